@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/backup"
+)
+
+// backupFlags control the sets a writing command saves chunks into before
+// overwriting them: the undo journal, and a backup set with --backup.
+type backupFlags struct {
+	enabled    bool
+	dir        string
+	noHistory  bool
+	historyDir string
+
+	backup  *backup.Set
+	journal *backup.Set
+}
+
+func (bf *backupFlags) bind(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&bf.enabled, "backup", userConfig.Backup, "Save every chunk before it is overwritten, for backup restore")
+	cmd.PersistentFlags().StringVar(&bf.dir, "backup-dir", "", "Directory holding backup sets (default: nbt-cli-backups next to the region directory)")
+	cmd.PersistentFlags().BoolVar(&bf.noHistory, "no-history", !userConfig.historyEnabled(), "Do not record this command in the undo journal")
+	cmd.PersistentFlags().StringVar(&bf.historyDir, "history-dir", "", "Undo journal directory (default: nbt-cli-history next to the region directory)")
+}
+
+// sideDir returns dir, else conf, else a directory called name beside the
+// region directory.
+func (cf *commonFlags) sideDir(dir, conf, name string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if conf != "" {
+		return conf, nil
+	}
+	base := cf.regionDir
+	if cf.regionFile != "" {
+		base = filepath.Dir(cf.regionFile)
+	}
+	if base == "" {
+		return "", errors.New("either --region-dir or --region-file must be specified")
+	}
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(abs), name), nil
+}
+
+func (cf *commonFlags) historyRoot() (string, error) {
+	return cf.sideDir(cf.write.backup.historyDir, userConfig.HistoryDir, "nbt-cli-history")
+}
+
+// backupSets returns the journal and backup sets of the command, nil when
+// turned off. Each set's directory is reported on stderr once something is
+// saved.
+func (cf *commonFlags) backupSets() (journal, bak *backup.Set, err error) {
+	bf := &cf.write.backup
+	command := strings.Join(os.Args, " ")
+	if !bf.noHistory && bf.journal == nil {
+		root, err := cf.historyRoot()
+		if err != nil {
+			return nil, nil, err
+		}
+		bf.journal = backup.New(root, command)
+		bf.journal.OnCreate = func(dir string) {
+			fmt.Fprintln(os.Stderr, "history:", dir)
+		}
+	}
+	if bf.enabled && bf.backup == nil {
+		root, err := cf.sideDir(bf.dir, userConfig.BackupDir, "nbt-cli-backups")
+		if err != nil {
+			return nil, nil, err
+		}
+		bf.backup = backup.New(root, command)
+		bf.backup.OnCreate = func(dir string) {
+			fmt.Fprintln(os.Stderr, "backup:", dir)
+		}
+	}
+	return bf.journal, bf.backup, nil
+}
+
+// writeHooks returns the hooks that save chunks into the command's sets
+// around each write; both are nil when no set is in use.
+func (cf *commonFlags) writeHooks() (before, after anvil.WriteHook, err error) {
+	journal, bak, err := cf.backupSets()
+	if err != nil {
+		return nil, nil, err
+	}
+	var sets []*backup.Set
+	for _, s := range []*backup.Set{journal, bak} {
+		if s != nil {
+			sets = append(sets, s)
+		}
+	}
+	if len(sets) > 0 {
+		before = func(r *anvil.Region, cx, cz int) error {
+			for _, s := range sets {
+				if err := s.SaveChunk(r, cx, cz); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if journal != nil {
+		after = journal.SaveWritten
+	}
+	return before, after, nil
+}
+
+func newBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "List and restore the backups taken with --backup",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newBackupListCmd(),
+		newBackupRestoreCmd(),
+		newBackupPruneCmd(),
+	)
+
+	return cmd
+}
+
+func newBackupListCmd() *cobra.Command {
+	var (
+		dir    string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the backup sets in a backup directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackupList(dir, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "backup-dir", userConfig.BackupDir, "Directory holding backup sets")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type backupRow struct {
+	Dir     string `json:"dir"`
+	Created string `json:"created"`
+	Entries int    `json:"entries"`
+	Command string `json:"command"`
+}
+
+func runBackupList(dir, format string) error {
+	if format != "text" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want text or json)", format)
+	}
+	if dir == "" {
+		return exitErrorf(1, "--backup-dir must be specified")
+	}
+	sets, err := backup.List(dir)
+	if err != nil {
+		return exitErrorf(1, "list backups: %w", err)
+	}
+	rows := make([]backupRow, len(sets))
+	for i, s := range sets {
+		rows[i] = backupRow{Dir: s.Dir, Created: s.Created.Format("2006-01-02 15:04:05"), Entries: s.Entries, Command: s.Command}
+	}
+	if format == "json" {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return exitError(1, err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, r := range rows {
+		fmt.Printf("%s\t%s\t%d\t%s\n", r.Dir, r.Created, r.Entries, r.Command)
+	}
+	return nil
+}
+
+func newBackupPruneCmd() *cobra.Command {
+	var (
+		dir       string
+		keep      int
+		olderThan string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old backup sets from a backup directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				return exitErrorf(1, "--backup-dir must be specified")
+			}
+			return runPrune(dir, keep, olderThan)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "backup-dir", userConfig.BackupDir, "Directory holding backup sets")
+	bindPruneFlags(cmd, &keep, &olderThan)
+
+	return cmd
+}
+
+func newBackupRestoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore <backup-set-dir>",
+		Short: "Put back every chunk and region file saved in a backup set",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackupRestore(args[0])
+		},
+	}
+}
+
+func runBackupRestore(dir string) error {
+	if dryRun {
+		return printRestorePlan(dir)
+	}
+	n, err := backup.Restore(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return exitErrorf(2, "restore: %w", err)
+	}
+	if err != nil {
+		return exitErrorf(1, "restore: %w (%d items restored)", err, n)
+	}
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "items restored: %d\n", n)
+	return nil
+}
+
+// printRestorePlan lists what restoring the set in dir would put back.
+func printRestorePlan(dir string) error {
+	entries, err := backup.Plan(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return exitErrorf(2, "restore: %w", err)
+	}
+	if err != nil {
+		return exitErrorf(1, "restore: %w", err)
+	}
+	for _, e := range entries {
+		switch {
+		case e.Kind == "region":
+			fmt.Printf("region %s: replace with saved copy\n", e.Region)
+		case e.File == "":
+			fmt.Printf("chunk %d,%d (%s): remove\n", e.X, e.Z, e.Region)
+		default:
+			fmt.Printf("chunk %d,%d (%s): restore\n", e.X, e.Z, e.Region)
+		}
+	}
+	return nil
+}
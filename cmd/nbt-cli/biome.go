@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func newBiomeCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "biome",
+		Short: "Read and write biomes per 4x4x4 cell",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bind(cmd)
+
+	cmd.AddCommand(
+		newBiomeGetCmd(cf),
+		newBiomeSetCmd(cf),
+	)
+
+	return cmd
+}
+
+func newBiomeGetCmd(cf *commonFlags) *cobra.Command {
+	var printRegion bool
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Print the biome of the cell containing the given coordinates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBiomeGet(cf, printRegion)
+		},
+	}
+
+	cmd.Flags().BoolVar(&printRegion, "print-region", false, "Also print region path to stdout on second line")
+	cf.bindPos(cmd)
+
+	return cmd
+}
+
+func newBiomeSetCmd(cf *commonFlags) *cobra.Command {
+	var (
+		biome string
+		bf    boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set the biome of a single cell or every cell overlapping a box",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBiomeSet(cf, &bf, biome)
+		},
+	}
+
+	cmd.Flags().StringVar(&biome, "biome", "", "Biome id (e.g. minecraft:plains)")
+	_ = cmd.MarkFlagRequired("biome")
+	bf.bind(cmd)
+	cf.bindPos(cmd)
+	for _, f := range posFlags {
+		cmd.MarkFlagsMutuallyExclusive(f, "from")
+	}
+
+	return cmd
+}
+
+func runBiomeGet(cf *commonFlags, printRegion bool) error {
+	s, chunk, path, err := cf.openChunk()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := cf.checkY(chunk, cf.y); err != nil {
+		return exitError(3, err)
+	}
+
+	biome, err := chunkedit.GetBiome(chunk, cf.x, cf.y, cf.z)
+	if err != nil {
+		return exitErrorf(2, "biome at (%d,%d,%d): %w", cf.x, cf.y, cf.z, err)
+	}
+
+	fmt.Println(biome)
+	if printRegion {
+		fmt.Println("region:", path)
+	} else {
+		fmt.Fprintln(os.Stderr, "region:", path)
+	}
+
+	return nil
+}
+
+func runBiomeSet(cf *commonFlags, bf *boxFlags, biome string) error {
+	box := coords.NewBox(cf.x, cf.y, cf.z, cf.x, cf.y, cf.z)
+	if bf.set() {
+		b, err := bf.box()
+		if err != nil {
+			return exitError(1, err)
+		}
+		box = b
+	}
+	if err := cf.checkY(nil, box.MinY, box.MaxY); err != nil {
+		return exitError(3, err)
+	}
+
+	cells := 0
+	st, err := editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		if err := cf.checkY(chunk, clip.MinY, clip.MaxY); err != nil {
+			return false, err
+		}
+		n, err := chunkedit.SetBiomeBox(chunk, clip, biome)
+		cells += n
+		return n > 0, err
+	})
+	if err != nil {
+		return exitErrorf(editExitCode(err), "set biome: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks in box")
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "cells changed: %d, chunks written: %d, chunks missing: %d\n", cells, st.Written, st.Missing)
+
+	return nil
+}
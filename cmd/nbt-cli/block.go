@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/scan"
+)
+
+func newBlockCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "block",
+		Short: "Edit block states within region files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bind(cmd)
+
+	cmd.AddCommand(
+		newBlockFillCmd(cf),
+		newBlockReplaceCmd(cf),
+		newBlockStatsCmd(cf),
+	)
+
+	return cmd
+}
+
+func newBlockFillCmd(cf *commonFlags) *cobra.Command {
+	var (
+		state string
+		bf    boxFlags
+		af    afterEditFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fill",
+		Short: "Set every block in a box to the given state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBlockFill(cf, &bf, &af, state)
+		},
+	}
+
+	cmd.Flags().StringVar(&state, "state", "", "Block state (e.g. minecraft:oak_log[axis=y])")
+	bf.bind(cmd)
+	af.bind(cmd)
+	_ = cmd.MarkFlagRequired("state")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func newBlockReplaceCmd(cf *commonFlags) *cobra.Command {
+	var (
+		match string
+		with  string
+		bf    boxFlags
+		af    afterEditFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replace",
+		Short: "Replace blocks matching a state within a box",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBlockReplace(cf, &bf, &af, match, with)
+		},
+	}
+
+	cmd.Flags().StringVar(&match, "match", "", "Block state to replace; omitted properties match any value")
+	cmd.Flags().StringVar(&with, "with", "", "Replacement block state")
+	bf.bind(cmd)
+	af.bind(cmd)
+	_ = cmd.MarkFlagRequired("match")
+	_ = cmd.MarkFlagRequired("with")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func runBlockFill(cf *commonFlags, bf *boxFlags, af *afterEditFlags, state string) error {
+	st, err := chunkedit.ParseBlockState(state)
+	if err != nil {
+		return exitError(1, err)
+	}
+	return runBlockEdit(cf, bf, af, func(chunk map[string]any, clip coords.Box) (int, error) {
+		return chunkedit.FillBox(chunk, clip, st)
+	})
+}
+
+func runBlockReplace(cf *commonFlags, bf *boxFlags, af *afterEditFlags, match, with string) error {
+	m, err := chunkedit.ParseBlockState(match)
+	if err != nil {
+		return exitError(1, err)
+	}
+	w, err := chunkedit.ParseBlockState(with)
+	if err != nil {
+		return exitError(1, err)
+	}
+	return runBlockEdit(cf, bf, af, func(chunk map[string]any, clip coords.Box) (int, error) {
+		return chunkedit.ReplaceBox(chunk, clip, m, w)
+	})
+}
+
+func runBlockEdit(cf *commonFlags, bf *boxFlags, af *afterEditFlags, edit func(map[string]any, coords.Box) (int, error)) error {
+	box, err := bf.box()
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	if err := cf.checkY(nil, box.MinY, box.MaxY); err != nil {
+		return exitError(3, err)
+	}
+
+	blocks := 0
+	st, err := editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		if err := cf.checkY(chunk, clip.MinY, clip.MaxY); err != nil {
+			return false, err
+		}
+		n, err := edit(chunk, clip)
+		if err != nil || n == 0 {
+			return false, err
+		}
+		blocks += n
+		return true, af.apply(chunk, cf.dimType)
+	})
+	if err != nil {
+		return exitErrorf(editExitCode(err), "edit blocks: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks in box")
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "blocks changed: %d, chunks written: %d, chunks missing: %d\n", blocks, st.Written, st.Missing)
+
+	return nil
+}
+
+func newBlockStatsCmd(cf *commonFlags) *cobra.Command {
+	var (
+		chunk  string
+		byName bool
+		perY   bool
+		format string
+		bf     boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Count block states in a chunk, box, region file or whole region directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBlockStats(cmd.Context(), cf, &bf, chunk, byName, perY, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&chunk, "chunk", "", "Absolute chunk coordinates as cx,cz")
+	cmd.Flags().BoolVar(&byName, "by-name", false, "Collapse block states to block names")
+	cmd.Flags().BoolVar(&perY, "per-y", false, "Also report counts for every Y level")
+	cmd.Flags().StringVar(&format, "format", "csv", "Output format: csv or json")
+	bf.bind(cmd)
+	cf.scan.bind(cmd)
+	cmd.MarkFlagsMutuallyExclusive("chunk", "from")
+
+	return cmd
+}
+
+func runBlockStats(ctx context.Context, cf *commonFlags, bf *boxFlags, chunk string, byName, perY bool, format string) error {
+	if format != "csv" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want csv or json)", format)
+	}
+
+	counts := chunkedit.NewBlockCounts(byName)
+	var (
+		st  boxStats
+		err error
+	)
+	switch {
+	case bf.set():
+		box, berr := bf.box()
+		if berr != nil {
+			return exitError(1, berr)
+		}
+		st, err = editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+			return false, counts.AddChunk(chunk, &clip)
+		})
+	case chunk != "":
+		v, perr := parseInts(chunk, 2)
+		if perr != nil {
+			return exitErrorf(1, "--chunk: %w", perr)
+		}
+		s, serr := cf.session()
+		if serr != nil {
+			return exitErrorf(1, "open region: %w", serr)
+		}
+		defer s.Close()
+		data, cerr := s.Chunk(v[0], v[1])
+		if cerr != nil {
+			return exitErrorf(1, "load chunk: %w", cerr)
+		}
+		st.Chunks = 1
+		err = counts.AddChunk(data, nil)
+	default:
+		var mu sync.Mutex
+		st, err = scanRegions(ctx, cf, nil, func(c scan.Chunk) (bool, error) {
+			local := chunkedit.NewBlockCounts(byName)
+			if err := local.AddChunk(c.Data, nil); err != nil {
+				return false, err
+			}
+			mu.Lock()
+			counts.Merge(local)
+			mu.Unlock()
+			return false, nil
+		})
+	}
+	if err != nil {
+		return exitErrorf(1, "count blocks: %w", err)
+	}
+
+	if format == "json" {
+		err = writeStatsJSON(counts, perY, st.Chunks)
+	} else {
+		err = writeStatsCSV(counts, perY)
+	}
+	if err != nil {
+		return exitErrorf(1, "write stats: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "chunks scanned: %d\n", st.Chunks)
+
+	return nil
+}
+
+func writeStatsCSV(counts *chunkedit.BlockCounts, perY bool) error {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"y", "block", "count"})
+	for _, bc := range chunkedit.Sorted(counts.Total) {
+		_ = w.Write([]string{"all", bc.Block, strconv.Itoa(bc.Count)})
+	}
+	if perY {
+		for _, y := range counts.Levels() {
+			for _, bc := range chunkedit.Sorted(counts.ByY[y]) {
+				_ = w.Write([]string{strconv.Itoa(y), bc.Block, strconv.Itoa(bc.Count)})
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+type statsLevel struct {
+	Y      int                    `json:"y"`
+	Blocks []chunkedit.BlockCount `json:"blocks"`
+}
+
+type statsReport struct {
+	Chunks int                    `json:"chunks"`
+	Total  []chunkedit.BlockCount `json:"total"`
+	Levels []statsLevel           `json:"levels,omitempty"`
+}
+
+func writeStatsJSON(counts *chunkedit.BlockCounts, perY bool, chunks int) error {
+	rep := statsReport{Chunks: chunks, Total: chunkedit.Sorted(counts.Total)}
+	if perY {
+		for _, y := range counts.Levels() {
+			rep.Levels = append(rep.Levels, statsLevel{Y: y, Blocks: chunkedit.Sorted(counts.ByY[y])})
+		}
+	}
+	out, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/scan"
+	"github.com/Zeptile/nbt-cli/pkg/world"
+)
+
+type boxFlags struct {
+	from string
+	to   string
+}
+
+func (bf *boxFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&bf.from, "from", "", "First corner of the box as x,y,z")
+	cmd.Flags().StringVar(&bf.to, "to", "", "Opposite corner of the box as x,y,z")
+	cmd.MarkFlagsRequiredTogether("from", "to")
+}
+
+func (bf *boxFlags) set() bool {
+	return bf.from != "" || bf.to != ""
+}
+
+func (bf *boxFlags) box() (coords.Box, error) {
+	x1, y1, z1, err := parseBlockPos(bf.from)
+	if err != nil {
+		return coords.Box{}, fmt.Errorf("--from: %w", err)
+	}
+	x2, y2, z2, err := parseBlockPos(bf.to)
+	if err != nil {
+		return coords.Box{}, fmt.Errorf("--to: %w", err)
+	}
+	return coords.NewBox(x1, y1, z1, x2, y2, z2), nil
+}
+
+func parseInts(s string, n int) ([]int, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated integers, got %q", n, s)
+	}
+	out := make([]int, n)
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", p)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func parseBlockPos(s string) (int, int, int, error) {
+	v, err := parseInts(s, 3)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return v[0], v[1], v[2], nil
+}
+
+type boxStats = anvil.Stats
+
+// chunkEditFunc edits one chunk; clip is the part of the box inside it.
+type chunkEditFunc = anvil.BoxFunc
+
+// editBox runs fn over the stored chunks intersecting box through a session
+// over --region-file or --region-dir.
+func editBox(cf *commonFlags, box coords.Box, fn chunkEditFunc) (boxStats, error) {
+	s, err := cf.session()
+	if err != nil {
+		return boxStats{}, err
+	}
+	defer s.Close()
+	st, err := s.EditBox(box, fn)
+	if errors.Is(err, anvil.ErrMultiRegionBox) {
+		err = fmt.Errorf("%w; use --region-dir", err)
+	}
+	return st, err
+}
+
+// afterEditFlags select the fix-ups applied to every chunk whose blocks a
+// command changed.
+type afterEditFlags struct {
+	heightmaps bool
+	resetLight bool
+}
+
+func (af *afterEditFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&af.heightmaps, "heightmaps", false, "Recompute heightmaps of changed chunks from their block states")
+	cmd.Flags().BoolVar(&af.resetLight, "reset-light", false, "Drop stored light of changed chunks so the server relights them")
+}
+
+// apply runs the selected fix-ups on chunk. dim gives the build height of
+// the heightmaps when known.
+func (af *afterEditFlags) apply(chunk map[string]any, dim *world.DimensionType) error {
+	if af.heightmaps {
+		height := 0
+		if dim != nil {
+			height = dim.Height
+		}
+		if err := chunkedit.RecomputeHeightmaps(chunk, height); err != nil {
+			return fmt.Errorf("recompute heightmaps: %w", err)
+		}
+	}
+	if af.resetLight {
+		if err := chunkedit.ResetLight(chunk); err != nil {
+			return fmt.Errorf("reset light: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanFlags tune whole-directory scans.
+type scanFlags struct {
+	workers  int
+	progress bool
+}
+
+func (sf *scanFlags) bind(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVar(&sf.workers, "workers", 0, "Regions scanned in parallel (default: number of CPUs)")
+	cmd.PersistentFlags().BoolVar(&sf.progress, "progress", false, "Report scan progress on stderr")
+}
+
+func (sf scanFlags) options() scan.Options {
+	opt := scan.Options{Workers: sf.workers}
+	if sf.progress {
+		opt.Progress = func(p scan.Progress) {
+			fmt.Fprintf(os.Stderr, "\rregions %d/%d, chunks %d, written %d", p.Done, p.Regions, p.Chunks, p.Written)
+			if p.Done == p.Regions {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+	return opt
+}
+
+// writeFlags select the compression of written chunks, whether they are
+// read back and whether they are backed up first.
+type writeFlags struct {
+	compression string
+	level       int
+	verify      bool
+	backup      backupFlags
+}
+
+func (wf *writeFlags) bind(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&wf.compression, "compression", "zlib", "Compression of written chunks: zlib, gzip, lz4 or none")
+	cmd.PersistentFlags().IntVar(&wf.level, "compression-level", 0, "zlib or gzip level from 1 (fastest) to 9 (smallest); 0 for the default")
+	cmd.PersistentFlags().BoolVar(&wf.verify, "verify", false, "Read every written chunk back and restore the previous one if it does not match")
+	wf.backup.bind(cmd)
+}
+
+// options returns the selected write options; unbound flags select the
+// default zlib.
+func (wf writeFlags) options() (anvil.WriteOptions, error) {
+	if wf.compression == "" {
+		return anvil.WriteOptions{Verify: wf.verify}, nil
+	}
+	c, err := anvil.ParseCompression(wf.compression)
+	if err != nil {
+		return anvil.WriteOptions{}, err
+	}
+	o := anvil.WriteOptions{Compression: c, Level: wf.level, Verify: wf.verify}
+	return o, o.Validate()
+}
+
+// scanRegions runs fn over every stored chunk of --region-file or
+// --region-dir with a pool of workers. fn is called concurrently. With
+// keys, only those top-level tags are decoded and fn must not edit.
+func scanRegions(ctx context.Context, cf *commonFlags, keys []string, fn scan.Func) (boxStats, error) {
+	s, err := cf.session()
+	if err != nil {
+		return boxStats{}, err
+	}
+	paths, err := s.RegionFiles()
+	s.Close()
+	if err != nil {
+		return boxStats{}, err
+	}
+	opt := cf.scan.options()
+	opt.Keys = keys
+	if opt.Write, err = cf.write.options(); err != nil {
+		return boxStats{}, err
+	}
+	if dryRun {
+		opt.DryRun = printChunkDiff
+	} else if opt.BeforeWrite, opt.AfterWrite, err = cf.writeHooks(); err != nil {
+		return boxStats{}, err
+	}
+	return scan.Files(ctx, paths, opt, fn)
+}
+
+// visitChunks edits the chunks intersecting box, or every stored chunk of
+// --region-file or --region-dir when box is nil, in which case clip is nil.
+// Whole-directory visits decode regions in parallel but call fn one chunk
+// at a time.
+func visitChunks(ctx context.Context, cf *commonFlags, box *coords.Box, fn func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error)) (boxStats, error) {
+	if box != nil {
+		return editBox(cf, *box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+			return fn(chunk, cx, cz, &clip)
+		})
+	}
+	var mu sync.Mutex
+	return scanRegions(ctx, cf, nil, func(c scan.Chunk) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(c.Data, c.X, c.Z, nil)
+	})
+}
+
+type chunkCountKey struct {
+	cx, cz int
+	name   string
+}
+
+// writeChunkCounts writes per-chunk removal counts as CSV, ordered by chunk
+// and then by name; label names the name column.
+func writeChunkCounts(counts map[chunkCountKey]int, label string) error {
+	keys := make([]chunkCountKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.cx != b.cx {
+			return a.cx < b.cx
+		}
+		if a.cz != b.cz {
+			return a.cz < b.cz
+		}
+		return a.name < b.name
+	})
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"chunk_x", "chunk_z", label, "removed"})
+	for _, k := range keys {
+		_ = w.Write([]string{strconv.Itoa(k.cx), strconv.Itoa(k.cz), k.name, strconv.Itoa(counts[k])})
+	}
+	w.Flush()
+	return w.Error()
+}
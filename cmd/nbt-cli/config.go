@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// config holds user defaults for flags, read from config.json in the
+// nbt-cli user config directory or from the file named by $NBT_CLI_CONFIG.
+type config struct {
+	// Backup turns --backup on by default.
+	Backup bool `json:"backup"`
+	// BackupDir replaces the default backup directory.
+	BackupDir string `json:"backup_dir"`
+	// History, when false, turns the undo journal off.
+	History *bool `json:"history"`
+	// HistoryDir replaces the default journal directory.
+	HistoryDir string `json:"history_dir"`
+}
+
+// historyEnabled reports whether writing commands keep an undo journal.
+func (c config) historyEnabled() bool {
+	return c.History == nil || *c.History
+}
+
+// userConfig is loaded by main before the commands are built, so flag
+// defaults can use it.
+var userConfig config
+
+func configPath() (string, error) {
+	if p := os.Getenv("NBT_CLI_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nbt-cli", "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file yields the defaults.
+func loadConfig() (config, error) {
+	var c config
+	p, err := configPath()
+	if err != nil {
+		return c, nil
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", p, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/world"
+)
+
+func newCoordsCmd() *cobra.Command {
+	var (
+		pos, chunk, region string
+		dimension, format  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "coords",
+		Short: "Convert between block, chunk, section, region and in-region coordinates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCoords(pos, chunk, region, dimension, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&pos, "pos", "", "Block position as x,y,z")
+	cmd.Flags().StringVar(&chunk, "chunk", "", "Chunk as cx,cz")
+	cmd.Flags().StringVar(&region, "region", "", "Region as rx,rz or r.<rx>.<rz>.mca")
+	cmd.Flags().StringVar(&dimension, "dimension", "overworld", "Dimension of the input: overworld or the_nether, for portal scaling")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.MarkFlagsMutuallyExclusive("pos", "chunk", "region")
+	cmd.MarkFlagsOneRequired("pos", "chunk", "region")
+
+	return cmd
+}
+
+// xzRange is an inclusive range of X/Z pairs.
+type xzRange struct {
+	Min []int `json:"min"`
+	Max []int `json:"max"`
+}
+
+// coordsReport holds every representation of a coords input. Block inputs
+// fill the single-position fields; chunk and region inputs fill the ranges.
+type coordsReport struct {
+	Block       []int    `json:"block,omitempty"`
+	Blocks      *xzRange `json:"blocks,omitempty"`
+	Section     []int    `json:"section,omitempty"`
+	InSection   []int    `json:"in_section,omitempty"`
+	Chunk       []int    `json:"chunk,omitempty"`
+	Chunks      *xzRange `json:"chunks,omitempty"`
+	Region      []int    `json:"region"`
+	RegionFile  string   `json:"region_file"`
+	InRegion    []int    `json:"in_region,omitempty"`
+	HeaderIndex *int     `json:"header_index,omitempty"`
+
+	// ScaledDimension is the dimension Scaled and ScaledBlocks refer to.
+	ScaledDimension string   `json:"scaled_dimension,omitempty"`
+	Scaled          []int    `json:"scaled,omitempty"`
+	ScaledBlocks    *xzRange `json:"scaled_blocks,omitempty"`
+}
+
+func runCoords(pos, chunk, region, dimension, format string) error {
+	if format != "text" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want text or json)", format)
+	}
+	dim, err := world.NormalizeDimension(dimension)
+	if err != nil {
+		return exitError(1, err)
+	}
+	// grow widens the upper end of a scaled range to the last block that
+	// maps back into it.
+	var (
+		scale     func(x, z int) (int, int)
+		scaledDim string
+		grow      int
+	)
+	switch dim {
+	case "minecraft:overworld":
+		scale, scaledDim = coords.OverworldToNether, "minecraft:the_nether"
+	case "minecraft:the_nether":
+		scale, scaledDim, grow = coords.NetherToOverworld, "minecraft:overworld", 7
+	}
+
+	var rep coordsReport
+	switch {
+	case pos != "":
+		x, y, z, err := parseBlockPos(pos)
+		if err != nil {
+			return exitErrorf(1, "--pos: %w", err)
+		}
+		rep = blockCoords(x, y, z)
+		if scale != nil {
+			sx, sz := scale(x, z)
+			rep.Scaled = []int{sx, y, sz}
+		}
+	case chunk != "":
+		v, err := parseInts(chunk, 2)
+		if err != nil {
+			return exitErrorf(1, "--chunk: %w", err)
+		}
+		rep = chunkCoords(v[0], v[1])
+	default:
+		rx, rz, err := parseRegionXZ(region)
+		if err != nil {
+			return exitErrorf(1, "--region: %w", err)
+		}
+		rep = regionCoords(rx, rz)
+	}
+	if scale != nil {
+		rep.ScaledDimension = scaledDim
+		if rep.Blocks != nil {
+			x0, z0 := scale(rep.Blocks.Min[0], rep.Blocks.Min[1])
+			x1, z1 := scale(rep.Blocks.Max[0], rep.Blocks.Max[1])
+			rep.ScaledBlocks = &xzRange{Min: []int{x0, z0}, Max: []int{x1 + grow, z1 + grow}}
+		}
+	}
+
+	if format == "json" {
+		out, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return exitErrorf(1, "encode coords: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	printCoords(rep)
+	return nil
+}
+
+func blockCoords(x, y, z int) coordsReport {
+	cx, cz := coords.WorldToChunkXZ(x, z)
+	rep := chunkCoords(cx, cz)
+	lx, lz := coords.LocalBlockXZ(x, z)
+	rep.Block = []int{x, y, z}
+	rep.Blocks = nil
+	rep.Section = []int{cx, coords.SectionY(y), cz}
+	rep.InSection = []int{lx, coords.FloorMod(y, 16), lz}
+	return rep
+}
+
+func chunkCoords(cx, cz int) coordsReport {
+	rx, rz := coords.ChunkToRegionXZ(cx, cz)
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	x0, z0 := coords.ChunkOrigin(cx, cz)
+	idx := coords.RegionHeaderIndex(lx, lz)
+	return coordsReport{
+		Blocks:      &xzRange{Min: []int{x0, z0}, Max: []int{x0 + 15, z0 + 15}},
+		Chunk:       []int{cx, cz},
+		Region:      []int{rx, rz},
+		RegionFile:  coords.RegionFileName(rx, rz),
+		InRegion:    []int{lx, lz},
+		HeaderIndex: &idx,
+	}
+}
+
+func regionCoords(rx, rz int) coordsReport {
+	x0, z0 := coords.RegionOrigin(rx, rz)
+	cx0, cz0 := coords.WorldToChunkXZ(x0, z0)
+	return coordsReport{
+		Blocks:     &xzRange{Min: []int{x0, z0}, Max: []int{x0 + 511, z0 + 511}},
+		Chunks:     &xzRange{Min: []int{cx0, cz0}, Max: []int{cx0 + 31, cz0 + 31}},
+		Region:     []int{rx, rz},
+		RegionFile: coords.RegionFileName(rx, rz),
+	}
+}
+
+func joinInts(v []int) string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+func printCoords(rep coordsReport) {
+	line := func(label, value string) {
+		fmt.Printf("%-13s %s\n", label+":", value)
+	}
+	span := func(r *xzRange) string {
+		return joinInts(r.Min) + " .. " + joinInts(r.Max)
+	}
+	if rep.Block != nil {
+		line("block", joinInts(rep.Block))
+	}
+	if rep.Blocks != nil {
+		line("blocks", span(rep.Blocks))
+	}
+	if rep.Section != nil {
+		line("section", joinInts(rep.Section))
+		line("in section", joinInts(rep.InSection))
+	}
+	if rep.Chunk != nil {
+		line("chunk", joinInts(rep.Chunk))
+	}
+	if rep.Chunks != nil {
+		line("chunks", span(rep.Chunks))
+	}
+	line("region", joinInts(rep.Region)+" ("+rep.RegionFile+")")
+	if rep.InRegion != nil {
+		line("in region", fmt.Sprintf("%s (header index %d)", joinInts(rep.InRegion), *rep.HeaderIndex))
+	}
+	name := strings.TrimPrefix(rep.ScaledDimension, "minecraft:")
+	if rep.Scaled != nil {
+		line(name, joinInts(rep.Scaled))
+	}
+	if rep.ScaledBlocks != nil {
+		line(name, span(rep.ScaledBlocks))
+	}
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Tnze/go-mc/nbt"
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/nbtdiff"
+	"github.com/Zeptile/nbt-cli/pkg/world"
+)
+
+// volatileKeys are tags the game rewrites on its own; --ignore-volatile
+// skips them.
+var volatileKeys = []string{"LastUpdate", "InhabitedTime", "LastPlayed", "Time", "DayTime"}
+
+// diffFlags are shared by the diff subcommands.
+type diffFlags struct {
+	dimension string
+	ignore    []string
+	volatile  bool
+	format    string
+	exitCode  bool
+}
+
+func (df *diffFlags) options() nbtdiff.Options {
+	opt := nbtdiff.Options{Ignore: df.ignore}
+	if df.volatile {
+		opt.Ignore = append(opt.Ignore, volatileKeys...)
+	}
+	return opt
+}
+
+func newDiffCmd() *cobra.Command {
+	df := &diffFlags{}
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare two chunks, boxes, NBT files or block entities path by path",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if df.format != "text" && df.format != "json" {
+				return exitErrorf(2, "unknown format %q (want text or json)", df.format)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&df.dimension, "dimension", "", "Dimension when a source is a world folder: overworld, the_nether, the_end or a namespaced id")
+	cmd.PersistentFlags().StringSliceVar(&df.ignore, "ignore", nil, "Skip these tag names at any depth, or exact paths such as Data.Time; repeatable")
+	cmd.PersistentFlags().BoolVar(&df.volatile, "ignore-volatile", false, "Skip tags the game updates on its own: LastUpdate, InhabitedTime, LastPlayed, Time and DayTime")
+	cmd.PersistentFlags().StringVar(&df.format, "format", "text", "Output format: text or json")
+	cmd.PersistentFlags().BoolVar(&df.exitCode, "exit-code", false, "Exit with code 1 when the sources differ; errors always exit with 2")
+
+	cmd.AddCommand(
+		newDiffChunkCmd(df),
+		newDiffBoxCmd(df),
+		newDiffFileCmd(df),
+		newDiffBlockEntityCmd(df),
+	)
+
+	return cmd
+}
+
+func newDiffChunkCmd(df *diffFlags) *cobra.Command {
+	var chunk string
+
+	cmd := &cobra.Command{
+		Use:   "chunk <a> <b>",
+		Short: "Compare a chunk of two region files, region directories or worlds",
+		Long: "Compare a chunk of two region files, region directories or worlds.\n" +
+			"Without --chunk, two region files are compared chunk by chunk.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffError(runDiffChunk(df, args[0], args[1], chunk))
+		},
+	}
+
+	cmd.Flags().StringVar(&chunk, "chunk", "", "Chunk cx,cz to compare")
+
+	return cmd
+}
+
+func newDiffBoxCmd(df *diffFlags) *cobra.Command {
+	var bf boxFlags
+
+	cmd := &cobra.Command{
+		Use:   "box <a> <b>",
+		Short: "Compare the blocks, block entities, entities and POI records inside a box of two worlds or region directories",
+		Long: "Compare the blocks, block entities, entities and POI records inside a box of two worlds or region directories.\n" +
+			"World folders are compared with their entities and poi directories. Region directories\n" +
+			"are compared on their own: pass entities or poi directories to compare those.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffError(runDiffBox(df, &bf, args[0], args[1]))
+		},
+	}
+
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func newDiffFileCmd(df *diffFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "file <a> <b>",
+		Short: "Compare two NBT files such as level.dat or player data, compressed or not",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffError(runDiffFile(df, args[0], args[1]))
+		},
+	}
+}
+
+func newDiffBlockEntityCmd(df *diffFlags) *cobra.Command {
+	var pos, otherPos string
+
+	cmd := &cobra.Command{
+		Use:   "block-entity <a> <b>",
+		Short: "Compare the block entities at a position of two sources, or at two positions",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffError(runDiffBlockEntity(df, args[0], args[1], pos, otherPos))
+		},
+	}
+
+	cmd.Flags().StringVar(&pos, "pos", "", "Block position x,y,z")
+	cmd.Flags().StringVar(&otherPos, "other-pos", "", "Position x,y,z of the block entity in <b> (default: --pos)")
+	_ = cmd.MarkFlagRequired("pos")
+
+	return cmd
+}
+
+// diffSource is one side of a chunk comparison: a region file, a region
+// directory or a world folder. For a world folder, entities and poi open
+// its entity and POI region directories.
+type diffSource struct {
+	s             *anvil.Session
+	entities, poi *anvil.Session
+	file          bool
+}
+
+func openDiffSource(path, dimension string) (*diffSource, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, exitError(2, err)
+	}
+	if !fi.IsDir() {
+		p, err := filepath.Abs(path)
+		if err != nil {
+			return nil, exitError(1, err)
+		}
+		return &diffSource{s: anvil.NewFileSession(p), file: true}, nil
+	}
+	if _, err := os.Stat(filepath.Join(path, "level.dat")); err == nil {
+		dirs, err := world.Resolve(path, dimension)
+		if err != nil {
+			return nil, worldError(err)
+		}
+		return &diffSource{
+			s:        anvil.NewSession(dirs.Region),
+			entities: anvil.NewSession(dirs.Entities),
+			poi:      anvil.NewSession(dirs.POI),
+		}, nil
+	} else if dimension != "" {
+		return nil, exitErrorf(1, "--dimension requires a world folder, got %s", path)
+	}
+	return &diffSource{s: anvil.NewSession(path)}, nil
+}
+
+func (d *diffSource) close() {
+	for _, s := range []*anvil.Session{d.s, d.entities, d.poi} {
+		if s != nil {
+			s.Close()
+		}
+	}
+}
+
+// chunk reads chunk (cx, cz) without caching it; a missing chunk or
+// region file gives nil.
+func (d *diffSource) chunk(cx, cz int) (map[string]any, error) {
+	return readDiffChunk(d.s, cx, cz)
+}
+
+// withWorldData returns a copy of chunk with the entities and POI records
+// of a world source's entity and POI chunks (cx, cz) added.
+func (d *diffSource) withWorldData(chunk map[string]any, cx, cz int) (map[string]any, error) {
+	if d.entities == nil {
+		return chunk, nil
+	}
+	out := maps.Clone(chunk)
+	ents, err := readDiffChunk(d.entities, cx, cz)
+	if err != nil {
+		return nil, fmt.Errorf("entities: %w", err)
+	}
+	if v, ok := ents["Entities"]; ok {
+		out["Entities"] = v
+	}
+	poi, err := readDiffChunk(d.poi, cx, cz)
+	if err != nil {
+		return nil, fmt.Errorf("poi: %w", err)
+	}
+	if v, ok := poi["Sections"]; ok {
+		out["Sections"] = v
+	}
+	return out, nil
+}
+
+func readDiffChunk(s *anvil.Session, cx, cz int) (map[string]any, error) {
+	r, err := s.Region(cx, cz)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	c, err := r.ReadChunkNBT(lx, lz)
+	if errors.Is(err, anvil.ErrChunkNotPresent) {
+		return nil, nil
+	}
+	return c, err
+}
+
+// chunkDiff is the comparison of one chunk. OnlyIn names the side holding
+// a chunk the other lacks.
+type chunkDiff struct {
+	ChunkX  int              `json:"chunk_x"`
+	ChunkZ  int              `json:"chunk_z"`
+	OnlyIn  string           `json:"only_in,omitempty"`
+	Changes []nbtdiff.Change `json:"changes,omitempty"`
+}
+
+func runDiffChunk(df *diffFlags, pathA, pathB, chunk string) error {
+	a, b, err := openDiffSources(df, pathA, pathB)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+	defer b.close()
+
+	var keys [][2]int
+	if chunk != "" {
+		v, err := parseInts(chunk, 2)
+		if err != nil {
+			return exitErrorf(1, "--chunk: %w", err)
+		}
+		keys = [][2]int{{v[0], v[1]}}
+	} else {
+		if !a.file || !b.file {
+			return exitErrorf(1, "--chunk is required unless both sources are region files")
+		}
+		if keys, err = storedChunkUnion(a, b); err != nil {
+			return exitError(1, err)
+		}
+	}
+	return diffChunks(df, a, b, keys, nil)
+}
+
+// storedChunkUnion lists the chunks stored in either of two region files,
+// in file order, numbered after the first file's name.
+func storedChunkUnion(a, b *diffSource) ([][2]int, error) {
+	seen := map[[2]int]bool{}
+	for _, d := range []*diffSource{a, b} {
+		r, err := d.s.Region(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		present, err := r.Chunks()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range present {
+			seen[c] = true
+		}
+	}
+	rx, rz, _ := coords.ParseRegionFileName(filepath.Base(a.s.RegionPath(0, 0)))
+	keys := make([][2]int, 0, len(seen))
+	for c := range seen {
+		keys = append(keys, [2]int{rx*32 + c[0], rz*32 + c[1]})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][1] != keys[j][1] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+	return keys, nil
+}
+
+func runDiffBox(df *diffFlags, bf *boxFlags, pathA, pathB string) error {
+	box, err := bf.box()
+	if err != nil {
+		return exitError(1, err)
+	}
+	a, b, err := openDiffSources(df, pathA, pathB)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+	defer b.close()
+	if a.file || b.file {
+		return exitErrorf(1, "box needs region directories or worlds")
+	}
+
+	cx0, cz0, cx1, cz1 := box.ChunkRange()
+	var keys [][2]int
+	for cz := cz0; cz <= cz1; cz++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			keys = append(keys, [2]int{cx, cz})
+		}
+	}
+	return diffChunks(df, a, b, keys, func(d *diffSource, chunk map[string]any, cx, cz int) (map[string]any, error) {
+		chunk, err := d.withWorldData(chunk, cx, cz)
+		if err != nil {
+			return nil, err
+		}
+		return boxView(chunk, box.ClipToChunk(cx, cz))
+	})
+}
+
+// boxView reduces a chunk to what lies inside clip: the state of every
+// block, and the block entities, entities and POI records in the box. Tags
+// that describe the whole chunk, such as heightmaps or light, are left
+// out.
+func boxView(chunk map[string]any, clip coords.Box) (map[string]any, error) {
+	var blocks, blockEntities, entities, pois []any
+	for sy := coords.FloorDiv(clip.MinY, 16); sy <= coords.FloorDiv(clip.MaxY, 16); sy++ {
+		sec := clip
+		sec.MinY, sec.MaxY = max(clip.MinY, sy*16), min(clip.MaxY, sy*16+15)
+		if _, err := chunkedit.GetBlockState(chunk, sec.MinX, sec.MinY, sec.MinZ); err != nil {
+			// Sections that are not stored hold no blocks to compare.
+			continue
+		}
+		err := chunkedit.VisitBlocks(chunk, sec, func(x, y, z int, st chunkedit.BlockState) {
+			blocks = append(blocks, map[string]any{"x": int32(x), "y": int32(y), "z": int32(z), "state": st.String()})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, be := range chunkedit.ListBlockEntities(chunk) {
+		if x, y, z, ok := chunkedit.BlockEntityPos(be); ok && clip.Contains(x, y, z) {
+			blockEntities = append(blockEntities, be)
+		}
+	}
+	for _, e := range chunkedit.ListEntities(chunk) {
+		x, y, z, ok := chunkedit.EntityPos(e)
+		if ok && clip.Contains(int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z))) {
+			entities = append(entities, e)
+		}
+	}
+	for _, p := range chunkedit.ListPOIs(chunk) {
+		if clip.Contains(p.X, p.Y, p.Z) {
+			pois = append(pois, map[string]any{"x": int32(p.X), "y": int32(p.Y), "z": int32(p.Z), "type": p.Type, "free_tickets": int32(p.FreeTickets)})
+		}
+	}
+	return map[string]any{"blocks": blocks, "block_entities": blockEntities, "Entities": entities, "poi": pois}, nil
+}
+
+func openDiffSources(df *diffFlags, pathA, pathB string) (*diffSource, *diffSource, error) {
+	a, err := openDiffSource(pathA, df.dimension)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := openDiffSource(pathB, df.dimension)
+	if err != nil {
+		a.close()
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+// diffChunks compares the given chunks of a and b and prints those that
+// differ. Chunks missing from both sides are skipped. When view is not nil,
+// only what it returns for each chunk is compared.
+func diffChunks(df *diffFlags, a, b *diffSource, keys [][2]int, view func(d *diffSource, chunk map[string]any, cx, cz int) (map[string]any, error)) error {
+	opt := df.options()
+	var out []chunkDiff
+	for _, k := range keys {
+		ca, err := a.chunk(k[0], k[1])
+		if err != nil {
+			return exitErrorf(1, "chunk %d,%d of a: %w", k[0], k[1], err)
+		}
+		cb, err := b.chunk(k[0], k[1])
+		if err != nil {
+			return exitErrorf(1, "chunk %d,%d of b: %w", k[0], k[1], err)
+		}
+		d := chunkDiff{ChunkX: k[0], ChunkZ: k[1]}
+		switch {
+		case ca == nil && cb == nil:
+			continue
+		case cb == nil:
+			d.OnlyIn = "a"
+		case ca == nil:
+			d.OnlyIn = "b"
+		default:
+			if view != nil {
+				if ca, err = view(a, ca, k[0], k[1]); err != nil {
+					return exitErrorf(1, "chunk %d,%d of a: %w", k[0], k[1], err)
+				}
+				if cb, err = view(b, cb, k[0], k[1]); err != nil {
+					return exitErrorf(1, "chunk %d,%d of b: %w", k[0], k[1], err)
+				}
+			}
+			if d.Changes = nbtdiff.Diff(ca, cb, opt); len(d.Changes) == 0 {
+				continue
+			}
+		}
+		out = append(out, d)
+	}
+
+	if df.format == "json" {
+		if out == nil {
+			out = []chunkDiff{}
+		}
+		if err := printDiffJSON(out); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range out {
+			if d.OnlyIn != "" {
+				fmt.Printf("chunk %d,%d: only in %s\n", d.ChunkX, d.ChunkZ, d.OnlyIn)
+				continue
+			}
+			fmt.Printf("chunk %d,%d:\n", d.ChunkX, d.ChunkZ)
+			for _, c := range d.Changes {
+				fmt.Println("  " + c.String())
+			}
+		}
+	}
+	return diffExit(df, len(out) > 0)
+}
+
+func runDiffFile(df *diffFlags, pathA, pathB string) error {
+	a, err := readNBTFile(pathA)
+	if err != nil {
+		return nbtFileError(err)
+	}
+	b, err := readNBTFile(pathB)
+	if err != nil {
+		return nbtFileError(err)
+	}
+	return printChanges(df, nbtdiff.Diff(a, b, df.options()))
+}
+
+func nbtFileError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return exitError(2, err)
+	}
+	return exitError(1, err)
+}
+
+// readNBTFile decodes an NBT file stored gzip- or zlib-compressed, as the
+// game does, or uncompressed.
+func readNBTFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = bytes.NewReader(data)
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case len(data) >= 1 && data[0] == 0x78:
+		if r, err = zlib.NewReader(r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	var v map[string]any
+	if _, err := nbt.NewDecoder(r).Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+func runDiffBlockEntity(df *diffFlags, pathA, pathB, pos, otherPos string) error {
+	x, y, z, err := parseBlockPos(pos)
+	if err != nil {
+		return exitErrorf(1, "--pos: %w", err)
+	}
+	x2, y2, z2 := x, y, z
+	if otherPos != "" {
+		if x2, y2, z2, err = parseBlockPos(otherPos); err != nil {
+			return exitErrorf(1, "--other-pos: %w", err)
+		}
+	}
+	a, b, err := openDiffSources(df, pathA, pathB)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+	defer b.close()
+
+	ea, err := blockEntityAt(a, x, y, z)
+	if err != nil {
+		return err
+	}
+	eb, err := blockEntityAt(b, x2, y2, z2)
+	if err != nil {
+		return err
+	}
+	return printChanges(df, nbtdiff.Diff(ea, eb, df.options()))
+}
+
+func blockEntityAt(d *diffSource, x, y, z int) (map[string]any, error) {
+	cx, cz := coords.WorldToChunkXZ(x, z)
+	chunk, err := d.chunk(cx, cz)
+	if err != nil {
+		return nil, exitErrorf(1, "load chunk: %w", err)
+	}
+	if chunk != nil {
+		if ent, ok := chunkedit.GetBlockEntity(chunk, x, y, z); ok {
+			return ent, nil
+		}
+	}
+	return nil, exitErrorf(2, "not found at (%d,%d,%d) in %s", x, y, z, d.s.RegionPath(cx, cz))
+}
+
+func printChanges(df *diffFlags, changes []nbtdiff.Change) error {
+	if df.format == "json" {
+		if changes == nil {
+			changes = []nbtdiff.Change{}
+		}
+		if err := printDiffJSON(changes); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range changes {
+			fmt.Println(c.String())
+		}
+	}
+	return diffExit(df, len(changes) > 0)
+}
+
+func printDiffJSON(v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return exitError(1, err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// errDiffer is returned with --exit-code when the sources differ. Like
+// cmp and diff, exit code 1 means "different" and errors use 2.
+var errDiffer = exitError(1, nil)
+
+// diffExit reports differences through the exit code with --exit-code.
+func diffExit(df *diffFlags, differ bool) error {
+	if differ && df.exitCode {
+		return errDiffer
+	}
+	return nil
+}
+
+// diffError gives errors exit code 2 or above, leaving 1 to errDiffer.
+func diffError(err error) error {
+	if err == nil || err == errDiffer {
+		return err
+	}
+	if ec, ok := err.(exitCoder); ok && ec.ExitCode() >= 2 {
+		return err
+	}
+	return exitError(2, err)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/nbtdiff"
+)
+
+// dryRun is the global --dry-run flag: commands decode and edit as usual,
+// but print a structural diff of every chunk instead of writing it.
+var dryRun bool
+
+var dryRunMu sync.Mutex
+
+// printChunkDiff is the anvil.DryRunFunc of --dry-run. It is safe for
+// concurrent use by scans.
+func printChunkDiff(r *anvil.Region, lx, lz int, old, edited map[string]any) error {
+	rx, rz, _ := coords.ParseRegionFileName(filepath.Base(r.Path()))
+	changes := nbtdiff.Diff(old, edited, nbtdiff.Options{})
+	if len(changes) == 0 {
+		return nil
+	}
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	state := "changed"
+	if old == nil {
+		state = "added"
+	}
+	fmt.Printf("chunk %d,%d (%s) %s:\n", rx*32+lx, rz*32+lz, r.Path(), state)
+	for _, c := range changes {
+		fmt.Println("  " + c.String())
+	}
+	return nil
+}
+
+// dryRunNote tells on stderr that a --dry-run command wrote nothing.
+func dryRunNote() {
+	if dryRun {
+		fmt.Fprintln(os.Stderr, "dry run: nothing was written")
+	}
+}
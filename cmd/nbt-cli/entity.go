@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/world"
+)
+
+// storageFlags locate the region files of one world storage directory
+// such as entities or poi.
+type storageFlags struct {
+	kind       string
+	world      string
+	dimension  string
+	dir        string
+	regionFile string
+	scan       scanFlags
+	write      writeFlags
+
+	// dirs holds the directories resolved from --world and --dimension.
+	dirs world.Dirs
+}
+
+func (sf *storageFlags) bind(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&sf.world, "world", "", "Path to the world folder; files are read from its "+sf.kind+" directory")
+	cmd.PersistentFlags().StringVar(&sf.dimension, "dimension", "", "Dimension with --world: overworld, the_nether, the_end or a namespaced id")
+	cmd.PersistentFlags().StringVar(&sf.dir, sf.kind+"-dir", "", "Path to a "+sf.kind+" directory containing r.*.*.mca")
+	cmd.PersistentFlags().StringVar(&sf.regionFile, "region-file", "", "Path to a single "+sf.kind+" region file .mca")
+	cmd.MarkFlagsMutuallyExclusive("world", sf.kind+"-dir", "region-file")
+	sf.scan.bind(cmd)
+	sf.write.bind(cmd)
+}
+
+// regions returns commonFlags addressing the storage's region files.
+func (sf *storageFlags) regions() (*commonFlags, error) {
+	if sf.dimension != "" && sf.world == "" {
+		return nil, exitErrorf(1, "--dimension requires --world")
+	}
+	if _, err := sf.write.options(); err != nil {
+		return nil, exitError(1, err)
+	}
+	switch {
+	case sf.regionFile != "":
+		return &commonFlags{regionFile: sf.regionFile, scan: sf.scan, write: sf.write}, nil
+	case sf.dir != "":
+		return &commonFlags{regionDir: sf.dir, scan: sf.scan, write: sf.write}, nil
+	case sf.world != "":
+		dirs, err := world.Resolve(sf.world, sf.dimension)
+		if err != nil {
+			return nil, worldError(err)
+		}
+		sf.dirs = dirs
+		if sf.kind == "poi" {
+			return &commonFlags{regionDir: dirs.POI, scan: sf.scan, write: sf.write}, nil
+		}
+		return &commonFlags{regionDir: dirs.Entities, scan: sf.scan, write: sf.write}, nil
+	}
+	return nil, exitErrorf(1, "one of --world, --%s-dir or --region-file must be specified", sf.kind)
+}
+
+func newEntityCmd() *cobra.Command {
+	ef := &storageFlags{kind: "entities"}
+	cmd := &cobra.Command{
+		Use:   "entity",
+		Short: "Inspect and edit entities in entity region files (1.17+)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	ef.bind(cmd)
+
+	cmd.AddCommand(
+		newEntityListCmd(ef),
+		newEntityGetCmd(ef),
+		newEntityDeleteCmd(ef),
+		newEntityPatchCmd(ef),
+		newEntityPurgeCmd(ef),
+	)
+
+	return cmd
+}
+
+// filterFlags selects entities by type and field predicates.
+type filterFlags struct {
+	types []string
+	where []string
+}
+
+func (ff *filterFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&ff.types, "type", nil, "Only match entities of these ids (e.g. minecraft:item); repeatable")
+	cmd.Flags().StringArrayVar(&ff.where, "where", nil, "Field predicate: key, !key, key=v, key!=v, key>n, key>=n, key<n, key<=n; repeatable")
+}
+
+func (ff *filterFlags) filter() (chunkedit.EntityFilter, error) {
+	var f chunkedit.EntityFilter
+	for _, t := range ff.types {
+		if !strings.Contains(t, ":") {
+			t = "minecraft:" + t
+		}
+		f.Types = append(f.Types, t)
+	}
+	for _, w := range ff.where {
+		p, err := chunkedit.ParseEntityPredicate(w)
+		if err != nil {
+			return f, fmt.Errorf("--where: %w", err)
+		}
+		f.Where = append(f.Where, p)
+	}
+	return f, nil
+}
+
+func newEntityListCmd(ef *storageFlags) *cobra.Command {
+	var (
+		ff     filterFlags
+		format string
+		bf     boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List entities with their UUID, type and position",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEntityList(cmd.Context(), ef, &bf, &ff, format)
+		},
+	}
+
+	ff.bind(cmd)
+	cmd.Flags().StringVar(&format, "format", "csv", "Output format: csv or json")
+	bf.bind(cmd)
+
+	return cmd
+}
+
+func newEntityGetCmd(ef *storageFlags) *cobra.Command {
+	var (
+		uuid string
+		bf   boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Print the entity with the given UUID as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEntityGet(cmd.Context(), ef, &bf, uuid)
+		},
+	}
+
+	cmd.Flags().StringVar(&uuid, "uuid", "", "Entity UUID")
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("uuid")
+
+	return cmd
+}
+
+func newEntityDeleteCmd(ef *storageFlags) *cobra.Command {
+	var (
+		uuid string
+		bf   boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the entity with the given UUID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEntityDelete(cmd.Context(), ef, &bf, uuid)
+		},
+	}
+
+	cmd.Flags().StringVar(&uuid, "uuid", "", "Entity UUID")
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("uuid")
+
+	return cmd
+}
+
+func newEntityPatchCmd(ef *storageFlags) *cobra.Command {
+	var (
+		uuid     string
+		data     string
+		dataFile string
+		bf       boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "patch",
+		Short: "Merge JSON fields into the entity with the given UUID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEntityPatch(cmd.Context(), ef, &bf, uuid, data, dataFile)
+		},
+	}
+
+	cmd.Flags().StringVar(&uuid, "uuid", "", "Entity UUID")
+	cmd.Flags().StringVar(&data, "data", "", "JSON object of fields to set")
+	cmd.Flags().StringVar(&dataFile, "data-file", "", "Path to JSON file of fields to set")
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("uuid")
+	cmd.MarkFlagsOneRequired("data", "data-file")
+
+	return cmd
+}
+
+// storageScope resolves the storage regions and the optional box to search.
+// Errors carry their exit code.
+func storageScope(ef *storageFlags, bf *boxFlags) (*commonFlags, *coords.Box, error) {
+	cf, err := ef.regions()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bf.set() {
+		return cf, nil, nil
+	}
+	box, err := bf.box()
+	if err != nil {
+		return nil, nil, exitError(1, err)
+	}
+	return cf, &box, nil
+}
+
+// entityIn reports whether ent lies inside clip; a nil clip matches all.
+func entityIn(ent map[string]any, clip *coords.Box) bool {
+	if clip == nil {
+		return true
+	}
+	x, y, z, ok := chunkedit.EntityPos(ent)
+	return ok && clip.Contains(int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z)))
+}
+
+type entityRow struct {
+	UUID   string     `json:"uuid"`
+	ID     string     `json:"id"`
+	Pos    [3]float64 `json:"pos"`
+	ChunkX int        `json:"chunk_x"`
+	ChunkZ int        `json:"chunk_z"`
+}
+
+func runEntityList(ctx context.Context, ef *storageFlags, bf *boxFlags, ff *filterFlags, format string) error {
+	if format != "csv" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want csv or json)", format)
+	}
+	filter, err := ff.filter()
+	if err != nil {
+		return exitError(1, err)
+	}
+	cf, box, err := storageScope(ef, bf)
+	if err != nil {
+		return err
+	}
+
+	rows := []entityRow{}
+	st, err := visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		for _, ent := range chunkedit.ListEntities(chunk) {
+			if !entityIn(ent, clip) || !filter.Match(ent) {
+				continue
+			}
+			row := entityRow{ID: chunkedit.EntityID(ent), ChunkX: cx, ChunkZ: cz}
+			row.UUID, _ = chunkedit.EntityUUID(ent)
+			row.Pos[0], row.Pos[1], row.Pos[2], _ = chunkedit.EntityPos(ent)
+			rows = append(rows, row)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "list entities: %w", err)
+	}
+	// Regions are scanned in parallel; list in chunk order.
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.ChunkX != b.ChunkX {
+			return a.ChunkX < b.ChunkX
+		}
+		return a.ChunkZ < b.ChunkZ
+	})
+
+	if format == "json" {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return exitErrorf(1, "encode entities: %w", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"uuid", "id", "x", "y", "z", "chunk_x", "chunk_z"})
+		for _, r := range rows {
+			_ = w.Write([]string{
+				r.UUID, r.ID,
+				strconv.FormatFloat(r.Pos[0], 'f', -1, 64),
+				strconv.FormatFloat(r.Pos[1], 'f', -1, 64),
+				strconv.FormatFloat(r.Pos[2], 'f', -1, 64),
+				strconv.Itoa(r.ChunkX), strconv.Itoa(r.ChunkZ),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return exitErrorf(1, "write entities: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "entities: %d, chunks scanned: %d\n", len(rows), st.Chunks)
+
+	return nil
+}
+
+// editEntity calls fn on the entity with the given UUID and writes its
+// chunk back when fn reports a change. It fails with exit code 2 when no
+// entity has that UUID.
+func editEntity(ctx context.Context, ef *storageFlags, bf *boxFlags, uuid string, fn func(chunk, ent map[string]any, cx, cz int) (bool, error)) error {
+	if _, err := chunkedit.ParseUUID(uuid); err != nil {
+		return exitError(1, err)
+	}
+	cf, box, err := storageScope(ef, bf)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	_, err = visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		ent, ok := chunkedit.FindEntity(chunk, uuid)
+		if !ok || !entityIn(ent, clip) {
+			return false, nil
+		}
+		found = true
+		return fn(chunk, ent, cx, cz)
+	})
+	if err != nil {
+		return exitErrorf(1, "edit entity: %w", err)
+	}
+	if !found {
+		return exitErrorf(2, "no entity with UUID %s", uuid)
+	}
+	return nil
+}
+
+func runEntityGet(ctx context.Context, ef *storageFlags, bf *boxFlags, uuid string) error {
+	return editEntity(ctx, ef, bf, uuid, func(chunk, ent map[string]any, cx, cz int) (bool, error) {
+		out, err := json.MarshalIndent(ent, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(out))
+		fmt.Fprintf(os.Stderr, "chunk: %d,%d\n", cx, cz)
+		return false, nil
+	})
+}
+
+func runEntityDelete(ctx context.Context, ef *storageFlags, bf *boxFlags, uuid string) error {
+	err := editEntity(ctx, ef, bf, uuid, func(chunk, ent map[string]any, cx, cz int) (bool, error) {
+		want, _ := chunkedit.EntityUUID(ent)
+		chunkedit.DeleteEntities(chunk, func(e map[string]any) bool {
+			u, _ := chunkedit.EntityUUID(e)
+			return u == want
+		})
+		fmt.Fprintf(os.Stderr, "chunk: %d,%d\n", cx, cz)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func runEntityPatch(ctx context.Context, ef *storageFlags, bf *boxFlags, uuid, data, dataFile string) error {
+	if data == "" && dataFile != "" {
+		contents, err := os.ReadFile(dataFile)
+		if err != nil {
+			return exitErrorf(1, "read data file: %w", err)
+		}
+		data = string(contents)
+	}
+
+	err := editEntity(ctx, ef, bf, uuid, func(chunk, ent map[string]any, cx, cz int) (bool, error) {
+		if err := chunkedit.MergeJSONTyped(ent, data); err != nil {
+			return false, fmt.Errorf("merge data: %w", err)
+		}
+		if x, _, z, ok := chunkedit.EntityPos(ent); ok {
+			ncx, ncz := coords.WorldToChunkXZ(int(math.Floor(x)), int(math.Floor(z)))
+			if ncx != cx || ncz != cz {
+				return false, fmt.Errorf("new Pos is in chunk %d,%d; entities cannot be moved between chunks", ncx, ncz)
+			}
+		} else if _, has := ent["Pos"]; has {
+			return false, errors.New("Pos must be a list of three numbers")
+		}
+		fmt.Fprintf(os.Stderr, "chunk: %d,%d\n", cx, cz)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func newEntityPurgeCmd(ef *storageFlags) *cobra.Command {
+	var (
+		ff filterFlags
+		bf boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete every entity matching the type and predicate filters",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEntityPurge(cmd.Context(), ef, &bf, &ff)
+		},
+	}
+
+	ff.bind(cmd)
+	bf.bind(cmd)
+	cmd.MarkFlagsOneRequired("type", "where", "from")
+
+	return cmd
+}
+
+func runEntityPurge(ctx context.Context, ef *storageFlags, bf *boxFlags, ff *filterFlags) error {
+	filter, err := ff.filter()
+	if err != nil {
+		return exitError(1, err)
+	}
+	cf, box, err := storageScope(ef, bf)
+	if err != nil {
+		return err
+	}
+
+	removed := map[chunkCountKey]int{}
+	total := 0
+	st, err := visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		n := chunkedit.DeleteEntities(chunk, func(ent map[string]any) bool {
+			if !entityIn(ent, clip) || !filter.Match(ent) {
+				return false
+			}
+			removed[chunkCountKey{cx, cz, chunkedit.EntityID(ent)}]++
+			return true
+		})
+		total += n
+		return n > 0, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "purge entities: %w", err)
+	}
+
+	if err := writeChunkCounts(removed, "id"); err != nil {
+		return exitErrorf(1, "write report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "entities removed: %d, chunks scanned: %d, chunks written: %d\n", total, st.Chunks, st.Written)
+
+	return nil
+}
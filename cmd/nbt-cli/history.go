@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/backup"
+)
+
+func newHistoryCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Inspect the undo journal of writing commands",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bindRegion(cmd)
+	cmd.PersistentFlags().StringVar(&cf.write.backup.historyDir, "history-dir", "", "Undo journal directory (default: nbt-cli-history next to the region directory)")
+
+	cmd.AddCommand(
+		newHistoryListCmd(cf),
+		newHistoryPruneCmd(cf),
+	)
+
+	return cmd
+}
+
+func newHistoryListCmd(cf *commonFlags) *cobra.Command {
+	var (
+		all    bool
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List journaled commands, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistoryList(cf, all, format)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Also list commands that were undone")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type historyRow struct {
+	N       int    `json:"n"`
+	Dir     string `json:"dir"`
+	Created string `json:"created"`
+	Entries int    `json:"entries"`
+	Undone  bool   `json:"undone"`
+	Command string `json:"command"`
+}
+
+// journal returns the journaled commands, newest first; n counts the ones
+// not undone from 1.
+func journal(cf *commonFlags) ([]historyRow, error) {
+	root, err := cf.historyRoot()
+	if err != nil {
+		return nil, err
+	}
+	sets, err := backup.List(root)
+	if err != nil {
+		return nil, err
+	}
+	var rows []historyRow
+	n := 0
+	for i := len(sets) - 1; i >= 0; i-- {
+		s := sets[i]
+		row := historyRow{Dir: s.Dir, Created: s.Created.Format("2006-01-02 15:04:05"), Entries: s.Entries, Undone: s.Undone != nil, Command: s.Command}
+		if !row.Undone {
+			n++
+			row.N = n
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func runHistoryList(cf *commonFlags, all bool, format string) error {
+	if format != "text" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want text or json)", format)
+	}
+	rows, err := journal(cf)
+	if err != nil {
+		return exitErrorf(1, "read history: %w", err)
+	}
+	shown := rows[:0:0]
+	for _, r := range rows {
+		if all || !r.Undone {
+			shown = append(shown, r)
+		}
+	}
+	if format == "json" {
+		out, err := json.MarshalIndent(shown, "", "  ")
+		if err != nil {
+			return exitError(1, err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, r := range shown {
+		n := strconv.Itoa(r.N)
+		if r.Undone {
+			n = "undone"
+		}
+		fmt.Printf("%s\t%s\t%d\t%s\n", n, r.Created, r.Entries, r.Command)
+	}
+	return nil
+}
+
+func newHistoryPruneCmd(cf *commonFlags) *cobra.Command {
+	var (
+		keep      int
+		olderThan string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old journaled commands to free disk space",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := cf.historyRoot()
+			if err != nil {
+				return exitError(1, err)
+			}
+			return runPrune(root, keep, olderThan)
+		},
+	}
+
+	bindPruneFlags(cmd, &keep, &olderThan)
+
+	return cmd
+}
+
+func bindPruneFlags(cmd *cobra.Command, keep *int, olderThan *string) {
+	cmd.Flags().IntVar(keep, "keep", 0, "Keep the newest N sets")
+	cmd.Flags().StringVar(olderThan, "older-than", "", "Only delete sets older than this age, such as 30d or 12h")
+}
+
+// runPrune deletes the sets under root selected by --keep and --older-than
+// and prints their directories. With --dry-run nothing is deleted.
+func runPrune(root string, keep int, olderThan string) error {
+	if keep < 0 {
+		return exitErrorf(1, "--keep must not be negative")
+	}
+	if keep == 0 && olderThan == "" {
+		return exitErrorf(1, "--keep or --older-than must be specified")
+	}
+	var before time.Time
+	if olderThan != "" {
+		age, err := parseAge(olderThan)
+		if err != nil {
+			return exitErrorf(1, "--older-than: %w", err)
+		}
+		before = time.Now().Add(-age)
+	}
+	gone, err := backup.Prune(root, keep, before, dryRun)
+	for _, s := range gone {
+		fmt.Println(s.Dir)
+	}
+	if err != nil {
+		return exitErrorf(1, "prune: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "sets deleted: %d\n", len(gone))
+	return nil
+}
+
+// parseAge parses a time.Duration, or a whole number of days such as 30d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func newUndoCmd() *cobra.Command {
+	var (
+		cf    commonFlags
+		force bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "undo [N]",
+		Short: "Revert the last N journaled commands (default 1), newest first",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := 1
+			if len(args) == 1 {
+				v, err := strconv.Atoi(args[0])
+				if err != nil || v < 1 {
+					return exitErrorf(1, "invalid count %q", args[0])
+				}
+				n = v
+			}
+			return runUndo(&cf, n, force)
+		},
+	}
+
+	cf.bindRegion(cmd)
+	cmd.Flags().StringVar(&cf.write.backup.historyDir, "history-dir", "", "Undo journal directory (default: nbt-cli-history next to the region directory)")
+	cmd.Flags().BoolVar(&force, "force", false, "Revert even chunks that changed after the command ran")
+
+	return cmd
+}
+
+func runUndo(cf *commonFlags, n int, force bool) error {
+	rows, err := journal(cf)
+	if err != nil {
+		return exitErrorf(1, "read history: %w", err)
+	}
+	var todo []historyRow
+	for _, r := range rows {
+		if !r.Undone && len(todo) < n {
+			todo = append(todo, r)
+		}
+	}
+	if len(todo) == 0 {
+		return exitErrorf(2, "nothing to undo")
+	}
+	if len(todo) < n {
+		return exitErrorf(2, "only %d commands can be undone", len(todo))
+	}
+
+	for i, r := range todo {
+		// A dry run restores nothing, so only the newest command can be
+		// checked against the chunks on disk.
+		if !force && (!dryRun || i == 0) {
+			if err := backup.Verify(r.Dir); errors.Is(err, backup.ErrChanged) {
+				return exitErrorf(1, "undo %q: %w; use --force to revert anyway", r.Command, err)
+			} else if err != nil {
+				return exitErrorf(1, "undo %q: %w", r.Command, err)
+			}
+		}
+		if dryRun {
+			fmt.Printf("undo: %s\n", r.Command)
+			if err := printRestorePlan(r.Dir); err != nil {
+				return err
+			}
+			continue
+		}
+		items, err := backup.Restore(r.Dir)
+		if err != nil {
+			return exitErrorf(1, "undo %q: %w (%d items restored)", r.Command, err, items)
+		}
+		if err := backup.MarkUndone(r.Dir); err != nil {
+			return exitErrorf(1, "undo %q: %w", r.Command, err)
+		}
+		fmt.Fprintf(os.Stderr, "undone: %s (%d items)\n", r.Command, items)
+	}
+	fmt.Println("ok")
+	return nil
+}
@@ -1,25 +1,216 @@
 package main
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/spf13/cobra"
 
-	"nbt-cli/internal/anvil"
-	"nbt-cli/internal/chunkedit"
-	"nbt-cli/internal/coords"
+	nbtcli "github.com/Zeptile/nbt-cli"
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/scan"
+	"github.com/Zeptile/nbt-cli/pkg/world"
 )
 
 type commonFlags struct {
 	regionDir  string
 	regionFile string
+	world      string
+	dimension  string
 	x          int
 	y          int
 	z          int
+
+	// pos, chunk and region are the alternative position inputs; resolvePos
+	// folds them into x, y and z.
+	pos    string
+	chunk  string
+	region string
+
+	// dirs holds the directories resolved from --world and --dimension,
+	// and dimType the dimension's height when it could be determined.
+	dirs    world.Dirs
+	dimType *world.DimensionType
+
+	scan  scanFlags
+	write writeFlags
+}
+
+func (cf *commonFlags) bindRegion(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&cf.regionDir, "region-dir", "", "Path to region directory containing r.*.*.mca")
+	cmd.PersistentFlags().StringVar(&cf.regionFile, "region-file", "", "Path to single region file .mca")
+	cmd.PersistentFlags().StringVar(&cf.world, "world", "", "Path to the world folder; replaces --region-dir")
+	cmd.PersistentFlags().StringVar(&cf.dimension, "dimension", "", "Dimension with --world: overworld, the_nether, the_end or a namespaced id")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return cf.resolve()
+	}
+}
+
+// resolve checks the write flags and sets regionDir from --world and
+// --dimension.
+func (cf *commonFlags) resolve() error {
+	if _, err := cf.write.options(); err != nil {
+		return exitError(1, err)
+	}
+	if cf.world == "" {
+		if cf.dimension != "" {
+			return exitErrorf(1, "--dimension requires --world")
+		}
+		return nil
+	}
+	if cf.regionDir != "" || cf.regionFile != "" {
+		return exitErrorf(1, "--world cannot be combined with --region-dir or --region-file")
+	}
+	dirs, err := world.Resolve(cf.world, cf.dimension)
+	if err != nil {
+		return worldError(err)
+	}
+	cf.dirs = dirs
+	cf.regionDir = dirs.Region
+	if t, err := world.LoadDimensionType(cf.world, dirs.Dimension); err == nil {
+		cf.dimType = &t
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: build height unknown, only chunk sections are checked: %v\n", err)
+	}
+	return nil
+}
+
+// errYBounds marks coordinates outside the build height. Commands exit
+// with code 3 for it.
+var errYBounds = errors.New("y out of bounds")
+
+// checkY fails with errYBounds when a y lies outside the dimension's build
+// height (known with --world) or, when chunk is given, outside the chunk's
+// block sections.
+func (cf *commonFlags) checkY(chunk map[string]any, ys ...int) error {
+	for _, y := range ys {
+		if t := cf.dimType; t != nil && !t.Contains(y) {
+			return fmt.Errorf("%w: y=%d is outside the build height of %s (%d..%d)", errYBounds, y, cf.dirs.Dimension, t.MinY, t.MaxY())
+		}
+	}
+	if chunk == nil {
+		return nil
+	}
+	lo, hi, err := chunkedit.BlockYRange(chunk)
+	if err != nil {
+		return nil
+	}
+	for _, y := range ys {
+		if y < lo || y > hi {
+			return fmt.Errorf("%w: y=%d is outside the chunk's sections (%d..%d)", errYBounds, y, lo, hi)
+		}
+	}
+	return nil
+}
+
+// editExitCode is 3 for Y bounds errors and 1 otherwise.
+func editExitCode(err error) int {
+	if errors.Is(err, errYBounds) {
+		return 3
+	}
+	return 1
+}
+
+// worldError maps a world resolution error to its exit code.
+func worldError(err error) error {
+	if errors.Is(err, world.ErrNotFound) {
+		return exitError(2, err)
+	}
+	return exitError(1, err)
+}
+
+func (cf *commonFlags) bind(cmd *cobra.Command) {
+	cf.bindRegion(cmd)
+	cf.write.bind(cmd)
+}
+
+// posFlags are the flags bindPos registers.
+var posFlags = []string{"x", "y", "z", "pos", "chunk", "region"}
+
+// bindPos adds the position flags to a command that acts on a single
+// position. Box and scan commands do not get them, so they cannot be
+// silently ignored there.
+func (cf *commonFlags) bindPos(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&cf.x, "x", 0, "Block X coordinate")
+	cmd.Flags().IntVar(&cf.y, "y", 0, "Block Y coordinate")
+	cmd.Flags().IntVar(&cf.z, "z", 0, "Block Z coordinate")
+	cmd.Flags().StringVar(&cf.pos, "pos", "", "Block position as x,y,z; replaces --x, --y and --z")
+	cmd.Flags().StringVar(&cf.chunk, "chunk", "", "Chunk cx,cz; X and Z become offsets 0..15 inside it")
+	cmd.Flags().StringVar(&cf.region, "region", "", "Region rx,rz or r.<rx>.<rz>.mca; X and Z become offsets 0..511 inside it")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return cf.resolvePos(cmd.Flags().Changed)
+	}
+}
+
+// resolvePos applies --pos, --chunk and --region to x, y and z. changed
+// reports whether a flag was set on the command line.
+func (cf *commonFlags) resolvePos(changed func(name string) bool) error {
+	if cf.pos != "" {
+		if changed("x") || changed("y") || changed("z") {
+			return exitErrorf(1, "--pos cannot be combined with --x, --y or --z")
+		}
+		x, y, z, err := parseBlockPos(cf.pos)
+		if err != nil {
+			return exitErrorf(1, "--pos: %w", err)
+		}
+		cf.x, cf.y, cf.z = x, y, z
+	}
+	if cf.chunk != "" && cf.region != "" {
+		return exitErrorf(1, "--chunk cannot be combined with --region")
+	}
+	switch {
+	case cf.chunk != "":
+		v, err := parseInts(cf.chunk, 2)
+		if err != nil {
+			return exitErrorf(1, "--chunk: %w", err)
+		}
+		if err := checkOffset(cf.x, cf.z, 16); err != nil {
+			return exitErrorf(1, "--chunk: %w", err)
+		}
+		ox, oz := coords.ChunkOrigin(v[0], v[1])
+		cf.x, cf.z = ox+cf.x, oz+cf.z
+	case cf.region != "":
+		rx, rz, err := parseRegionXZ(cf.region)
+		if err != nil {
+			return exitErrorf(1, "--region: %w", err)
+		}
+		if err := checkOffset(cf.x, cf.z, 512); err != nil {
+			return exitErrorf(1, "--region: %w", err)
+		}
+		ox, oz := coords.RegionOrigin(rx, rz)
+		cf.x, cf.z = ox+cf.x, oz+cf.z
+	}
+	return nil
+}
+
+func checkOffset(x, z, size int) error {
+	if x < 0 || x >= size || z < 0 || z >= size {
+		return fmt.Errorf("offset %d,%d is outside 0..%d", x, z, size-1)
+	}
+	return nil
+}
+
+// parseRegionXZ accepts "rx,rz" or a region file name such as r.-1.2.mca.
+func parseRegionXZ(s string) (int, int, error) {
+	if rx, rz, ok := coords.ParseRegionFileName(filepath.Base(s)); ok {
+		return rx, rz, nil
+	}
+	v, err := parseInts(s, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	return v[0], v[1], nil
 }
 
 type exitCoder interface {
@@ -57,9 +248,25 @@ func newRootCmd() *cobra.Command {
 		Short:         "Minecraft NBT map editor for block entities",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		Version:       nbtcli.Version,
 	}
-
-	root.AddCommand(newMapCmd())
+	root.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Edit in memory only and print a structural diff of each chunk instead of writing")
+
+	root.AddCommand(
+		newMapCmd(),
+		newBiomeCmd(),
+		newBlockCmd(),
+		newSchemCmd(),
+		newStructureCmd(),
+		newEntityCmd(),
+		newPOICmd(),
+		newRegionCmd(),
+		newDiffCmd(),
+		newBackupCmd(),
+		newHistoryCmd(),
+		newUndoCmd(),
+		newCoordsCmd(),
+	)
 
 	return root
 }
@@ -74,14 +281,11 @@ func newMapCmd() *cobra.Command {
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(&cf.regionDir, "region-dir", "", "Path to region directory containing r.*.*.mca")
-	cmd.PersistentFlags().StringVar(&cf.regionFile, "region-file", "", "Path to single region file .mca")
-	cmd.PersistentFlags().IntVar(&cf.x, "x", 0, "Block X coordinate")
-	cmd.PersistentFlags().IntVar(&cf.y, "y", 0, "Block Y coordinate")
-	cmd.PersistentFlags().IntVar(&cf.z, "z", 0, "Block Z coordinate")
+	cf.bind(cmd)
 
 	cmd.AddCommand(
 		newMapGetCmd(cf),
+		newMapListCmd(cf),
 		newMapCreateCmd(cf),
 		newMapDeleteCmd(cf),
 	)
@@ -102,6 +306,31 @@ func newMapGetCmd(cf *commonFlags) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&printRegion, "print-region", false, "Also print region path to stdout on second line")
+	cf.bindPos(cmd)
+
+	return cmd
+}
+
+func newMapListCmd(cf *commonFlags) *cobra.Command {
+	var (
+		ids    []string
+		format string
+		bf     boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List block entities in a box, region file or whole region directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMapList(cmd.Context(), cf, &bf, ids, format)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&ids, "id", nil, "Only list these block entity ids (e.g. minecraft:chest); repeatable")
+	cmd.Flags().StringVar(&format, "format", "csv", "Output format: csv or json")
+	bf.bind(cmd)
+	cf.scan.bind(cmd)
 
 	return cmd
 }
@@ -127,6 +356,7 @@ func newMapCreateCmd(cf *commonFlags) *cobra.Command {
 	cmd.Flags().StringVar(&data, "data", "", "JSON for extra NBT fields")
 	cmd.Flags().StringVar(&dataFile, "data-file", "", "Path to JSON file for extra NBT fields")
 	cmd.Flags().BoolVar(&printRegion, "print-region", false, "Also print region path to stdout on second line")
+	cf.bindPos(cmd)
 
 	return cmd
 }
@@ -144,20 +374,19 @@ func newMapDeleteCmd(cf *commonFlags) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&printRegion, "print-region", false, "Also print region path to stdout on second line")
+	cf.bindPos(cmd)
 
 	return cmd
 }
 
 func runMapGet(cf *commonFlags, printRegion bool) error {
-	r, path, err := openRegion(cf)
+	s, chunk, path, err := cf.openChunk()
 	if err != nil {
-		return exitErrorf(1, "open region: %w", err)
+		return err
 	}
-	defer r.Close()
-
-	chunk, _, _, _, _, err := loadChunk(r, cf.x, cf.z)
-	if err != nil {
-		return exitErrorf(1, "load chunk: %w", err)
+	defer s.Close()
+	if err := cf.checkY(chunk, cf.y); err != nil {
+		return exitError(3, err)
 	}
 
 	ent, ok := chunkedit.GetBlockEntity(chunk, cf.x, cf.y, cf.z)
@@ -180,6 +409,99 @@ func runMapGet(cf *commonFlags, printRegion bool) error {
 	return nil
 }
 
+type blockEntityRow struct {
+	ID     string         `json:"id"`
+	X      int            `json:"x"`
+	Y      int            `json:"y"`
+	Z      int            `json:"z"`
+	ChunkX int            `json:"chunk_x"`
+	ChunkZ int            `json:"chunk_z"`
+	Data   map[string]any `json:"data"`
+}
+
+func runMapList(ctx context.Context, cf *commonFlags, bf *boxFlags, ids []string, format string) error {
+	if format != "csv" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want csv or json)", format)
+	}
+	want := namespacedSet(ids)
+
+	var (
+		mu   sync.Mutex
+		rows = []blockEntityRow{}
+	)
+	collect := func(chunk map[string]any, cx, cz int, clip *coords.Box) {
+		for _, ent := range chunkedit.ListBlockEntities(chunk) {
+			x, y, z, ok := chunkedit.BlockEntityPos(ent)
+			if !ok || (clip != nil && !clip.Contains(x, y, z)) {
+				continue
+			}
+			id, _ := ent["id"].(string)
+			if want != nil && !want[id] {
+				continue
+			}
+			mu.Lock()
+			rows = append(rows, blockEntityRow{ID: id, X: x, Y: y, Z: z, ChunkX: cx, ChunkZ: cz, Data: ent})
+			mu.Unlock()
+		}
+	}
+
+	var (
+		st  boxStats
+		err error
+	)
+	if bf.set() {
+		box, berr := bf.box()
+		if berr != nil {
+			return exitError(1, berr)
+		}
+		st, err = editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+			collect(chunk, cx, cz, &clip)
+			return false, nil
+		})
+	} else {
+		// Only the block entity tags are decoded; sections, light and
+		// heightmaps are skipped.
+		st, err = scanRegions(ctx, cf, chunkedit.BlockEntityKeys, func(c scan.Chunk) (bool, error) {
+			collect(c.Data, c.X, c.Z, nil)
+			return false, nil
+		})
+	}
+	if err != nil {
+		return exitErrorf(1, "list block entities: %w", err)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.ChunkX != b.ChunkX {
+			return a.ChunkX < b.ChunkX
+		}
+		return a.ChunkZ < b.ChunkZ
+	})
+
+	if format == "json" {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return exitErrorf(1, "encode block entities: %w", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"id", "x", "y", "z", "chunk_x", "chunk_z"})
+		for _, r := range rows {
+			_ = w.Write([]string{
+				r.ID, strconv.Itoa(r.X), strconv.Itoa(r.Y), strconv.Itoa(r.Z),
+				strconv.Itoa(r.ChunkX), strconv.Itoa(r.ChunkZ),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return exitErrorf(1, "write block entities: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "block entities: %d, chunks scanned: %d\n", len(rows), st.Chunks)
+
+	return nil
+}
+
 func runMapCreate(cf *commonFlags, id, data, dataFile string, printRegion bool) error {
 	if data == "" && dataFile != "" {
 		contents, err := os.ReadFile(dataFile)
@@ -189,15 +511,13 @@ func runMapCreate(cf *commonFlags, id, data, dataFile string, printRegion bool)
 		data = string(contents)
 	}
 
-	r, path, err := openRegion(cf)
+	s, chunk, path, err := cf.openChunk()
 	if err != nil {
-		return exitErrorf(1, "open region: %w", err)
+		return err
 	}
-	defer r.Close()
-
-	chunk, cx, cz, _, _, err := loadChunk(r, cf.x, cf.z)
-	if err != nil {
-		return exitErrorf(1, "load chunk: %w", err)
+	defer s.Close()
+	if err := cf.checkY(chunk, cf.y); err != nil {
+		return exitError(3, err)
 	}
 
 	extra := map[string]any{}
@@ -207,8 +527,8 @@ func runMapCreate(cf *commonFlags, id, data, dataFile string, printRegion bool)
 
 	chunkedit.CreateOrUpdateBlockEntity(chunk, cf.x, cf.y, cf.z, id, extra)
 
-	if err := r.WriteChunkNBT(cx, cz, chunk); err != nil {
-		return exitErrorf(1, "write chunk: %w", err)
+	if err := cf.saveChunk(s); err != nil {
+		return err
 	}
 
 	fmt.Println("ok")
@@ -222,23 +542,21 @@ func runMapCreate(cf *commonFlags, id, data, dataFile string, printRegion bool)
 }
 
 func runMapDelete(cf *commonFlags, printRegion bool) error {
-	r, path, err := openRegion(cf)
+	s, chunk, path, err := cf.openChunk()
 	if err != nil {
-		return exitErrorf(1, "open region: %w", err)
+		return err
 	}
-	defer r.Close()
-
-	chunk, cx, cz, _, _, err := loadChunk(r, cf.x, cf.z)
-	if err != nil {
-		return exitErrorf(1, "load chunk: %w", err)
+	defer s.Close()
+	if err := cf.checkY(chunk, cf.y); err != nil {
+		return exitError(3, err)
 	}
 
 	if !chunkedit.DeleteBlockEntity(chunk, cf.x, cf.y, cf.z) {
 		return exitErrorf(2, "not found at (%d,%d,%d)", cf.x, cf.y, cf.z)
 	}
 
-	if err := r.WriteChunkNBT(cx, cz, chunk); err != nil {
-		return exitErrorf(1, "write chunk: %w", err)
+	if err := cf.saveChunk(s); err != nil {
+		return err
 	}
 
 	fmt.Println("ok")
@@ -251,31 +569,76 @@ func runMapDelete(cf *commonFlags, printRegion bool) error {
 	return nil
 }
 
-func openRegion(cf *commonFlags) (*anvil.Region, string, error) {
-	if cf.regionFile != "" {
+// session opens a chunk session over --region-file or --region-dir.
+func (cf *commonFlags) session() (*anvil.Session, error) {
+	opts, err := cf.write.options()
+	if err != nil {
+		return nil, err
+	}
+	var s *anvil.Session
+	switch {
+	case cf.regionFile != "":
 		p, err := filepath.Abs(cf.regionFile)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
-		r, err := anvil.OpenRegionFile(p)
-		return r, p, err
+		s = anvil.NewFileSession(p)
+	case cf.regionDir != "":
+		s = anvil.NewSession(cf.regionDir)
+	default:
+		return nil, errors.New("either --region-dir or --region-file must be specified")
 	}
-	if cf.regionDir == "" {
-		return nil, "", errors.New("either --region-dir or --region-file must be specified")
+	s.SetWriteOptions(opts)
+	if dryRun {
+		s.SetDryRun(printChunkDiff)
+		return s, nil
+	}
+	before, after, err := cf.writeHooks()
+	if err != nil {
+		return nil, err
 	}
-	return anvil.OpenRegionForWorldXZ(cf.regionDir, cf.x, cf.z)
+	s.SetBeforeWrite(before)
+	s.SetAfterWrite(after)
+	return s, nil
 }
 
-func loadChunk(r *anvil.Region, x, z int) (map[string]any, int, int, int, int, error) {
-	cxAbs, czAbs := coords.WorldToChunkXZ(x, z)
-	cx, cz := coords.InRegionChunkIndex(cxAbs, czAbs)
-	chunk, err := r.ReadChunkNBT(cx, cz)
-	return chunk, cx, cz, cxAbs, czAbs, err
+// openChunk loads the chunk holding the --x/--z column through a new
+// session, which the caller closes, and returns its region path.
+func (cf *commonFlags) openChunk() (*anvil.Session, map[string]any, string, error) {
+	s, err := cf.session()
+	if err != nil {
+		return nil, nil, "", exitErrorf(1, "open region: %w", err)
+	}
+	cx, cz := coords.WorldToChunkXZ(cf.x, cf.z)
+	chunk, err := s.Chunk(cx, cz)
+	if err != nil {
+		s.Close()
+		return nil, nil, "", exitErrorf(1, "load chunk: %w", err)
+	}
+	return s, chunk, s.RegionPath(cx, cz), nil
+}
+
+// saveChunk writes back the chunk loaded by openChunk.
+func (cf *commonFlags) saveChunk(s *anvil.Session) error {
+	s.MarkDirty(coords.WorldToChunkXZ(cf.x, cf.z))
+	if _, err := s.Flush(); err != nil {
+		return exitErrorf(1, "write chunk: %w", err)
+	}
+	return nil
 }
 
 func main() {
+	var err error
+	if userConfig, err = loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	rootCmd := newRootCmd()
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	dryRunNote()
+	if err != nil {
 		if ec, ok := err.(exitCoder); ok {
 			if msg := err.Error(); msg != "" {
 				fmt.Fprintln(os.Stderr, msg)
@@ -283,6 +646,17 @@ func main() {
 			os.Exit(ec.ExitCode())
 		}
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(usageExitCode(cmd))
+	}
+}
+
+// usageExitCode is the exit code for errors cobra reports itself, such as
+// unknown flags: 1, except under diff, where 1 means the sources differ.
+func usageExitCode(cmd *cobra.Command) int {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "diff" {
+			return 2
+		}
 	}
+	return 1
 }
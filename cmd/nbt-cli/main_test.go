@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -39,13 +40,106 @@ func TestNewMapCmdStructure(t *testing.T) {
 		t.Fatalf("use: got %q, want 'map'", cmd.Use)
 	}
 
-	for _, flag := range []string{"region-dir", "region-file", "x", "y", "z"} {
+	for _, flag := range []string{"region-dir", "region-file"} {
 		if f := cmd.PersistentFlags().Lookup(flag); f == nil {
 			t.Fatalf("persistent flag %q not registered", flag)
 		}
 	}
 
-	wantSubs := map[string]bool{"get": true, "create": true, "delete": true}
+	// Position flags belong to the single-position subcommands only.
+	wantSubs := map[string]bool{"get": true, "list": false, "create": true, "delete": true}
+	for _, sub := range cmd.Commands() {
+		wantPos, ok := wantSubs[sub.Name()]
+		if !ok {
+			continue
+		}
+		delete(wantSubs, sub.Name())
+		for _, flag := range []string{"x", "y", "z", "pos", "chunk", "region"} {
+			if got := sub.Flags().Lookup(flag) != nil; got != wantPos {
+				t.Fatalf("%s: flag %q registered: %v, want %v", sub.Name(), flag, got, wantPos)
+			}
+		}
+	}
+	if len(wantSubs) != 0 {
+		t.Fatalf("missing subcommands: %v", wantSubs)
+	}
+}
+
+func TestNewBiomeCmdStructure(t *testing.T) {
+	cmd := newBiomeCmd()
+	wantSubs := map[string]bool{"get": true, "set": true}
+	for _, sub := range cmd.Commands() {
+		delete(wantSubs, sub.Name())
+	}
+	if len(wantSubs) != 0 {
+		t.Fatalf("missing subcommands: %v", wantSubs)
+	}
+}
+
+func TestParseBlockPos(t *testing.T) {
+	x, y, z, err := parseBlockPos("-12, 64,300")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if x != -12 || y != 64 || z != 300 {
+		t.Fatalf("got %d,%d,%d", x, y, z)
+	}
+	if _, _, _, err := parseBlockPos("1,2"); err == nil {
+		t.Fatalf("expected error for two components")
+	}
+}
+
+func TestNewBlockCmdStructure(t *testing.T) {
+	cmd := newBlockCmd()
+	wantSubs := map[string]bool{"fill": true, "replace": true, "stats": true}
+	for _, sub := range cmd.Commands() {
+		delete(wantSubs, sub.Name())
+	}
+	if len(wantSubs) != 0 {
+		t.Fatalf("missing subcommands: %v", wantSubs)
+	}
+}
+
+func TestNewEntityCmdStructure(t *testing.T) {
+	cmd := newEntityCmd()
+	for _, flag := range []string{"world", "entities-dir", "region-file"} {
+		if f := cmd.PersistentFlags().Lookup(flag); f == nil {
+			t.Fatalf("persistent flag %q not registered", flag)
+		}
+	}
+	wantSubs := map[string]bool{"list": true, "get": true, "delete": true, "patch": true}
+	for _, sub := range cmd.Commands() {
+		delete(wantSubs, sub.Name())
+	}
+	if len(wantSubs) != 0 {
+		t.Fatalf("missing subcommands: %v", wantSubs)
+	}
+}
+
+func TestNewPOICmdStructure(t *testing.T) {
+	cmd := newPOICmd()
+	for _, flag := range []string{"world", "poi-dir", "region-file"} {
+		if f := cmd.PersistentFlags().Lookup(flag); f == nil {
+			t.Fatalf("persistent flag %q not registered", flag)
+		}
+	}
+	wantSubs := map[string]bool{"list": true, "delete": true, "prune": true}
+	for _, sub := range cmd.Commands() {
+		delete(wantSubs, sub.Name())
+	}
+	if len(wantSubs) != 0 {
+		t.Fatalf("missing subcommands: %v", wantSubs)
+	}
+}
+
+func TestNewDiffCmdStructure(t *testing.T) {
+	cmd := newDiffCmd()
+	for _, flag := range []string{"ignore", "ignore-volatile", "format", "exit-code", "dimension"} {
+		if f := cmd.PersistentFlags().Lookup(flag); f == nil {
+			t.Fatalf("persistent flag %q not registered", flag)
+		}
+	}
+	wantSubs := map[string]bool{"chunk": true, "box": true, "file": true, "block-entity": true}
 	for _, sub := range cmd.Commands() {
 		delete(wantSubs, sub.Name())
 	}
@@ -54,3 +148,92 @@ func TestNewMapCmdStructure(t *testing.T) {
 	}
 }
 
+func TestDiffExitCodes(t *testing.T) {
+	if err := diffExit(&diffFlags{exitCode: true}, true); err.(exitCoder).ExitCode() != 1 {
+		t.Fatalf("differ: %v", err)
+	}
+	if err := diffError(errors.New("boom")); err.(exitCoder).ExitCode() != 2 {
+		t.Fatalf("plain error: %v", err)
+	}
+	if err := diffError(exitError(3, errors.New("y"))); err.(exitCoder).ExitCode() != 3 {
+		t.Fatalf("code 3 error: %v", err)
+	}
+	if err := diffError(exitError(1, errors.New("x"))); err.(exitCoder).ExitCode() != 2 {
+		t.Fatalf("code 1 error: %v", err)
+	}
+	diff, _, _ := newDiffCmd().Find([]string{"chunk"})
+	if got := usageExitCode(diff); got != 2 {
+		t.Fatalf("diff usage exit code: %d", got)
+	}
+	if got := usageExitCode(newBlockCmd()); got != 1 {
+		t.Fatalf("block usage exit code: %d", got)
+	}
+}
+
+func TestCommonFlagsResolve(t *testing.T) {
+	cf := &commonFlags{world: "w", regionDir: "r"}
+	if err := cf.resolve(); err == nil {
+		t.Fatal("expected error for --world with --region-dir")
+	}
+	cf = &commonFlags{dimension: "the_nether"}
+	if err := cf.resolve(); err == nil {
+		t.Fatal("expected error for --dimension without --world")
+	}
+	cf = &commonFlags{world: t.TempDir(), dimension: "the_end"}
+	err := cf.resolve()
+	var ec exitCoder
+	if !errors.As(err, &ec) || ec.ExitCode() != 2 {
+		t.Fatalf("missing dimension: got %v", err)
+	}
+}
+
+func TestCommonFlagsResolvePos(t *testing.T) {
+	none := func(string) bool { return false }
+
+	cf := &commonFlags{pos: "-3,70,5"}
+	if err := cf.resolvePos(none); err != nil || cf.x != -3 || cf.y != 70 || cf.z != 5 {
+		t.Fatalf("--pos: got %d,%d,%d err=%v", cf.x, cf.y, cf.z, err)
+	}
+	cf = &commonFlags{pos: "1,2,3"}
+	if err := cf.resolvePos(func(name string) bool { return name == "y" }); err == nil {
+		t.Fatal("expected error for --pos with --y")
+	}
+	cf = &commonFlags{pos: "2,64,15", chunk: "-1,3"}
+	if err := cf.resolvePos(none); err != nil || cf.x != -14 || cf.z != 63 {
+		t.Fatalf("--chunk: got %d,%d err=%v", cf.x, cf.z, err)
+	}
+	cf = &commonFlags{x: 16, chunk: "0,0"}
+	if err := cf.resolvePos(none); err == nil {
+		t.Fatal("expected error for offset outside the chunk")
+	}
+	cf = &commonFlags{x: 10, z: 511, region: "r.-1.2.mca"}
+	if err := cf.resolvePos(none); err != nil || cf.x != -502 || cf.z != 1535 {
+		t.Fatalf("--region: got %d,%d err=%v", cf.x, cf.z, err)
+	}
+}
+
+func TestBlockCoords(t *testing.T) {
+	rep := blockCoords(-1, -65, 530)
+	want := map[string][]int{
+		"section":    {-1, -5, 33},
+		"in section": {15, 15, 2},
+		"chunk":      {-1, 33},
+		"region":     {-1, 1},
+		"in region":  {31, 1},
+	}
+	got := map[string][]int{
+		"section":    rep.Section,
+		"in section": rep.InSection,
+		"chunk":      rep.Chunk,
+		"region":     rep.Region,
+		"in region":  rep.InRegion,
+	}
+	for k, w := range want {
+		if fmt.Sprint(got[k]) != fmt.Sprint(w) {
+			t.Errorf("%s: got %v, want %v", k, got[k], w)
+		}
+	}
+	if rep.RegionFile != "r.-1.1.mca" || *rep.HeaderIndex != 63 {
+		t.Errorf("region file %s, header index %d", rep.RegionFile, *rep.HeaderIndex)
+	}
+}
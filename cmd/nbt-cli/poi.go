@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func newPOICmd() *cobra.Command {
+	pf := &storageFlags{kind: "poi"}
+	cmd := &cobra.Command{
+		Use:   "poi",
+		Short: "Inspect and clean point-of-interest records in poi region files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	pf.bind(cmd)
+
+	cmd.AddCommand(
+		newPOIListCmd(pf),
+		newPOIDeleteCmd(pf),
+		newPOIPruneCmd(pf),
+	)
+
+	return cmd
+}
+
+func newPOIListCmd(pf *storageFlags) *cobra.Command {
+	var (
+		types  []string
+		format string
+		bf     boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List POI records with their type, position and free tickets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPOIList(cmd.Context(), pf, &bf, types, format)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&types, "type", nil, "Only list these POI types (e.g. minecraft:home); repeatable")
+	cmd.Flags().StringVar(&format, "format", "csv", "Output format: csv or json")
+	bf.bind(cmd)
+
+	return cmd
+}
+
+func newPOIDeleteCmd(pf *storageFlags) *cobra.Command {
+	var (
+		pos   string
+		types []string
+		bf    boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the POI records at a position, in a box or of given types",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPOIDelete(cmd.Context(), pf, &bf, pos, types)
+		},
+	}
+
+	cmd.Flags().StringVar(&pos, "pos", "", "Block position of the record as x,y,z")
+	cmd.Flags().StringSliceVar(&types, "type", nil, "Only delete these POI types; repeatable")
+	bf.bind(cmd)
+	cmd.MarkFlagsMutuallyExclusive("pos", "from")
+	cmd.MarkFlagsOneRequired("pos", "from", "type")
+
+	return cmd
+}
+
+func newPOIPruneCmd(pf *storageFlags) *cobra.Command {
+	var (
+		regionDir string
+		types     []string
+		bf        boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete POI records whose block no longer exists in the terrain",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPOIPrune(cmd.Context(), pf, &bf, regionDir, types)
+		},
+	}
+
+	cmd.Flags().StringVar(&regionDir, "region-dir", "", "Terrain region directory (default: the --world dimension's, or region next to the poi directory)")
+	cmd.Flags().StringSliceVar(&types, "type", nil, "Only check these POI types; repeatable")
+	bf.bind(cmd)
+
+	return cmd
+}
+
+// namespacedSet returns the ids with the minecraft: namespace added where
+// missing, or nil when ids is empty.
+func namespacedSet(types []string) map[string]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	out := make(map[string]bool, len(types))
+	for _, t := range types {
+		if !strings.Contains(t, ":") {
+			t = "minecraft:" + t
+		}
+		out[t] = true
+	}
+	return out
+}
+
+// poiSelected reports whether p is of one of types (all when nil) and lies
+// inside clip (anywhere when nil).
+func poiSelected(p chunkedit.POI, types map[string]bool, clip *coords.Box) bool {
+	if types != nil && !types[p.Type] {
+		return false
+	}
+	return clip == nil || clip.Contains(p.X, p.Y, p.Z)
+}
+
+type poiRow struct {
+	chunkedit.POI
+	ChunkX int `json:"chunk_x"`
+	ChunkZ int `json:"chunk_z"`
+}
+
+func runPOIList(ctx context.Context, pf *storageFlags, bf *boxFlags, types []string, format string) error {
+	if format != "csv" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want csv or json)", format)
+	}
+	cf, box, err := storageScope(pf, bf)
+	if err != nil {
+		return err
+	}
+	want := namespacedSet(types)
+
+	rows := []poiRow{}
+	st, err := visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		for _, p := range chunkedit.ListPOIs(chunk) {
+			if poiSelected(p, want, clip) {
+				rows = append(rows, poiRow{POI: p, ChunkX: cx, ChunkZ: cz})
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "list poi: %w", err)
+	}
+	// Regions are scanned in parallel; list in chunk order.
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.ChunkX != b.ChunkX {
+			return a.ChunkX < b.ChunkX
+		}
+		return a.ChunkZ < b.ChunkZ
+	})
+
+	if format == "json" {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return exitErrorf(1, "encode poi: %w", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"type", "x", "y", "z", "free_tickets", "chunk_x", "chunk_z"})
+		for _, r := range rows {
+			_ = w.Write([]string{
+				r.Type, strconv.Itoa(r.X), strconv.Itoa(r.Y), strconv.Itoa(r.Z),
+				strconv.Itoa(r.FreeTickets), strconv.Itoa(r.ChunkX), strconv.Itoa(r.ChunkZ),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return exitErrorf(1, "write poi: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "records: %d, chunks scanned: %d\n", len(rows), st.Chunks)
+
+	return nil
+}
+
+func runPOIDelete(ctx context.Context, pf *storageFlags, bf *boxFlags, pos string, types []string) error {
+	if pos != "" {
+		if _, _, _, err := parseBlockPos(pos); err != nil {
+			return exitErrorf(1, "--pos: %w", err)
+		}
+		bf.from, bf.to = pos, pos
+	}
+	cf, box, err := storageScope(pf, bf)
+	if err != nil {
+		return err
+	}
+	want := namespacedSet(types)
+
+	removed := map[chunkCountKey]int{}
+	total := 0
+	st, err := visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		n := chunkedit.DeletePOIs(chunk, func(p chunkedit.POI) bool {
+			if !poiSelected(p, want, clip) {
+				return false
+			}
+			removed[chunkCountKey{cx, cz, p.Type}]++
+			return true
+		})
+		total += n
+		return n > 0, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "delete poi: %w", err)
+	}
+	if pos != "" && total == 0 {
+		return exitErrorf(2, "no POI record at %s", pos)
+	}
+	if err := writeChunkCounts(removed, "type"); err != nil {
+		return exitErrorf(1, "write report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "records removed: %d, chunks scanned: %d, chunks written: %d\n", total, st.Chunks, st.Written)
+
+	return nil
+}
+
+func runPOIPrune(ctx context.Context, pf *storageFlags, bf *boxFlags, regionDir string, types []string) error {
+	cf, box, err := storageScope(pf, bf)
+	if err != nil {
+		return err
+	}
+	if regionDir == "" {
+		switch {
+		case pf.dirs.Region != "":
+			regionDir = pf.dirs.Region
+		case cf.regionDir != "":
+			regionDir = filepath.Join(filepath.Dir(filepath.Clean(cf.regionDir)), "region")
+		default:
+			return exitErrorf(1, "--region-dir is required with --region-file")
+		}
+	}
+	want := namespacedSet(types)
+
+	// Each terrain chunk is read once, so only the region handles are
+	// cached, not the decoded chunks.
+	terrain := anvil.NewSession(regionDir)
+	defer terrain.Close()
+	loadTerrain := func(cx, cz int) (map[string]any, error) {
+		r, err := terrain.Region(cx, cz)
+		if err != nil {
+			return nil, err
+		}
+		lx, lz := coords.InRegionChunkIndex(cx, cz)
+		return r.ReadChunkNBT(lx, lz)
+	}
+
+	removed := map[chunkCountKey]int{}
+	var total, unknown, noTerrain int
+	st, err := visitChunks(ctx, cf, box, func(chunk map[string]any, cx, cz int, clip *coords.Box) (bool, error) {
+		if len(chunkedit.ListPOIs(chunk)) == 0 {
+			return false, nil
+		}
+		blocks, err := loadTerrain(cx, cz)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, anvil.ErrChunkNotPresent) {
+			noTerrain++
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("terrain chunk %d,%d: %w", cx, cz, err)
+		}
+		var blockErr error
+		n := chunkedit.DeletePOIs(chunk, func(p chunkedit.POI) bool {
+			if !poiSelected(p, want, clip) || blockErr != nil {
+				return false
+			}
+			bs, err := chunkedit.GetBlockState(blocks, p.X, p.Y, p.Z)
+			if err != nil {
+				blockErr = err
+				return false
+			}
+			match, known := chunkedit.POIBlockMatches(p.Type, bs.Name)
+			if !known {
+				unknown++
+				return false
+			}
+			if match {
+				return false
+			}
+			removed[chunkCountKey{cx, cz, p.Type}]++
+			return true
+		})
+		if blockErr != nil {
+			return false, blockErr
+		}
+		total += n
+		return n > 0, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "prune poi: %w", err)
+	}
+	if err := writeChunkCounts(removed, "type"); err != nil {
+		return exitErrorf(1, "write report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "records removed: %d, unknown types kept: %d, chunks without terrain: %d, chunks scanned: %d, chunks written: %d\n",
+		total, unknown, noTerrain, st.Chunks, st.Written)
+
+	return nil
+}
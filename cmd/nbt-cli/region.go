@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/backup"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/scan"
+)
+
+func newRegionCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "region",
+		Short: "Maintain whole region files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bindRegion(cmd)
+
+	cmd.AddCommand(
+		newRegionRecompressCmd(cf),
+		newRegionInfoCmd(cf),
+	)
+
+	return cmd
+}
+
+func newRegionRecompressCmd(cf *commonFlags) *cobra.Command {
+	var (
+		wf      writeFlags
+		history bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "recompress",
+		Short: "Rewrite every chunk with another compression and compact the region files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRegionRecompress(cmd.Context(), cf, wf, history)
+		},
+	}
+
+	cmd.Flags().StringVar(&wf.compression, "to", "", "Target compression: zlib, gzip, lz4 or none")
+	cmd.Flags().IntVar(&wf.level, "level", 0, "zlib or gzip level from 1 (fastest) to 9 (smallest); 0 for the default")
+	cmd.Flags().BoolVar(&wf.verify, "verify", false, "Read each new region file back and keep the original if any chunk differs")
+	cmd.Flags().BoolVar(&history, "history", false, "Also copy each region file into the undo journal, which doubles its disk use")
+	cf.scan.bind(cmd)
+	cf.write.backup.bind(cmd)
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// runRegionRecompress rewrites whole region files. Copying them into the
+// journal costs as much disk as the world itself, so it only happens with
+// --history; --backup copies them as for any other command.
+func runRegionRecompress(ctx context.Context, cf *commonFlags, wf writeFlags, history bool) error {
+	opts, err := wf.options()
+	if err != nil {
+		return exitError(1, err)
+	}
+	if !history && !cf.write.backup.noHistory && !dryRun {
+		fmt.Fprintln(os.Stderr, "history: not recorded for whole region files; use --history to make this undoable")
+	}
+	cf.write.backup.noHistory = cf.write.backup.noHistory || !history
+	s, err := cf.session()
+	if err != nil {
+		return exitError(1, err)
+	}
+	paths, err := s.RegionFiles()
+	s.Close()
+	if err != nil {
+		return exitError(1, err)
+	}
+	journal, bak, err := cf.backupSets()
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	var (
+		mu            sync.Mutex
+		before, after int64
+	)
+	st, err := scan.Regions(ctx, paths, cf.scan.options(), func(ctx context.Context, path string) (anvil.Stats, error) {
+		if err := ctx.Err(); err != nil {
+			return anvil.Stats{}, err
+		}
+		rs, err := recompressFile(path, opts, journal, bak)
+		if err != nil {
+			return anvil.Stats{}, err
+		}
+		mu.Lock()
+		before += rs.Before
+		after += rs.After
+		mu.Unlock()
+		return anvil.Stats{Chunks: rs.Chunks, Written: rs.Chunks}, nil
+	})
+	if err != nil {
+		return exitErrorf(1, "recompress: %w", err)
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "regions: %d, chunks written: %d, size: %d -> %d bytes (%s)\n", len(paths), st.Written, before, after, opts.Compression)
+
+	return nil
+}
+
+// recompressFile rewrites one region file, saving it into the journal and
+// backup sets first. With --dry-run it only reports the new size.
+func recompressFile(path string, opts anvil.WriteOptions, journal, bak *backup.Set) (anvil.RewriteStats, error) {
+	if dryRun {
+		rs, err := anvil.PackRegion(path, opts)
+		if err != nil {
+			return rs, fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("region %s: %d -> %d bytes\n", path, rs.Before, rs.After)
+		return rs, nil
+	}
+	for _, set := range []*backup.Set{journal, bak} {
+		if set != nil {
+			if err := set.SaveFile(path); err != nil {
+				return anvil.RewriteStats{}, err
+			}
+		}
+	}
+	rs, err := anvil.RewriteRegion(path, opts)
+	if err != nil {
+		return rs, fmt.Errorf("%s: %w", path, err)
+	}
+	if journal != nil {
+		if err := journal.SaveWrittenFile(path); err != nil {
+			return rs, err
+		}
+	}
+	return rs, nil
+}
+
+func newRegionInfoCmd(cf *commonFlags) *cobra.Command {
+	var (
+		top    int
+		chunks bool
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "Report chunk sizes, compression, timestamps, free sectors and data versions of region files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRegionInfo(cf, top, chunks, format)
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 5, "Number of largest chunks to list")
+	cmd.Flags().BoolVar(&chunks, "chunks", false, "List every chunk in text output; JSON always does")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type chunkInfoRow struct {
+	ChunkX      int    `json:"chunk_x"`
+	ChunkZ      int    `json:"chunk_z"`
+	Bytes       int    `json:"bytes"`
+	Sectors     int    `json:"sectors"`
+	Compression string `json:"compression"`
+	Timestamp   uint32 `json:"timestamp"`
+	DataVersion int32  `json:"data_version"`
+	Invalid     bool   `json:"invalid,omitempty"`
+}
+
+// regionReport summarises one region file, or with Regions set, all of
+// them.
+type regionReport struct {
+	Path         string         `json:"path,omitempty"`
+	Regions      int            `json:"regions,omitempty"`
+	Chunks       int            `json:"chunks"`
+	Bytes        int64          `json:"bytes"`
+	Sectors      int64          `json:"sectors"`
+	FreeSectors  int64          `json:"free_sectors"`
+	Compression  map[string]int `json:"compression"`
+	DataVersions map[int32]int  `json:"data_versions"`
+	// FirstWrite and LastWrite bound the chunk timestamps, ignoring zeros.
+	FirstWrite uint32         `json:"first_write,omitempty"`
+	LastWrite  uint32         `json:"last_write,omitempty"`
+	Invalid    int            `json:"invalid,omitempty"`
+	Largest    []chunkInfoRow `json:"largest"`
+	ChunkList  []chunkInfoRow `json:"chunk_list,omitempty"`
+}
+
+func newRegionReport() *regionReport {
+	return &regionReport{Compression: map[string]int{}, DataVersions: map[int32]int{}}
+}
+
+// add folds the chunks of one file into rep.
+func (rep *regionReport) add(info anvil.RegionInfo, rows []chunkInfoRow) {
+	rep.Bytes += info.Size
+	rep.Sectors += info.Sectors
+	rep.FreeSectors += info.FreeSectors
+	for _, c := range rows {
+		rep.Chunks++
+		if c.Invalid {
+			rep.Invalid++
+			continue
+		}
+		rep.Compression[c.Compression]++
+		rep.DataVersions[c.DataVersion]++
+		if c.Timestamp != 0 {
+			if rep.FirstWrite == 0 || c.Timestamp < rep.FirstWrite {
+				rep.FirstWrite = c.Timestamp
+			}
+			rep.LastWrite = max(rep.LastWrite, c.Timestamp)
+		}
+	}
+	rep.ChunkList = append(rep.ChunkList, rows...)
+}
+
+// finish picks the top largest chunks.
+func (rep *regionReport) finish(top int) {
+	largest := append([]chunkInfoRow(nil), rep.ChunkList...)
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Bytes > largest[j].Bytes })
+	rep.Largest = largest[:min(top, len(largest))]
+}
+
+func runRegionInfo(cf *commonFlags, top int, chunks bool, format string) error {
+	if format != "text" && format != "json" {
+		return exitErrorf(1, "unknown format %q (want text or json)", format)
+	}
+	if top < 0 {
+		return exitErrorf(1, "--top must not be negative")
+	}
+	s, err := cf.session()
+	if err != nil {
+		return exitError(1, err)
+	}
+	paths, err := s.RegionFiles()
+	s.Close()
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	total := newRegionReport()
+	total.Regions = len(paths)
+	var reports []*regionReport
+	for _, path := range paths {
+		r, err := anvil.OpenRegionFile(path)
+		if err != nil {
+			return exitError(1, err)
+		}
+		info, err := r.Info()
+		r.Close()
+		if err != nil {
+			return exitErrorf(1, "%s: %w", path, err)
+		}
+		rows := chunkInfoRows(path, info)
+		rep := newRegionReport()
+		rep.Path = path
+		rep.add(info, rows)
+		rep.finish(top)
+		reports = append(reports, rep)
+		total.add(info, rows)
+	}
+	total.finish(top)
+
+	if format == "json" {
+		total.ChunkList = nil
+		out, err := json.MarshalIndent(struct {
+			Regions []*regionReport `json:"regions"`
+			Total   *regionReport   `json:"total"`
+		}{reports, total}, "", "  ")
+		if err != nil {
+			return exitError(1, err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, rep := range reports {
+		printRegionReport(filepath.Base(rep.Path), rep, chunks)
+	}
+	if len(reports) > 1 {
+		printRegionReport(fmt.Sprintf("total (%d regions)", len(reports)), total, false)
+	}
+	return nil
+}
+
+// chunkInfoRows numbers the chunks of a region file after its name.
+func chunkInfoRows(path string, info anvil.RegionInfo) []chunkInfoRow {
+	rx, rz, _ := coords.ParseRegionFileName(filepath.Base(path))
+	rows := make([]chunkInfoRow, len(info.Chunks))
+	for i, c := range info.Chunks {
+		rows[i] = chunkInfoRow{
+			ChunkX:      rx*32 + c.X,
+			ChunkZ:      rz*32 + c.Z,
+			Bytes:       c.Size,
+			Sectors:     c.Sectors,
+			Compression: c.Compression.String(),
+			Timestamp:   c.Timestamp,
+			DataVersion: c.DataVersion,
+			Invalid:     c.Invalid,
+		}
+	}
+	return rows
+}
+
+func printRegionReport(name string, rep *regionReport, chunks bool) {
+	fmt.Printf("%s: %d chunks, %d bytes, %d sectors, %d free\n", name, rep.Chunks, rep.Bytes, rep.Sectors, rep.FreeSectors)
+	if rep.Chunks == 0 {
+		return
+	}
+	fmt.Printf("  compression: %s\n", countList(rep.Compression))
+	versions := make(map[string]int, len(rep.DataVersions))
+	for v, n := range rep.DataVersions {
+		name := fmt.Sprint(v)
+		if v == 0 {
+			name = "none"
+		}
+		versions[name] = n
+	}
+	fmt.Printf("  data versions: %s\n", countList(versions))
+	if rep.FirstWrite != 0 {
+		fmt.Printf("  written: %s .. %s\n", formatStamp(rep.FirstWrite), formatStamp(rep.LastWrite))
+	}
+	if rep.Invalid > 0 {
+		fmt.Printf("  invalid records: %d\n", rep.Invalid)
+	}
+	if len(rep.Largest) > 0 {
+		fmt.Println("  largest:")
+		for _, c := range rep.Largest {
+			printChunkInfo(c)
+		}
+	}
+	if chunks {
+		fmt.Println("  chunks:")
+		for _, c := range rep.ChunkList {
+			printChunkInfo(c)
+		}
+	}
+}
+
+func printChunkInfo(c chunkInfoRow) {
+	if c.Invalid {
+		fmt.Printf("    chunk %d,%d: invalid record in %d sectors\n", c.ChunkX, c.ChunkZ, c.Sectors)
+		return
+	}
+	fmt.Printf("    chunk %d,%d: %d bytes, %d sectors, %s, data version %d, written %s\n", c.ChunkX, c.ChunkZ, c.Bytes, c.Sectors, c.Compression, c.DataVersion, formatStamp(c.Timestamp))
+}
+
+// countList renders counts as "a (3), b (1)", largest first.
+func countList(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s (%d)", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatStamp(ts uint32) string {
+	if ts == 0 {
+		return "unknown"
+	}
+	return time.Unix(int64(ts), 0).Format("2006-01-02 15:04:05")
+}
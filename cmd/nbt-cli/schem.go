@@ -0,0 +1,463 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+	"github.com/Zeptile/nbt-cli/pkg/schematic"
+)
+
+func newSchemCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "schem",
+		Short: "Export and paste Sponge schematics (.schem) and paste legacy MCEdit .schematic files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bindRegion(cmd)
+	cf.write.bind(cmd)
+
+	cmd.AddCommand(
+		newSchemExportCmd(cf),
+		newSchemPasteCmd(cf),
+	)
+
+	return cmd
+}
+
+func newSchemExportCmd(cf *commonFlags) *cobra.Command {
+	var (
+		out         string
+		version     int
+		entities    bool
+		entitiesDir string
+		bf          boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the blocks, block entities and entities of a box to a .schem file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSchemExport(cf, &bf, out, version, entities, entitiesDir)
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Path of the .schem file to write")
+	cmd.Flags().IntVar(&version, "schem-version", 3, "Sponge schematic version to write (2 or 3)")
+	cmd.Flags().BoolVar(&entities, "entities", false, "Also export entities from the entities directory")
+	cmd.Flags().StringVar(&entitiesDir, "entities-dir", "", "Entities directory (default: ../entities next to --region-dir)")
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("out")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func runSchemExport(cf *commonFlags, bf *boxFlags, out string, version int, entities bool, entitiesDir string) error {
+	box, err := bf.box()
+	if err != nil {
+		return exitError(1, err)
+	}
+	if version != 2 && version != 3 {
+		return exitErrorf(1, "unsupported --schem-version %d (want 2 or 3)", version)
+	}
+
+	s, st, err := exportSchematic(cf, box)
+	if err != nil {
+		return exitErrorf(1, "export blocks: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks in box")
+	}
+
+	if entities {
+		dir, err := entitiesDirFor(cf, entitiesDir)
+		if err != nil {
+			return exitError(1, err)
+		}
+		if err := exportEntities(s, dir, box); err != nil {
+			return exitErrorf(1, "export entities: %w", err)
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return exitErrorf(1, "create schematic: %w", err)
+	}
+	if err := schematic.WriteSponge(f, s, version); err != nil {
+		f.Close()
+		return exitErrorf(1, "write schematic: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return exitErrorf(1, "write schematic: %w", err)
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "size: %dx%dx%d, block entities: %d, entities: %d, chunks missing: %d\n",
+		s.Width, s.Height, s.Length, len(s.BlockEntities), len(s.Entities), st.Missing)
+
+	return nil
+}
+
+// exportSchematic copies the blocks and block entities of box into a new
+// schematic whose origin is the box minimum. Missing chunks export as air.
+func exportSchematic(cf *commonFlags, box coords.Box) (*schematic.Schematic, boxStats, error) {
+	s := schematic.New(box.MaxX-box.MinX+1, box.MaxY-box.MinY+1, box.MaxZ-box.MinZ+1)
+	s.Offset = [3]int{box.MinX, box.MinY, box.MinZ}
+	s.PaletteID(chunkedit.BlockState{Name: "minecraft:air"})
+
+	st, err := editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		if dv, ok := chunk["DataVersion"].(int32); ok && s.DataVersion == 0 {
+			s.DataVersion = int(dv)
+		}
+		err := chunkedit.VisitBlocks(chunk, clip, func(x, y, z int, bs chunkedit.BlockState) {
+			s.Set(x-box.MinX, y-box.MinY, z-box.MinZ, bs)
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, ent := range chunkedit.ListBlockEntities(chunk) {
+			x, y, z, ok := chunkedit.BlockEntityPos(ent)
+			if !ok || !clip.Contains(x, y, z) {
+				continue
+			}
+			id, _ := ent["id"].(string)
+			s.BlockEntities = append(s.BlockEntities, schematic.BlockEntity{
+				X: x - box.MinX, Y: y - box.MinY, Z: z - box.MinZ,
+				ID:   id,
+				Data: withoutKeys(ent, "x", "y", "z", "id", "keepPacked"),
+			})
+		}
+		return false, nil
+	})
+	return s, st, err
+}
+
+// entitiesDirFor returns dir, or the entities directory of the --world
+// dimension or next to --region-dir when dir is empty.
+func entitiesDirFor(cf *commonFlags, dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if cf.dirs.Entities != "" {
+		return cf.dirs.Entities, nil
+	}
+	if cf.regionDir == "" {
+		return "", errors.New("--entities-dir is required with --region-file")
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(cf.regionDir)), "entities"), nil
+}
+
+func exportEntities(s *schematic.Schematic, entitiesDir string, box coords.Box) error {
+	ecf := &commonFlags{regionDir: entitiesDir}
+	_, err := editBox(ecf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		list, _ := chunk["Entities"].([]any)
+		for _, v := range list {
+			ent, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			pos, ok := ent["Pos"].([]any)
+			if !ok || len(pos) != 3 {
+				continue
+			}
+			x, _ := pos[0].(float64)
+			y, _ := pos[1].(float64)
+			z, _ := pos[2].(float64)
+			if x < float64(box.MinX) || x >= float64(box.MaxX+1) ||
+				y < float64(box.MinY) || y >= float64(box.MaxY+1) ||
+				z < float64(box.MinZ) || z >= float64(box.MaxZ+1) {
+				continue
+			}
+			id, _ := ent["id"].(string)
+			s.Entities = append(s.Entities, schematic.Entity{
+				X: x - float64(box.MinX), Y: y - float64(box.MinY), Z: z - float64(box.MinZ),
+				ID:   id,
+				Data: withoutKeys(ent, "Pos", "id"),
+			})
+		}
+		return false, nil
+	})
+	return err
+}
+
+// pasteEntityFlags select whether a paste also writes the entities of the
+// schematic, and into which entities directory.
+type pasteEntityFlags struct {
+	enabled bool
+	dir     string
+}
+
+func (ef *pasteEntityFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&ef.enabled, "entities", false, "Also place entities into the entities directory")
+	cmd.Flags().StringVar(&ef.dir, "entities-dir", "", "Entities directory (default: ../entities next to --region-dir)")
+}
+
+// placeEntities appends the entities of s, transformed and moved to
+// (ax, ay, az), to the chunks of the entities directory dir. Each entity
+// and passenger gets a new UUID, and hanging entities a new TileX/Y/Z.
+// Entities whose chunk is not stored are skipped; it returns how many were
+// placed.
+func placeEntities(cf *commonFlags, dir string, s *schematic.Schematic, t schematic.Transform, ax, ay, az int) (int, error) {
+	byChunk := map[[2]int][]any{}
+	var box coords.Box
+	for i, e := range s.Entities {
+		e = t.Entity(e, s.Width, s.Length)
+		x, y, z := float64(ax)+e.X, float64(ay)+e.Y, float64(az)+e.Z
+		bx, by, bz := int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z))
+		ent := e.Data
+		ent["id"] = e.ID
+		ent["Pos"] = []any{x, y, z}
+		if _, ok := ent["TileX"]; ok {
+			ent["TileX"], ent["TileY"], ent["TileZ"] = int32(bx), int32(by), int32(bz)
+		}
+		newUUIDs(ent)
+		cx, cz := coords.WorldToChunkXZ(bx, bz)
+		byChunk[[2]int{cx, cz}] = append(byChunk[[2]int{cx, cz}], ent)
+		if i == 0 {
+			box = coords.NewBox(bx, by, bz, bx, by, bz)
+		}
+		box.MinX, box.MaxX = min(box.MinX, bx), max(box.MaxX, bx)
+		box.MinZ, box.MaxZ = min(box.MinZ, bz), max(box.MaxZ, bz)
+	}
+	if len(byChunk) == 0 {
+		return 0, nil
+	}
+
+	ecf := &commonFlags{regionDir: dir, write: cf.write}
+	placed := 0
+	_, err := editBox(ecf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		add, ok := byChunk[[2]int{cx, cz}]
+		if !ok {
+			return false, nil
+		}
+		list, _ := chunk["Entities"].([]any)
+		chunk["Entities"] = append(list, add...)
+		placed += len(add)
+		return true, nil
+	})
+	return placed, err
+}
+
+// newUUIDs gives ent and its passengers fresh UUIDs, so a placed copy does
+// not clash with the entities it was exported from.
+func newUUIDs(ent map[string]any) {
+	ent["UUID"] = chunkedit.NewUUID()
+	delete(ent, "UUIDMost")
+	delete(ent, "UUIDLeast")
+	passengers, _ := ent["Passengers"].([]any)
+	for _, p := range passengers {
+		if m, ok := p.(map[string]any); ok {
+			newUUIDs(m)
+		}
+	}
+}
+
+// reportUnplaced prints how many entities of a pasted schematic were left
+// out.
+func reportUnplaced(total, placed int, enabled bool) {
+	switch {
+	case placed == total:
+	case !enabled:
+		fmt.Fprintf(os.Stderr, "entities not placed: %d (use --entities)\n", total)
+	default:
+		fmt.Fprintf(os.Stderr, "entities not placed: %d (entity chunk not stored)\n", total-placed)
+	}
+}
+
+// reportLegacy prints what a legacy .schematic import could not translate.
+func reportLegacy(r *schematic.LegacyReport) {
+	keys := make([]string, 0, len(r.Unknown))
+	for k := range r.Unknown {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(os.Stderr, "unknown legacy block %s: %d imported as air\n", k, r.Unknown[k])
+	}
+	if r.DroppedTileEntities > 0 {
+		fmt.Fprintf(os.Stderr, "tile entities dropped: %d\n", r.DroppedTileEntities)
+	}
+	if r.DroppedEntities > 0 {
+		fmt.Fprintf(os.Stderr, "entities not imported: %d\n", r.DroppedEntities)
+	}
+}
+
+func newSchemPasteCmd(cf *commonFlags) *cobra.Command {
+	var (
+		file   string
+		at     string
+		rotate int
+		mirror string
+		noAir  bool
+		af     afterEditFlags
+		ef     pasteEntityFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "paste",
+		Short: "Paste the blocks and block entities of a .schem or legacy .schematic file into the world",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSchemPaste(cf, &af, &ef, file, at, rotate, mirror, noAir)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "Path of the .schem or MCEdit .schematic file to paste")
+	cmd.Flags().StringVar(&at, "at", "", "World position of the schematic's minimum corner as x,y,z")
+	cmd.Flags().IntVar(&rotate, "rotate", 0, "Clockwise rotation around Y: 0, 90, 180 or 270")
+	cmd.Flags().StringVar(&mirror, "mirror", "", "Mirror before rotating: x (east/west), z (north/south) or xz")
+	cmd.Flags().BoolVar(&noAir, "no-air", false, "Skip air blocks so existing blocks show through")
+	af.bind(cmd)
+	ef.bind(cmd)
+	_ = cmd.MarkFlagRequired("file")
+	_ = cmd.MarkFlagRequired("at")
+
+	return cmd
+}
+
+func runSchemPaste(cf *commonFlags, af *afterEditFlags, ef *pasteEntityFlags, file, at string, rotate int, mirror string, noAir bool) error {
+	ax, ay, az, err := parseBlockPos(at)
+	if err != nil {
+		return exitErrorf(1, "--at: %w", err)
+	}
+	t, err := schematic.ParseTransform(rotate, mirror)
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return exitErrorf(1, "open schematic: %w", err)
+	}
+	s, legacy, err := schematic.Read(f)
+	f.Close()
+	if err != nil {
+		return exitErrorf(1, "read schematic: %w", err)
+	}
+	if legacy != nil {
+		reportLegacy(legacy)
+	}
+	entitiesDir := ""
+	if ef.enabled && len(s.Entities) > 0 {
+		if entitiesDir, err = entitiesDirFor(cf, ef.dir); err != nil {
+			return exitError(1, err)
+		}
+	}
+
+	skip := func(bs chunkedit.BlockState) bool { return noAir && bs.IsAir() }
+	placed, st, err := pasteSchematic(cf, af, s, t, ax, ay, az, skip)
+	if err != nil {
+		return exitErrorf(editExitCode(err), "paste: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks under the paste area")
+	}
+
+	entities := 0
+	if entitiesDir != "" {
+		if entities, err = placeEntities(cf, entitiesDir, s, t, ax, ay, az); err != nil {
+			return exitErrorf(1, "paste entities: %w", err)
+		}
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "blocks changed: %d, block entities: %d, entities: %d, chunks written: %d, chunks missing: %d\n",
+		placed.blocks, placed.blockEntities, entities, st.Written, st.Missing)
+	reportUnplaced(len(s.Entities), entities, ef.enabled)
+
+	return nil
+}
+
+type pasteStats struct {
+	blocks        int
+	blockEntities int
+}
+
+// pasteSchematic writes s into the world with its transformed minimum
+// corner at (ax, ay, az), leaving positions whose block skip reports
+// untouched. Block entities from the schematic replace any existing block
+// entity at their target position.
+func pasteSchematic(cf *commonFlags, af *afterEditFlags, s *schematic.Schematic, t schematic.Transform, ax, ay, az int, skip func(chunkedit.BlockState) bool) (pasteStats, boxStats, error) {
+	var ps pasteStats
+	w, l := t.Size(s.Width, s.Length)
+	box := coords.NewBox(ax, ay, az, ax+w-1, ay+s.Height-1, az+l-1)
+
+	palette := make([]chunkedit.BlockState, len(s.Palette))
+	remap := make([]int, len(s.Palette))
+	for i, bs := range s.Palette {
+		palette[i] = t.State(bs)
+		remap[i] = i
+		if skip(bs) {
+			remap[i] = -1
+		}
+	}
+
+	type placedEntity struct {
+		id   string
+		data map[string]any
+	}
+	entities := map[[3]int]placedEntity{}
+	for _, be := range s.BlockEntities {
+		if !s.Contains(be.X, be.Y, be.Z) {
+			continue
+		}
+		x, z := t.Pos(be.X, be.Z, s.Width, s.Length)
+		entities[[3]int{ax + x, ay + be.Y, az + z}] = placedEntity{id: be.ID, data: be.Data}
+	}
+
+	if err := cf.checkY(nil, box.MinY, box.MaxY); err != nil {
+		return ps, boxStats{}, err
+	}
+	st, err := editBox(cf, box, func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error) {
+		if err := cf.checkY(chunk, clip.MinY, clip.MaxY); err != nil {
+			return false, err
+		}
+		n, err := chunkedit.PlaceBlocks(chunk, clip, palette, func(x, y, z int) int {
+			sx, sz := t.Source(x-ax, z-az, s.Width, s.Length)
+			return remap[s.Blocks[s.Index(sx, y-ay, sz)]]
+		})
+		if err != nil {
+			return false, err
+		}
+		ps.blocks += n
+		placed := 0
+		for pos, pe := range entities {
+			if !clip.Contains(pos[0], pos[1], pos[2]) {
+				continue
+			}
+			chunkedit.DeleteBlockEntity(chunk, pos[0], pos[1], pos[2])
+			chunkedit.CreateOrUpdateBlockEntity(chunk, pos[0], pos[1], pos[2], pe.id, withoutKeys(pe.data))
+			placed++
+		}
+		ps.blockEntities += placed
+		if n == 0 && placed == 0 {
+			return false, nil
+		}
+		return true, af.apply(chunk, cf.dimType)
+	})
+	return ps, st, err
+}
+
+func withoutKeys(m map[string]any, keys ...string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, k := range keys {
+		delete(out, k)
+	}
+	return out
+}
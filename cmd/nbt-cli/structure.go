@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+	"github.com/Zeptile/nbt-cli/pkg/schematic"
+)
+
+func newStructureCmd() *cobra.Command {
+	cf := &commonFlags{}
+	cmd := &cobra.Command{
+		Use:   "structure",
+		Short: "Export and place vanilla structure templates (.nbt)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cf.bindRegion(cmd)
+	cf.write.bind(cmd)
+
+	cmd.AddCommand(
+		newStructureExportCmd(cf),
+		newStructurePlaceCmd(cf),
+	)
+
+	return cmd
+}
+
+func newStructureExportCmd(cf *commonFlags) *cobra.Command {
+	var (
+		out         string
+		entities    bool
+		entitiesDir string
+		bf          boxFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the blocks, block entities and entities of a box as a structure template",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStructureExport(cf, &bf, out, entities, entitiesDir)
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Path of the .nbt file to write")
+	cmd.Flags().BoolVar(&entities, "entities", false, "Also export entities from the entities directory")
+	cmd.Flags().StringVar(&entitiesDir, "entities-dir", "", "Entities directory (default: ../entities next to --region-dir)")
+	bf.bind(cmd)
+	_ = cmd.MarkFlagRequired("out")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+func newStructurePlaceCmd(cf *commonFlags) *cobra.Command {
+	var (
+		file    string
+		at      string
+		rotate  int
+		mirror  string
+		palette int
+		af      afterEditFlags
+		ef      pasteEntityFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "place",
+		Short: "Place a structure template's blocks, block entities and entities into the world",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStructurePlace(cf, &af, &ef, file, at, rotate, mirror, palette)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "Path of the .nbt structure file")
+	cmd.Flags().StringVar(&at, "at", "", "World position of the structure's minimum corner as x,y,z")
+	cmd.Flags().IntVar(&rotate, "rotate", 0, "Clockwise rotation around Y: 0, 90, 180 or 270")
+	cmd.Flags().StringVar(&mirror, "mirror", "", "Mirror before rotating: x (east/west), z (north/south) or xz")
+	cmd.Flags().IntVar(&palette, "palette", 0, "Palette to use for structures with several palettes")
+	af.bind(cmd)
+	ef.bind(cmd)
+	_ = cmd.MarkFlagRequired("file")
+	_ = cmd.MarkFlagRequired("at")
+
+	return cmd
+}
+
+func runStructureExport(cf *commonFlags, bf *boxFlags, out string, entities bool, entitiesDir string) error {
+	box, err := bf.box()
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	s, st, err := exportSchematic(cf, box)
+	if err != nil {
+		return exitErrorf(1, "export blocks: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks in box")
+	}
+
+	if entities {
+		dir, err := entitiesDirFor(cf, entitiesDir)
+		if err != nil {
+			return exitError(1, err)
+		}
+		if err := exportEntities(s, dir, box); err != nil {
+			return exitErrorf(1, "export entities: %w", err)
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return exitErrorf(1, "create structure: %w", err)
+	}
+	if err := schematic.WriteStructure(f, s); err != nil {
+		f.Close()
+		return exitErrorf(1, "write structure: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return exitErrorf(1, "write structure: %w", err)
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "size: %dx%dx%d, block entities: %d, entities: %d, chunks missing: %d\n",
+		s.Width, s.Height, s.Length, len(s.BlockEntities), len(s.Entities), st.Missing)
+
+	return nil
+}
+
+func runStructurePlace(cf *commonFlags, af *afterEditFlags, ef *pasteEntityFlags, file, at string, rotate int, mirror string, palette int) error {
+	ax, ay, az, err := parseBlockPos(at)
+	if err != nil {
+		return exitErrorf(1, "--at: %w", err)
+	}
+	t, err := schematic.ParseTransform(rotate, mirror)
+	if err != nil {
+		return exitError(1, err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return exitErrorf(1, "open structure: %w", err)
+	}
+	s, err := schematic.ReadStructure(f, palette)
+	f.Close()
+	if err != nil {
+		return exitErrorf(1, "read structure: %w", err)
+	}
+	entitiesDir := ""
+	if ef.enabled && len(s.Entities) > 0 {
+		if entitiesDir, err = entitiesDirFor(cf, ef.dir); err != nil {
+			return exitError(1, err)
+		}
+	}
+
+	skip := func(bs chunkedit.BlockState) bool { return bs.Name == schematic.StructureVoid.Name }
+	placed, st, err := pasteSchematic(cf, af, s, t, ax, ay, az, skip)
+	if err != nil {
+		return exitErrorf(editExitCode(err), "place: %w", err)
+	}
+	if st.Chunks == 0 {
+		return exitErrorf(2, "no stored chunks under the placement area")
+	}
+
+	entities := 0
+	if entitiesDir != "" {
+		if entities, err = placeEntities(cf, entitiesDir, s, t, ax, ay, az); err != nil {
+			return exitErrorf(1, "place entities: %w", err)
+		}
+	}
+
+	fmt.Println("ok")
+	fmt.Fprintf(os.Stderr, "blocks changed: %d, block entities: %d, entities: %d, chunks written: %d, chunks missing: %d\n",
+		placed.blocks, placed.blockEntities, entities, st.Written, st.Missing)
+	reportUnplaced(len(s.Entities), entities, ef.enabled)
+
+	return nil
+}
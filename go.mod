@@ -1,4 +1,4 @@
-module nbt-cli
+module github.com/Zeptile/nbt-cli
 
 go 1.22.2
 
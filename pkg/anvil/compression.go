@@ -0,0 +1,144 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compression is a chunk compression scheme, numbered as in the type byte
+// of a chunk record.
+type Compression byte
+
+// Compression schemes supported by region files.
+const (
+	CompressionGzip Compression = 1
+	CompressionZlib Compression = 2
+	CompressionNone Compression = 3
+	CompressionLZ4  Compression = 4
+)
+
+var compressionNames = map[Compression]string{
+	CompressionGzip: "gzip",
+	CompressionZlib: "zlib",
+	CompressionNone: "none",
+	CompressionLZ4:  "lz4",
+}
+
+// String returns the name used by --compression, such as "zlib".
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("type %d", byte(c))
+}
+
+// ParseCompression parses gzip, zlib, none or lz4.
+func ParseCompression(name string) (Compression, error) {
+	for c, n := range compressionNames {
+		if strings.EqualFold(name, n) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression %q (want zlib, gzip, lz4 or none)", name)
+}
+
+// WriteOptions select how chunks are compressed when written. The zero value
+// writes zlib at the default level, as the game does.
+type WriteOptions struct {
+	// Compression defaults to zlib.
+	Compression Compression
+	// Level applies to zlib and gzip: 1 (fastest) to 9 (smallest), zero
+	// for the library default.
+	Level int
+	// Verify reads every chunk back after writing it and compares it with
+	// what was written; see ErrVerify.
+	Verify bool
+}
+
+// Validate reports an unknown compression or an out-of-range level.
+func (o WriteOptions) Validate() error {
+	c := o.compression()
+	if _, ok := compressionNames[c]; !ok {
+		return fmt.Errorf("unknown compression %s", c)
+	}
+	if o.Level == 0 {
+		return nil
+	}
+	if c != CompressionZlib && c != CompressionGzip {
+		return fmt.Errorf("compression %s takes no level", c)
+	}
+	if o.Level < 1 || o.Level > 9 {
+		return fmt.Errorf("compression level %d out of range 1..9", o.Level)
+	}
+	return nil
+}
+
+func (o WriteOptions) compression() Compression {
+	if o.Compression == 0 {
+		return CompressionZlib
+	}
+	return o.Compression
+}
+
+func (o WriteOptions) level() int {
+	if o.Level == 0 {
+		return zlib.DefaultCompression
+	}
+	return o.Level
+}
+
+// compress returns raw compressed as o selects, with its record type byte.
+func (o WriteOptions) compress(raw []byte) (Compression, []byte, error) {
+	if err := o.Validate(); err != nil {
+		return 0, nil, err
+	}
+	c := o.compression()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch c {
+	case CompressionNone:
+		return c, raw, nil
+	case CompressionLZ4:
+		return c, lz4Encode(raw), nil
+	case CompressionGzip:
+		w, err = gzip.NewWriterLevel(&buf, o.level())
+	default:
+		w, err = zlib.NewWriterLevel(&buf, o.level())
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := w.Write(raw); err != nil {
+		w.Close()
+		return 0, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, nil, err
+	}
+	return c, buf.Bytes(), nil
+}
+
+// decompress returns a reader over the payload of a chunk record of type c.
+func decompress(c Compression, comp []byte) (io.ReadCloser, error) {
+	switch c {
+	case CompressionZlib:
+		return zlib.NewReader(bytes.NewReader(comp))
+	case CompressionGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(comp))
+		if err != nil {
+			return nil, err
+		}
+		return gr, nil
+	case CompressionNone:
+		return io.NopCloser(bytes.NewReader(comp)), nil
+	case CompressionLZ4:
+		return lz4NewReader(comp)
+	default:
+		return nil, fmt.Errorf("unsupported compression type %d", byte(c))
+	}
+}
@@ -0,0 +1,191 @@
+package anvil
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestXXH32(t *testing.T) {
+	cases := []struct {
+		in   string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0x02CC5D05},
+		{"a", 0, 0x550D7456},
+		{"abc", 0, 0x32D153FF},
+		{"Nobody inspects the spammish repetition", 0, 0xE2293B2F},
+	}
+	for _, c := range cases {
+		if got := xxh32([]byte(c.in), c.seed); got != c.want {
+			t.Errorf("xxh32(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+	}
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 70000)
+	rng.Read(random)
+	repeated := bytes.Repeat([]byte("minecraft:stone\x00"), 20000)
+	inputs := map[string][]byte{
+		"empty":    nil,
+		"short":    []byte("hello"),
+		"random":   random,
+		"repeated": repeated,
+		"run":      make([]byte, 1000),
+	}
+	for name, in := range inputs {
+		enc := lz4Encode(in)
+		out, err := lz4Decode(enc)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if !bytes.Equal(out, in) {
+			t.Fatalf("%s: round trip mismatch", name)
+		}
+	}
+	if enc := lz4Encode(repeated); len(enc) > len(repeated)/10 {
+		t.Errorf("repeated input compressed to %d of %d bytes", len(enc), len(repeated))
+	}
+
+	enc := lz4Encode(repeated)
+	enc[len(enc)/2] ^= 0xFF
+	if _, err := lz4Decode(enc); err == nil {
+		t.Error("corrupt stream decoded without error")
+	}
+}
+
+// testdata/chunk_lz4.bin is a type 4 chunk record in the framing of
+// lz4-java's LZ4BlockOutputStream. Its block was compressed by the reference
+// liblz4 (lz4 1.9.4, level 1), the algorithm behind lz4-java's fast
+// compressor that the game uses, and the header was built by hand.
+func TestLZ4Golden(t *testing.T) {
+	record, err := os.ReadFile(filepath.Join("testdata", "chunk_lz4.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record[4] != byte(CompressionLZ4) {
+		t.Fatalf("fixture has compression type %d", record[4])
+	}
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+	if err := r.WriteChunkRecord(3, 30, record, 1); err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := r.ReadChunkNBT(3, 30)
+	if err != nil {
+		t.Fatalf("decode golden record: %v", err)
+	}
+	sections, _ := chunk["sections"].([]any)
+	if chunk["DataVersion"] != int32(3839) || chunk["Status"] != "minecraft:full" || len(sections) != 3 {
+		t.Fatalf("decoded %v", chunk)
+	}
+
+	// Our encoder picks the same matches as liblz4 for this input, so the
+	// whole stream is byte-identical.
+	raw, err := lz4Decode(record[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc := lz4Encode(raw); !bytes.Equal(enc, record[5:]) {
+		t.Fatalf("encoded stream differs from golden:\n got %x\nwant %x", enc, record[5:])
+	}
+
+	// Input that does not shrink is stored raw, as lz4-java does, followed
+	// by the empty end block.
+	stored := "4c5a34426c6f636b" + "16" + "05000000" + "05000000" + "e3bf410a" + "68656c6c6f" +
+		"4c5a34426c6f636b" + "16" + "00000000" + "00000000" + "00000000"
+	if got := fmt.Sprintf("%x", lz4Encode([]byte("hello"))); got != stored {
+		t.Fatalf("stored block:\n got %s\nwant %s", got, stored)
+	}
+}
+
+func TestWriteCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZlib, CompressionNone, CompressionLZ4} {
+		path := filepath.Join(t.TempDir(), "r.0.0.mca")
+		writeTestRegion(t, path, map[string]any{"Status": "full"})
+		r, err := OpenRegionFile(path)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		r.SetWriteOptions(WriteOptions{Compression: c})
+		if err := r.WriteChunkNBT(1, 0, map[string]any{"Status": c.String()}); err != nil {
+			t.Fatalf("%s: write: %v", c, err)
+		}
+		got, err := r.ReadChunkNBT(1, 0)
+		r.Close()
+		if err != nil || got["Status"] != c.String() {
+			t.Fatalf("%s: read back %v %v", c, got, err)
+		}
+	}
+}
+
+func TestRewriteRegion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// Grow chunk 0,0 and shrink it again: it keeps its larger slot.
+	big := make([]int64, 2000)
+	for i := range big {
+		big[i] = rand.Int63()
+	}
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "full", "Data": big}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "shrunk"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := r.WriteChunkNBT(2, 3, map[string]any{"Status": "empty"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	r.Close()
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := RewriteRegion(path, WriteOptions{Compression: CompressionLZ4})
+	if err != nil {
+		t.Fatalf("RewriteRegion: %v", err)
+	}
+	if st.Chunks != 2 || st.After >= st.Before {
+		t.Fatalf("stats: %+v", st)
+	}
+	info, _ := os.Stat(path)
+	if info.Size() != st.After {
+		t.Fatalf("file size %d, stats say %d", info.Size(), st.After)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+		t.Fatalf("mode %v, want 0640", info.Mode().Perm())
+	}
+
+	r, err = OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer r.Close()
+	a, err := r.ReadChunkNBT(0, 0)
+	if err != nil || a["Status"] != "shrunk" {
+		t.Fatalf("chunk 0,0: %v %v", a, err)
+	}
+	b, err := r.ReadChunkNBT(2, 3)
+	if err != nil || b["Status"] != "empty" {
+		t.Fatalf("chunk 2,3: %v %v", b, err)
+	}
+	if _, err := RewriteRegion(path, WriteOptions{Compression: CompressionNone, Level: 5}); err == nil {
+		t.Fatal("level with compression none accepted")
+	}
+}
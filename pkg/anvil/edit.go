@@ -0,0 +1,166 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+// ErrMultiRegionBox is returned by EditBox on a single-file session for a
+// box that crosses region borders.
+var ErrMultiRegionBox = errors.New("box spans multiple regions of a single region file")
+
+// Stats counts the chunks an edit pass visited, wrote back, and could not
+// find on disk.
+type Stats struct {
+	Chunks  int
+	Written int
+	Missing int
+}
+
+// Add accumulates o into st.
+func (st *Stats) Add(o Stats) {
+	st.Chunks += o.Chunks
+	st.Written += o.Written
+	st.Missing += o.Missing
+}
+
+// BoxFunc edits one chunk; clip is the part of the box inside it. It
+// reports whether the chunk changed and must be written back.
+type BoxFunc func(chunk map[string]any, cx, cz int, clip coords.Box) (bool, error)
+
+// ChunkFunc edits one chunk and reports whether it changed.
+type ChunkFunc func(chunk map[string]any, cx, cz int) (bool, error)
+
+// EditBox calls fn with every stored chunk intersecting box, one region at a
+// time, and writes back the chunks fn reports as changed. Chunks and regions
+// that do not exist on disk are counted as missing and skipped.
+func (s *Session) EditBox(box coords.Box, fn BoxFunc) (Stats, error) {
+	var st Stats
+	cx0, cz0, cx1, cz1 := box.ChunkRange()
+	rx0, rz0 := coords.ChunkToRegionXZ(cx0, cz0)
+	rx1, rz1 := coords.ChunkToRegionXZ(cx1, cz1)
+	if s.file != "" && (rx0 != rx1 || rz0 != rz1) {
+		return st, ErrMultiRegionBox
+	}
+
+	for rx := rx0; rx <= rx1; rx++ {
+		for rz := rz0; rz <= rz1; rz++ {
+			qx0, qx1 := max(cx0, rx*32), min(cx1, rx*32+31)
+			qz0, qz1 := max(cz0, rz*32), min(cz1, rz*32+31)
+			if _, err := s.Region(qx0, qz0); errors.Is(err, os.ErrNotExist) {
+				st.Missing += (qx1 - qx0 + 1) * (qz1 - qz0 + 1)
+				continue
+			} else if err != nil {
+				return st, err
+			}
+			path := s.RegionPath(qx0, qz0)
+			err := s.editChunks(qx0, qz0, qx1, qz1, box, fn, &st)
+			if err == nil {
+				var n int
+				n, err = s.Flush()
+				st.Written += n
+			}
+			s.CloseRegion(path)
+			if err != nil {
+				return st, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	return st, nil
+}
+
+func (s *Session) editChunks(cx0, cz0, cx1, cz1 int, box coords.Box, fn BoxFunc, st *Stats) error {
+	for cz := cz0; cz <= cz1; cz++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			chunk, err := s.Chunk(cx, cz)
+			if errors.Is(err, ErrChunkNotPresent) {
+				st.Missing++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("read chunk %d,%d: %w", cx, cz, err)
+			}
+			st.Chunks++
+			changed, err := fn(chunk, cx, cz, box.ClipToChunk(cx, cz))
+			if err != nil {
+				return fmt.Errorf("chunk %d,%d: %w", cx, cz, err)
+			}
+			if changed {
+				s.MarkDirty(cx, cz)
+			}
+		}
+	}
+	return nil
+}
+
+// EditRegion calls fn with every stored chunk of the region file at path,
+// writes back the chunks fn reports as changed and closes the region. Only
+// changed chunks stay in memory until the write.
+func (s *Session) EditRegion(path string, fn ChunkFunc) (Stats, error) {
+	var st Stats
+	defer s.CloseRegion(path)
+
+	present, err := s.StoredChunks(path)
+	if err != nil {
+		return st, err
+	}
+	for _, c := range present {
+		cx, cz := c[0], c[1]
+		chunk, err := s.Chunk(cx, cz)
+		if err != nil {
+			return st, fmt.Errorf("%s: read chunk %d,%d: %w", path, cx, cz, err)
+		}
+		st.Chunks++
+		changed, err := fn(chunk, cx, cz)
+		if err != nil {
+			return st, fmt.Errorf("%s: chunk %d,%d: %w", path, cx, cz, err)
+		}
+		if changed {
+			s.MarkDirty(cx, cz)
+		} else {
+			s.release(cx, cz)
+		}
+	}
+	st.Written, err = s.Flush()
+	return st, err
+}
+
+// EditAll runs EditRegion over every region file of the session.
+func (s *Session) EditAll(fn ChunkFunc) (Stats, error) {
+	var st Stats
+	paths, err := s.RegionFiles()
+	if err != nil {
+		return st, err
+	}
+	for _, p := range paths {
+		rs, err := s.EditRegion(p, fn)
+		st.Add(rs)
+		if err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// RegionFiles lists the region files of the session's directory, or the
+// session's single file.
+func (s *Session) RegionFiles() ([]string, error) {
+	if s.file != "" {
+		return []string{s.file}, nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list regions: %w", err)
+	}
+	var out []string
+	for _, e := range entries {
+		if _, _, ok := coords.ParseRegionFileName(e.Name()); ok && !e.IsDir() {
+			out = append(out, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	return out, nil
+}
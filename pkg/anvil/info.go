@@ -0,0 +1,90 @@
+package anvil
+
+import (
+	"encoding/binary"
+)
+
+// ChunkInfo describes how one chunk is stored in its region file.
+type ChunkInfo struct {
+	// X and Z are in-region coordinates.
+	X, Z int
+	// Sector is the chunk's first sector and Sectors how many it owns.
+	Sector  int64
+	Sectors int
+	// Size is the record length in bytes, length and type header included.
+	Size        int
+	Compression Compression
+	Timestamp   uint32
+	// DataVersion is zero when the chunk has none or cannot be decoded.
+	DataVersion int32
+	// Invalid marks a record whose length does not fit its sectors.
+	Invalid bool
+}
+
+// RegionInfo describes the layout of a region file.
+type RegionInfo struct {
+	// Size is the file size in bytes and Sectors the 4 KiB sectors it spans.
+	Size    int64
+	Sectors int64
+	// FreeSectors counts sectors past the header that no chunk owns, such
+	// as those left behind when a chunk outgrew its slot.
+	FreeSectors int64
+	// Chunks lists the stored chunks in header order.
+	Chunks []ChunkInfo
+}
+
+// Info reads the header of every stored chunk and its DataVersion tag.
+func (r *Region) Info() (RegionInfo, error) {
+	var info RegionInfo
+	st, err := r.f.Stat()
+	if err != nil {
+		return info, err
+	}
+	info.Size = st.Size()
+	if info.Size < sectorSize*2 {
+		return info, nil
+	}
+	used, err := r.buildUsedSectors()
+	if err != nil {
+		return info, err
+	}
+	info.Sectors = int64(len(used))
+	for _, u := range used[2:] {
+		if !u {
+			info.FreeSectors++
+		}
+	}
+	present, err := r.Chunks()
+	if err != nil {
+		return info, err
+	}
+	_, ts, err := r.readHeaders()
+	if err != nil {
+		return info, err
+	}
+
+	header := make([]byte, 5)
+	for _, c := range present {
+		off, cnt, err := r.getLocation(c[0], c[1])
+		if err != nil {
+			return info, err
+		}
+		idx := indexFor(c[0], c[1]) * 4
+		ci := ChunkInfo{X: c[0], Z: c[1], Sector: off, Sectors: cnt, Timestamp: binary.BigEndian.Uint32(ts[idx : idx+4])}
+		if _, err := r.f.ReadAt(header, off*sectorSize); err != nil {
+			ci.Invalid = true
+			info.Chunks = append(info.Chunks, ci)
+			continue
+		}
+		length := int(binary.BigEndian.Uint32(header[:4]))
+		ci.Size = length + 4
+		ci.Compression = Compression(header[4])
+		if length <= 0 || ci.Size > cnt*sectorSize {
+			ci.Invalid = true
+		} else if v, err := r.ReadChunkKeys(c[0], c[1], "DataVersion"); err == nil {
+			ci.DataVersion, _ = v["DataVersion"].(int32)
+		}
+		info.Chunks = append(info.Chunks, ci)
+	}
+	return info, nil
+}
@@ -0,0 +1,45 @@
+package anvil
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegionInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"DataVersion": int32(3953)})
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+	r.SetWriteOptions(WriteOptions{Compression: CompressionLZ4})
+	if err := r.WriteChunkNBT(4, 2, map[string]any{"Status": "empty"}); err != nil {
+		t.Fatal(err)
+	}
+	// Move chunk 0,0 to a larger slot at the end, freeing its sector.
+	r.SetWriteOptions(WriteOptions{})
+	big := make([]int64, 2000)
+	for i := range big {
+		big[i] = rand.Int63()
+	}
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"DataVersion": int32(3953), "Data": big}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := r.Info()
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if len(info.Chunks) != 2 || info.FreeSectors != 1 || info.Sectors*sectorSize != info.Size {
+		t.Fatalf("info: %+v", info)
+	}
+	a, b := info.Chunks[0], info.Chunks[1]
+	if a.X != 0 || a.Z != 0 || a.Sectors < 4 || a.Size <= 3*sectorSize || a.Compression != CompressionZlib || a.DataVersion != 3953 || a.Timestamp == 0 {
+		t.Fatalf("chunk 0,0: %+v", a)
+	}
+	if b.X != 4 || b.Z != 2 || b.Sectors != 1 || b.Compression != CompressionLZ4 || b.DataVersion != 0 || b.Invalid {
+		t.Fatalf("chunk 4,2: %+v", b)
+	}
+}
@@ -0,0 +1,293 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Chunks of compression type 4 use the framing of lz4-java's
+// LZ4BlockOutputStream, which is what the game writes: a sequence of
+// blocks, each with a 21-byte header, ending with an empty block.
+//
+//	magic "LZ4Block" | method|level | compressed len | raw len | checksum
+//
+// Lengths and checksum are little endian. The checksum is XXH32 of the raw
+// block with seed 0x9747b28c, truncated to 28 bits.
+const (
+	lz4Magic       = "LZ4Block"
+	lz4HeaderLen   = len(lz4Magic) + 13
+	lz4BlockSize   = 1 << 16
+	lz4MethodRaw   = 0x10
+	lz4MethodLZ4   = 0x20
+	lz4Level       = 6 // log2(lz4BlockSize) - 10
+	lz4Seed        = 0x9747b28c
+	lz4ChecksumMsk = 0x0FFFFFFF
+
+	lz4MinMatch     = 4
+	lz4LastLiterals = 5
+	lz4MFLimit      = 12
+	lz4HashLog      = 14
+)
+
+var errLZ4Corrupt = errors.New("lz4: corrupt block")
+
+// lz4Encode frames raw as LZ4 blocks.
+func lz4Encode(raw []byte) []byte {
+	var out bytes.Buffer
+	header := func(method byte, compLen, rawLen int, check uint32) {
+		var h [lz4HeaderLen]byte
+		copy(h[:], lz4Magic)
+		h[8] = method | lz4Level
+		binary.LittleEndian.PutUint32(h[9:], uint32(compLen))
+		binary.LittleEndian.PutUint32(h[13:], uint32(rawLen))
+		binary.LittleEndian.PutUint32(h[17:], check)
+		out.Write(h[:])
+	}
+	for len(raw) > 0 {
+		n := min(len(raw), lz4BlockSize)
+		block := raw[:n]
+		raw = raw[n:]
+		check := xxh32(block, lz4Seed) & lz4ChecksumMsk
+		if comp := lz4CompressBlock(block); len(comp) < n {
+			header(lz4MethodLZ4, len(comp), n, check)
+			out.Write(comp)
+		} else {
+			header(lz4MethodRaw, n, n, check)
+			out.Write(block)
+		}
+	}
+	header(lz4MethodRaw, 0, 0, 0)
+	return out.Bytes()
+}
+
+// lz4Decode reverses lz4Encode, verifying each block's checksum.
+func lz4Decode(data []byte) ([]byte, error) {
+	var out []byte
+	for {
+		if len(data) < lz4HeaderLen || string(data[:8]) != lz4Magic {
+			return nil, errors.New("lz4: missing block header")
+		}
+		method := data[8] & 0xF0
+		compLen := int(binary.LittleEndian.Uint32(data[9:]))
+		rawLen := int(binary.LittleEndian.Uint32(data[13:]))
+		check := binary.LittleEndian.Uint32(data[17:])
+		data = data[lz4HeaderLen:]
+		if rawLen == 0 && compLen == 0 {
+			return out, nil
+		}
+		if compLen < 0 || rawLen < 0 || rawLen > lz4BlockSize<<4 || compLen > len(data) {
+			return nil, errLZ4Corrupt
+		}
+		start := len(out)
+		switch method {
+		case lz4MethodRaw:
+			if compLen != rawLen {
+				return nil, errLZ4Corrupt
+			}
+			out = append(out, data[:compLen]...)
+		case lz4MethodLZ4:
+			var err error
+			if out, err = lz4DecompressBlock(out, data[:compLen], rawLen); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("lz4: unknown block method %#x", method)
+		}
+		if xxh32(out[start:], lz4Seed)&lz4ChecksumMsk != check {
+			return nil, errors.New("lz4: checksum mismatch")
+		}
+		data = data[compLen:]
+	}
+}
+
+func lz4NewReader(data []byte) (io.ReadCloser, error) {
+	raw, err := lz4Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// lz4CompressBlock compresses src into one raw LZ4 block with a greedy
+// single-probe hash table.
+func lz4CompressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(src)/255+16)
+	if len(src) < lz4MFLimit+1 {
+		return lz4AppendSequence(dst, src, 0, 0)
+	}
+	var table [1 << lz4HashLog]int32
+	hash := func(i int) uint32 {
+		return (binary.LittleEndian.Uint32(src[i:]) * 2654435761) >> (32 - lz4HashLog)
+	}
+	anchor := 0
+	limit := len(src) - lz4MFLimit
+	for i := 0; i < limit; {
+		h := hash(i)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > 0xFFFF || binary.LittleEndian.Uint32(src[ref:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+		// Extend the match backwards over pending literals, then forwards
+		// up to the region the format reserves for literals.
+		for i > anchor && ref > 0 && src[i-1] == src[ref-1] {
+			i--
+			ref--
+		}
+		end := i + lz4MinMatch
+		for end < len(src)-lz4LastLiterals && src[end] == src[ref+end-i] {
+			end++
+		}
+		dst = lz4AppendSequence(dst, src[anchor:i], i-ref, end-i)
+		anchor = end
+		i = end
+	}
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence appends literals followed by a match of matchLen bytes
+// at offset; matchLen zero marks the final, literal-only sequence.
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	lit := len(literals)
+	ml := 0
+	if matchLen > 0 {
+		ml = matchLen - lz4MinMatch
+	}
+	token := byte(min(lit, 15))<<4 | byte(min(ml, 15))
+	dst = append(dst, token)
+	if lit >= 15 {
+		dst = lz4AppendLength(dst, lit-15)
+	}
+	dst = append(dst, literals...)
+	if matchLen == 0 {
+		return dst
+	}
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if ml >= 15 {
+		dst = lz4AppendLength(dst, ml-15)
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
+
+// lz4DecompressBlock appends the rawLen bytes encoded in src to dst.
+func lz4DecompressBlock(dst, src []byte, rawLen int) ([]byte, error) {
+	start := len(dst)
+	readLen := func(i, n int) (int, int, error) {
+		if n != 15 {
+			return n, i, nil
+		}
+		for {
+			if i >= len(src) {
+				return 0, 0, errLZ4Corrupt
+			}
+			b := src[i]
+			i++
+			n += int(b)
+			if b != 255 {
+				return n, i, nil
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		lit, ni, err := readLen(i, int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		i = ni
+		if i+lit > len(src) || len(dst)-start+lit > rawLen {
+			return nil, errLZ4Corrupt
+		}
+		dst = append(dst, src[i:i+lit]...)
+		i += lit
+		if i == len(src) {
+			break
+		}
+		if i+2 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		ml, ni, err := readLen(i, int(token&15))
+		if err != nil {
+			return nil, err
+		}
+		i = ni
+		ml += lz4MinMatch
+		if offset == 0 || offset > len(dst)-start || len(dst)-start+ml > rawLen {
+			return nil, errLZ4Corrupt
+		}
+		// Copy byte by byte: matches may overlap their own output.
+		from := len(dst) - offset
+		for k := range ml {
+			dst = append(dst, dst[from+k])
+		}
+	}
+	if len(dst)-start != rawLen {
+		return nil, errLZ4Corrupt
+	}
+	return dst, nil
+}
+
+const (
+	xxhPrime1 uint32 = 2654435761
+	xxhPrime2 uint32 = 2246822519
+	xxhPrime3 uint32 = 3266489917
+	xxhPrime4 uint32 = 668265263
+	xxhPrime5 uint32 = 374761393
+)
+
+// xxh32 is the 32-bit xxHash of b.
+func xxh32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxhPrime1 + xxhPrime2
+		v2 := seed + xxhPrime2
+		v3 := seed
+		v4 := seed - xxhPrime1
+		round := func(acc, in uint32) uint32 {
+			return bits.RotateLeft32(acc+in*xxhPrime2, 13) * xxhPrime1
+		}
+		for len(b) >= 16 {
+			v1 = round(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = round(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = round(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = round(v4, binary.LittleEndian.Uint32(b[12:]))
+			b = b[16:]
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxhPrime5
+	}
+	h += uint32(n)
+	for len(b) >= 4 {
+		h += binary.LittleEndian.Uint32(b) * xxhPrime3
+		h = bits.RotateLeft32(h, 17) * xxhPrime4
+		b = b[4:]
+	}
+	for _, c := range b {
+		h += uint32(c) * xxhPrime5
+		h = bits.RotateLeft32(h, 11) * xxhPrime1
+	}
+	h ^= h >> 15
+	h *= xxhPrime2
+	h ^= h >> 13
+	h *= xxhPrime3
+	h ^= h >> 16
+	return h
+}
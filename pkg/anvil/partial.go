@@ -0,0 +1,167 @@
+package anvil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+var errTruncated = errors.New("truncated NBT")
+
+// ReadChunkKeys decodes only the given top-level tags of chunk (cx, cz).
+// Every other tag is stepped over without being materialized, which makes
+// reading, say, only the block entities of a chunk far cheaper than
+// ReadChunkNBT. Tags the chunk lacks are absent from the result. The result
+// must not be written back: it would replace the chunk with these tags.
+func (r *Region) ReadChunkKeys(cx, cz int, keys ...string) (map[string]any, error) {
+	reader, err := r.chunkReader(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return decodeKeys(data, keys)
+}
+
+// decodeKeys decodes the wanted tags of the root compound in data.
+func decodeKeys(data []byte, keys []string) (map[string]any, error) {
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[k] = true
+	}
+	if len(data) < 3 || data[0] != nbt.TagCompound {
+		return nil, errors.New("chunk NBT does not start with a compound")
+	}
+	pos := 3 + int(binary.BigEndian.Uint16(data[1:3]))
+
+	out := make(map[string]any, len(keys))
+	for {
+		if pos >= len(data) {
+			return nil, errTruncated
+		}
+		tt := data[pos]
+		if tt == nbt.TagEnd {
+			return out, nil
+		}
+		if pos+3 > len(data) {
+			return nil, errTruncated
+		}
+		n := int(binary.BigEndian.Uint16(data[pos+1 : pos+3]))
+		start := pos + 3 + n
+		if start > len(data) {
+			return nil, errTruncated
+		}
+		name := string(data[pos+3 : start])
+		end, err := skipPayload(data, start, tt)
+		if err != nil {
+			return nil, fmt.Errorf("tag %q: %w", name, err)
+		}
+		if want[name] {
+			var v any
+			if err := (nbt.RawMessage{Type: tt, Data: data[start:end]}).Unmarshal(&v); err != nil {
+				return nil, fmt.Errorf("tag %q: %w", name, err)
+			}
+			out[name] = v
+		}
+		pos = end
+	}
+}
+
+// fixedSize is the payload size of the fixed-width tag types.
+var fixedSize = map[byte]int{
+	nbt.TagByte: 1, nbt.TagShort: 2, nbt.TagInt: 4, nbt.TagLong: 8,
+	nbt.TagFloat: 4, nbt.TagDouble: 8,
+}
+
+// arrayElemSize is the element size of the array tag types.
+var arrayElemSize = map[byte]int{nbt.TagByteArray: 1, nbt.TagIntArray: 4, nbt.TagLongArray: 8}
+
+// skipPayload returns the offset just past the payload of a tag of type tt
+// starting at pos. Arrays and lists of fixed-width values are skipped in
+// one step.
+func skipPayload(data []byte, pos int, tt byte) (int, error) {
+	need := func(n int) (int, error) {
+		if n < 0 || pos+n > len(data) {
+			return 0, errTruncated
+		}
+		return pos + n, nil
+	}
+	length := func(size int) (int, error) {
+		if pos+size > len(data) {
+			return 0, errTruncated
+		}
+		if size == 2 {
+			return int(binary.BigEndian.Uint16(data[pos:])), nil
+		}
+		return int(int32(binary.BigEndian.Uint32(data[pos:]))), nil
+	}
+
+	if size, ok := fixedSize[tt]; ok {
+		return need(size)
+	}
+	switch tt {
+	case nbt.TagString:
+		n, err := length(2)
+		if err != nil {
+			return 0, err
+		}
+		return need(2 + n)
+	case nbt.TagByteArray, nbt.TagIntArray, nbt.TagLongArray:
+		n, err := length(4)
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("negative array length %d", n)
+		}
+		return need(4 + n*arrayElemSize[tt])
+	case nbt.TagList:
+		if pos+5 > len(data) {
+			return 0, errTruncated
+		}
+		et := data[pos]
+		pos++
+		n, err := length(4)
+		if err != nil {
+			return 0, err
+		}
+		pos += 4
+		if n <= 0 {
+			return pos, nil
+		}
+		if size, ok := fixedSize[et]; ok {
+			return need(n * size)
+		}
+		for range n {
+			if pos, err = skipPayload(data, pos, et); err != nil {
+				return 0, err
+			}
+		}
+		return pos, nil
+	case nbt.TagCompound:
+		for {
+			if pos >= len(data) {
+				return 0, errTruncated
+			}
+			ct := data[pos]
+			if ct == nbt.TagEnd {
+				return pos + 1, nil
+			}
+			n := 0
+			if pos+3 <= len(data) {
+				n = int(binary.BigEndian.Uint16(data[pos+1 : pos+3]))
+			}
+			var err error
+			if pos, err = skipPayload(data, pos+3+n, ct); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown tag type %#02x", tt)
+}
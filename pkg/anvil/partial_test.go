@@ -0,0 +1,75 @@
+package anvil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadChunkKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	if err := os.WriteFile(path, make([]byte, 2*sectorSize), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	chunk := map[string]any{
+		"DataVersion": int32(3700),
+		"Status":      "minecraft:full",
+		"sections": []any{
+			map[string]any{
+				"Y":          int8(-4),
+				"BlockLight": make([]byte, 2048),
+				"block_states": map[string]any{
+					"palette": []any{map[string]any{"Name": "minecraft:stone"}},
+					"data":    make([]int64, 256),
+				},
+			},
+		},
+		"Heightmaps":     map[string]any{"WORLD_SURFACE": make([]int64, 37)},
+		"PostProcessing": []any{[]any{}, []any{int16(3)}},
+		"block_entities": []any{
+			map[string]any{"id": "minecraft:chest", "x": int32(1), "y": int32(64), "z": int32(2), "keepPacked": int8(0)},
+		},
+		"InhabitedTime": int64(12),
+		"structures":    map[string]any{"References": map[string]any{}, "starts": map[string]any{}},
+		"xPos":          int32(0),
+	}
+	if err := r.WriteChunkNBT(0, 0, chunk); err != nil {
+		t.Fatal(err)
+	}
+	full, err := r.ReadChunkNBT(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.ReadChunkKeys(0, 0, "block_entities", "xPos", "missing")
+	if err != nil {
+		t.Fatalf("ReadChunkKeys: %v", err)
+	}
+	want := map[string]any{"block_entities": full["block_entities"], "xPos": full["xPos"]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	all := make([]string, 0, len(full))
+	for k := range full {
+		all = append(all, k)
+	}
+	if got, err := r.ReadChunkKeys(0, 0, all...); err != nil || !reflect.DeepEqual(got, full) {
+		t.Fatalf("all keys: err=%v, differs from full decode", err)
+	}
+}
+
+func TestDecodeKeysTruncated(t *testing.T) {
+	// Root compound holding a long array that claims 4 elements but has 1.
+	data := []byte{10, 0, 0, 12, 0, 1, 'a', 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 1}
+	if _, err := decodeKeys(data, []string{"a"}); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
@@ -0,0 +1,392 @@
+// Package anvil reads and writes chunks in Anvil region files (.mca).
+// Region gives raw access to one file; Session caches regions and decoded
+// chunks across a directory and writes edited chunks back on Flush.
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+const sectorSize = 4096
+
+// ErrChunkNotPresent is returned when reading a chunk the region does not
+// store.
+var ErrChunkNotPresent = errors.New("chunk not present in region")
+
+// ErrChunkTooLarge is returned for a chunk record longer than the 255
+// sectors a location entry can address.
+var ErrChunkTooLarge = errors.New("chunk too large for a region file")
+
+// Region is an open region file. Its methods take in-region chunk
+// coordinates, 0 to 31 on each axis.
+type Region struct {
+	path string
+	f    *os.File
+	mu   sync.Mutex
+
+	// loc and ts are the location and timestamp tables, read on first use
+	// and kept in step with every write.
+	loc []byte
+	ts  []byte
+
+	opts WriteOptions
+}
+
+// OpenRegionFile opens the region file at path for reading and writing.
+func OpenRegionFile(path string) (*Region, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	return &Region{path: path, f: f}, nil
+}
+
+// OpenRegionForWorldXZ opens the region file in regionDir that holds
+// absolute block (x, z), and returns its path as well.
+func OpenRegionForWorldXZ(regionDir string, x, z int) (*Region, string, error) {
+	rx, rz := coords.WorldToRegionXZ(x, z)
+	name := coords.RegionFileName(rx, rz)
+	path := filepath.Join(regionDir, name)
+	reg, err := OpenRegionFile(path)
+	return reg, path, err
+}
+
+// Close closes the region file.
+func (r *Region) Close() error {
+	return r.f.Close()
+}
+
+// Path returns the file the region was opened from.
+func (r *Region) Path() string {
+	return r.path
+}
+
+func indexFor(cx, cz int) int { return cz*32 + cx }
+
+func (r *Region) readHeaders() ([]byte, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.headersLocked()
+}
+
+func (r *Region) headersLocked() ([]byte, []byte, error) {
+	if r.loc == nil {
+		buf := make([]byte, sectorSize*2)
+		if _, err := r.f.ReadAt(buf, 0); err != nil {
+			return nil, nil, err
+		}
+		r.loc, r.ts = buf[:sectorSize], buf[sectorSize:]
+	}
+	return r.loc, r.ts, nil
+}
+
+func (r *Region) getLocation(cx, cz int) (offsetSectors int64, count int, err error) {
+	loc, _, err := r.readHeaders()
+	if err != nil {
+		return 0, 0, err
+	}
+	idx := indexFor(cx, cz) * 4
+	entry := loc[idx : idx+4]
+	offset := int64(entry[0])<<16 | int64(entry[1])<<8 | int64(entry[2])
+	count = int(entry[3])
+	return offset, count, nil
+}
+
+func (r *Region) setLocation(cx, cz int, offset int64, count int, stamp uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loc, ts, err := r.headersLocked()
+	if err != nil {
+		return err
+	}
+	idx := indexFor(cx, cz) * 4
+	loc[idx+0] = byte((offset >> 16) & 0xFF)
+	loc[idx+1] = byte((offset >> 8) & 0xFF)
+	loc[idx+2] = byte(offset & 0xFF)
+	loc[idx+3] = byte(count)
+	if _, err := r.f.WriteAt(loc[idx:idx+4], int64(idx)); err != nil {
+		return err
+	}
+	// timestamp
+	binary.BigEndian.PutUint32(ts[idx:idx+4], stamp)
+	if _, err := r.f.WriteAt(ts[idx:idx+4], sectorSize+int64(idx)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Region) fileSectorCount() (int64, error) {
+	st, err := r.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return (st.Size() + sectorSize - 1) / sectorSize, nil
+}
+
+func (r *Region) buildUsedSectors() ([]bool, error) {
+	sectorCount, err := r.fileSectorCount()
+	if err != nil {
+		return nil, err
+	}
+	used := make([]bool, sectorCount)
+	if sectorCount >= 2 {
+		used[0] = true
+		used[1] = true
+	}
+	loc, _, err := r.readHeaders()
+	if err != nil {
+		return nil, err
+	}
+	for i := range 1024 {
+		entry := loc[i*4 : i*4+4]
+		off := int(entry[0])<<16 | int(entry[1])<<8 | int(entry[2])
+		cnt := int(entry[3])
+		if off == 0 || cnt == 0 {
+			continue
+		}
+		for s := 0; s < cnt && int64(off+s) < int64(len(used)); s++ {
+			used[off+s] = true
+		}
+	}
+	return used, nil
+}
+
+func (r *Region) findFreeRun(need int) (int64, error) {
+	used, err := r.buildUsedSectors()
+	if err != nil {
+		return 0, err
+	}
+	// search
+	freeLen := 0
+	start := 0
+	for i := 2; i < len(used); i++ { // skip header
+		if !used[i] {
+			if freeLen == 0 {
+				start = i
+			}
+			freeLen++
+			if freeLen >= need {
+				return int64(start), nil
+			}
+		} else {
+			freeLen = 0
+		}
+	}
+	// append
+	count, err := r.fileSectorCount()
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Chunks lists the in-region coordinates of every chunk stored in the region.
+func (r *Region) Chunks() ([][2]int, error) {
+	loc, _, err := r.readHeaders()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out [][2]int
+	for i := range 1024 {
+		entry := loc[i*4 : i*4+4]
+		if entry[0]|entry[1]|entry[2] == 0 || entry[3] == 0 {
+			continue
+		}
+		out = append(out, [2]int{i % 32, i / 32})
+	}
+	return out, nil
+}
+
+// ReadChunkNBT decodes chunk (cx, cz), given in in-region coordinates.
+func (r *Region) ReadChunkNBT(cx, cz int) (map[string]any, error) {
+	reader, err := r.chunkReader(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var chunk map[string]any
+	dec := nbt.NewDecoder(reader)
+	if _, err := dec.Decode(&chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// chunkReader returns the decompressed NBT stream of chunk (cx, cz).
+func (r *Region) chunkReader(cx, cz int) (io.ReadCloser, error) {
+	off, cnt, err := r.getLocation(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	if off == 0 || cnt == 0 {
+		return nil, ErrChunkNotPresent
+	}
+	pos := off * sectorSize
+	header := make([]byte, 5)
+	if _, err := r.f.ReadAt(header, pos); err != nil {
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	ctype := header[4]
+	if length <= 0 || length > int64(cnt*sectorSize) {
+		return nil, fmt.Errorf("invalid chunk length %d", length)
+	}
+	comp := make([]byte, length-1)
+	if _, err := r.f.ReadAt(comp, pos+5); err != nil {
+		return nil, err
+	}
+	return decompress(Compression(ctype), comp)
+}
+
+// SetWriteOptions sets the compression and verification of later writes.
+func (r *Region) SetWriteOptions(o WriteOptions) {
+	r.mu.Lock()
+	r.opts = o
+	r.mu.Unlock()
+}
+
+// WriteChunkNBT encodes chunk and stores it as chunk (cx, cz), reading it
+// back first when the write options ask for verification.
+func (r *Region) WriteChunkNBT(cx, cz int, chunk map[string]any) error {
+	var nbtBuf bytes.Buffer
+	enc := nbt.NewEncoder(&nbtBuf)
+	if err := enc.Encode(chunk, ""); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	verify := r.opts.Verify
+	r.mu.Unlock()
+	if verify {
+		return r.writeVerified(cx, cz, chunk, nbtBuf.Bytes())
+	}
+	return r.WriteChunkRaw(cx, cz, nbtBuf.Bytes())
+}
+
+// ReadChunkRaw returns the uncompressed NBT bytes of chunk (cx, cz).
+func (r *Region) ReadChunkRaw(cx, cz int) ([]byte, error) {
+	reader, err := r.chunkReader(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// WriteChunkRaw compresses already encoded chunk NBT with the region's
+// write options and stores it as chunk (cx, cz).
+func (r *Region) WriteChunkRaw(cx, cz int, raw []byte) error {
+	r.mu.Lock()
+	opts := r.opts
+	r.mu.Unlock()
+	ctype, comp, err := opts.compress(raw)
+	if err != nil {
+		return err
+	}
+	return r.writeRecord(cx, cz, chunkRecord(ctype, comp), uint32(time.Now().Unix()))
+}
+
+// ReadChunkRecord returns chunk (cx, cz) as stored: the compressed record
+// with its length and type header, and its timestamp.
+func (r *Region) ReadChunkRecord(cx, cz int) ([]byte, uint32, error) {
+	off, cnt, err := r.getLocation(cx, cz)
+	if err != nil {
+		return nil, 0, err
+	}
+	if off == 0 || cnt == 0 {
+		return nil, 0, ErrChunkNotPresent
+	}
+	header := make([]byte, 4)
+	if _, err := r.f.ReadAt(header, off*sectorSize); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if length <= 0 || length+4 > int64(cnt*sectorSize) {
+		return nil, 0, fmt.Errorf("invalid chunk length %d", length)
+	}
+	record := make([]byte, length+4)
+	if _, err := r.f.ReadAt(record, off*sectorSize); err != nil {
+		return nil, 0, err
+	}
+	_, ts, err := r.readHeaders()
+	if err != nil {
+		return nil, 0, err
+	}
+	idx := indexFor(cx, cz) * 4
+	return record, binary.BigEndian.Uint32(ts[idx : idx+4]), nil
+}
+
+// WriteChunkRecord stores a record returned by ReadChunkRecord as chunk
+// (cx, cz) with the given timestamp. A nil record removes the chunk.
+func (r *Region) WriteChunkRecord(cx, cz int, record []byte, stamp uint32) error {
+	if record == nil {
+		return r.setLocation(cx, cz, 0, 0, 0)
+	}
+	if len(record) < 5 || int(binary.BigEndian.Uint32(record))+4 != len(record) {
+		return fmt.Errorf("invalid chunk record of %d bytes", len(record))
+	}
+	return r.writeRecord(cx, cz, record, stamp)
+}
+
+// writeRecord places record in the chunk's sectors when it fits there, or
+// in the first free run of sectors otherwise.
+func (r *Region) writeRecord(cx, cz int, record []byte, stamp uint32) error {
+	need := (len(record) + sectorSize - 1) / sectorSize
+	if need > 255 {
+		return fmt.Errorf("%w: %d bytes with %s compression", ErrChunkTooLarge, len(record), Compression(record[4]))
+	}
+
+	oldOff, oldCnt, err := r.getLocation(cx, cz)
+	if err != nil {
+		return err
+	}
+	writeOff := oldOff
+	writeCnt := oldCnt
+	if oldOff == 0 || oldCnt < need {
+		off, err := r.findFreeRun(need)
+		if err != nil {
+			return err
+		}
+		writeOff = off
+		writeCnt = need
+	}
+	// write
+	pos := writeOff * sectorSize
+	padSize := writeCnt*sectorSize - len(record)
+	buf := make([]byte, 0, writeCnt*sectorSize)
+	buf = append(buf, record...)
+	if padSize > 0 {
+		buf = append(buf, make([]byte, padSize)...)
+	}
+	if _, err := r.f.WriteAt(buf, pos); err != nil {
+		return err
+	}
+	if err := r.setLocation(cx, cz, writeOff, writeCnt, stamp); err != nil {
+		return err
+	}
+	return nil
+}
+
+// chunkRecord frames a compressed chunk as stored in a region: big-endian
+// length of type and payload, the type byte, then the payload.
+func chunkRecord(ctype Compression, comp []byte) []byte {
+	record := make([]byte, 5+len(comp))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(comp)+1))
+	record[4] = byte(ctype)
+	copy(record[5:], comp)
+	return record
+}
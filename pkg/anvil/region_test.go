@@ -8,7 +8,7 @@ import (
 	"path/filepath"
 	"testing"
 
-	"nbt-cli/internal/coords"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
 
 	"github.com/Tnze/go-mc/nbt"
 )
@@ -0,0 +1,153 @@
+package anvil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// RewriteStats describes one RewriteRegion call.
+type RewriteStats struct {
+	Chunks int
+	// Before and After are the file sizes in bytes.
+	Before, After int64
+}
+
+// RewriteRegion recompresses every chunk of the region file at path with o
+// and packs the chunks back to back, dropping free sectors. Timestamps are
+// kept. The new file is written next to the old one with the same mode,
+// synced and renamed over it, so a failure or crash leaves the original
+// in place. With o.Verify the new file is read back and compared with the
+// original before the rename.
+func RewriteRegion(path string, o WriteOptions) (RewriteStats, error) {
+	if err := o.Validate(); err != nil {
+		return RewriteStats{}, err
+	}
+	data, st, err := packRegion(path, o)
+	if err != nil {
+		return st, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return st, err
+	}
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data, fi.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return st, err
+	}
+	if o.Verify {
+		if err := verifyRewrite(path, tmp); err != nil {
+			os.Remove(tmp)
+			return st, err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return st, err
+	}
+	st.After = int64(len(data))
+	return st, syncDir(filepath.Dir(path))
+}
+
+// writeFileSync writes data to a new file at path with mode perm and
+// flushes it to disk.
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// The umask may have narrowed perm.
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir flushes a rename in dir to disk. Windows cannot sync directories
+// and commits renames on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// PackRegion reports what RewriteRegion would do without writing anything.
+func PackRegion(path string, o WriteOptions) (RewriteStats, error) {
+	if err := o.Validate(); err != nil {
+		return RewriteStats{}, err
+	}
+	data, st, err := packRegion(path, o)
+	st.After = int64(len(data))
+	return st, err
+}
+
+// packRegion builds the compacted contents of the region file at path.
+func packRegion(path string, o WriteOptions) ([]byte, RewriteStats, error) {
+	var st RewriteStats
+	src, err := OpenRegionFile(path)
+	if err != nil {
+		return nil, st, err
+	}
+	defer src.Close()
+	info, err := src.f.Stat()
+	if err != nil {
+		return nil, st, err
+	}
+	st.Before = info.Size()
+	present, err := src.Chunks()
+	if err != nil {
+		return nil, st, fmt.Errorf("%s: %w", path, err)
+	}
+	_, ts, err := src.readHeaders()
+	if err != nil {
+		return nil, st, fmt.Errorf("%s: %w", path, err)
+	}
+
+	header := make([]byte, sectorSize*2)
+	copy(header[sectorSize:], ts)
+	var body []byte
+	for _, c := range present {
+		raw, err := src.ReadChunkRaw(c[0], c[1])
+		if err != nil {
+			return nil, st, fmt.Errorf("%s: read chunk %d,%d: %w", path, c[0], c[1], err)
+		}
+		ctype, comp, err := o.compress(raw)
+		if err != nil {
+			return nil, st, err
+		}
+		record := chunkRecord(ctype, comp)
+		need := (len(record) + sectorSize - 1) / sectorSize
+		if need > 255 {
+			return nil, st, fmt.Errorf("%s: chunk %d,%d: %w: %d bytes with %s compression", path, c[0], c[1], ErrChunkTooLarge, len(record), ctype)
+		}
+		off := 2 + len(body)/sectorSize
+		idx := indexFor(c[0], c[1]) * 4
+		header[idx] = byte(off >> 16)
+		header[idx+1] = byte(off >> 8)
+		header[idx+2] = byte(off)
+		header[idx+3] = byte(need)
+		body = append(body, record...)
+		body = append(body, make([]byte, need*sectorSize-len(record))...)
+		st.Chunks++
+	}
+
+	return append(header, body...), st, nil
+}
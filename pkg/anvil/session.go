@@ -0,0 +1,286 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+// Session caches the open regions of a region directory, or of a single
+// region file, and the chunks decoded from them. Edited chunks are marked
+// dirty and written by Flush, so several edits to one chunk cost a single
+// decode and encode.
+type Session struct {
+	dir  string
+	file string
+
+	regions map[string]*sessionRegion
+	chunks  map[chunkKey]*sessionChunk
+	opts    WriteOptions
+	before  WriteHook
+	after   WriteHook
+	dryRun  DryRunFunc
+}
+
+// DryRunFunc receives a chunk a dry-run Flush did not write: its state on
+// disk, nil when the chunk is absent, and its edited state.
+type DryRunFunc func(r *Region, cx, cz int, old, edited map[string]any) error
+
+// WriteHook is called around a chunk write, with its region and in-region
+// coordinates. An error aborts the flush.
+type WriteHook func(r *Region, cx, cz int) error
+
+type sessionRegion struct {
+	r   *Region
+	err error
+}
+
+type chunkKey struct {
+	path   string
+	lx, lz int
+}
+
+type sessionChunk struct {
+	data   map[string]any
+	cx, cz int
+	dirty  bool
+}
+
+// NewSession returns a session over the r.<x>.<z>.mca files of dir.
+func NewSession(dir string) *Session {
+	return &Session{dir: dir, regions: map[string]*sessionRegion{}, chunks: map[chunkKey]*sessionChunk{}}
+}
+
+// NewFileSession returns a session over one region file. Every chunk
+// coordinate maps to its in-region index within that file.
+func NewFileSession(path string) *Session {
+	s := NewSession("")
+	s.file = path
+	return s
+}
+
+// SetWriteOptions sets the compression of chunks written by Flush,
+// including through regions the session already opened.
+func (s *Session) SetWriteOptions(o WriteOptions) {
+	s.opts = o
+	for _, sr := range s.regions {
+		if sr.r != nil {
+			sr.r.SetWriteOptions(o)
+		}
+	}
+}
+
+// SetBeforeWrite installs h to run before Flush writes each chunk, so the
+// chunk's previous record can be saved.
+func (s *Session) SetBeforeWrite(h WriteHook) {
+	s.before = h
+}
+
+// SetAfterWrite installs h to run after Flush writes each chunk, so the
+// new record can be logged.
+func (s *Session) SetAfterWrite(h WriteHook) {
+	s.after = h
+}
+
+// SetDryRun makes Flush hand dirty chunks to fn instead of writing them.
+// Write hooks do not run.
+func (s *Session) SetDryRun(fn DryRunFunc) {
+	s.dryRun = fn
+}
+
+// RegionPath returns the region file that holds absolute chunk (cx, cz).
+func (s *Session) RegionPath(cx, cz int) string {
+	if s.file != "" {
+		return s.file
+	}
+	rx, rz := coords.ChunkToRegionXZ(cx, cz)
+	return filepath.Join(s.dir, coords.RegionFileName(rx, rz))
+}
+
+// Region returns the open region file holding chunk (cx, cz). The error
+// wraps os.ErrNotExist when the file does not exist.
+func (s *Session) Region(cx, cz int) (*Region, error) {
+	path := s.RegionPath(cx, cz)
+	sr, ok := s.regions[path]
+	if !ok {
+		sr = &sessionRegion{}
+		sr.r, sr.err = OpenRegionFile(path)
+		if sr.err == nil {
+			sr.r.SetWriteOptions(s.opts)
+		}
+		s.regions[path] = sr
+	}
+	if sr.err != nil {
+		return nil, fmt.Errorf("open region %s: %w", path, sr.err)
+	}
+	return sr.r, nil
+}
+
+// Chunk returns the decoded chunk (cx, cz), reading it on first use. Edits
+// to the returned map are only saved after MarkDirty and Flush.
+func (s *Session) Chunk(cx, cz int) (map[string]any, error) {
+	r, err := s.Region(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	key := chunkKey{r.path, lx, lz}
+	if c, ok := s.chunks[key]; ok {
+		return c.data, nil
+	}
+	data, err := r.ReadChunkNBT(lx, lz)
+	if err != nil {
+		return nil, err
+	}
+	s.chunks[key] = &sessionChunk{data: data, cx: cx, cz: cz}
+	return data, nil
+}
+
+// ChunkKeys returns only the given top-level tags of chunk (cx, cz): from
+// the cache when the chunk was already decoded, through ReadChunkKeys
+// otherwise. The result is never cached and cannot be marked dirty.
+func (s *Session) ChunkKeys(cx, cz int, keys ...string) (map[string]any, error) {
+	r, err := s.Region(cx, cz)
+	if err != nil {
+		return nil, err
+	}
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	if c, ok := s.chunks[chunkKey{r.path, lx, lz}]; ok {
+		out := make(map[string]any, len(keys))
+		for _, k := range keys {
+			if v, ok := c.data[k]; ok {
+				out[k] = v
+			}
+		}
+		return out, nil
+	}
+	return r.ReadChunkKeys(lx, lz, keys...)
+}
+
+// MarkDirty schedules chunk (cx, cz), previously returned by Chunk, to be
+// written by the next Flush.
+func (s *Session) MarkDirty(cx, cz int) {
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	if c, ok := s.chunks[chunkKey{s.RegionPath(cx, cz), lx, lz}]; ok {
+		c.dirty = true
+	}
+}
+
+// release drops chunk (cx, cz) from the cache unless it is dirty.
+func (s *Session) release(cx, cz int) {
+	lx, lz := coords.InRegionChunkIndex(cx, cz)
+	key := chunkKey{s.RegionPath(cx, cz), lx, lz}
+	if c, ok := s.chunks[key]; ok && !c.dirty {
+		delete(s.chunks, key)
+	}
+}
+
+// StoredChunks lists the absolute coordinates of the chunks stored in the
+// region file at path, which must belong to the session. Coordinates come
+// from the file name; a file without a region name counts as region 0,0.
+func (s *Session) StoredChunks(path string) ([][2]int, error) {
+	rx, rz, _ := coords.ParseRegionFileName(filepath.Base(path))
+	r, err := s.Region(rx*32, rz*32)
+	if err != nil {
+		return nil, err
+	}
+	present, err := r.Chunks()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := make([][2]int, len(present))
+	for i, c := range present {
+		out[i] = [2]int{rx*32 + c[0], rz*32 + c[1]}
+	}
+	return out, nil
+}
+
+// Flush writes every dirty chunk, in file order, and empties the chunk
+// cache. It returns how many chunks were written.
+func (s *Session) Flush() (int, error) {
+	keys := make([]chunkKey, 0, len(s.chunks))
+	for k, c := range s.chunks {
+		if c.dirty {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return indexFor(a.lx, a.lz) < indexFor(b.lx, b.lz)
+	})
+	written := 0
+	for _, k := range keys {
+		c := s.chunks[k]
+		r := s.regions[k.path].r
+		if s.dryRun != nil {
+			old, err := r.ReadChunkNBT(k.lx, k.lz)
+			if errors.Is(err, ErrChunkNotPresent) {
+				old, err = nil, nil
+			}
+			if err == nil {
+				err = s.dryRun(r, k.lx, k.lz, old, c.data)
+			}
+			if err != nil {
+				return written, fmt.Errorf("%s: chunk %d,%d: %w", k.path, c.cx, c.cz, err)
+			}
+			c.dirty = false
+			written++
+			continue
+		}
+		if s.before != nil {
+			if err := s.before(r, k.lx, k.lz); err != nil {
+				return written, fmt.Errorf("%s: chunk %d,%d: %w", k.path, c.cx, c.cz, err)
+			}
+		}
+		if err := r.WriteChunkNBT(k.lx, k.lz, c.data); err != nil {
+			return written, fmt.Errorf("%s: write chunk %d,%d: %w", k.path, c.cx, c.cz, err)
+		}
+		if s.after != nil {
+			if err := s.after(r, k.lx, k.lz); err != nil {
+				return written, fmt.Errorf("%s: chunk %d,%d: %w", k.path, c.cx, c.cz, err)
+			}
+		}
+		c.dirty = false
+		written++
+	}
+	clear(s.chunks)
+	return written, nil
+}
+
+// CloseRegion closes the region file at path and drops its cached chunks,
+// dirty or not. Scans call it once they are done with a region.
+func (s *Session) CloseRegion(path string) error {
+	sr, ok := s.regions[path]
+	if !ok {
+		return nil
+	}
+	delete(s.regions, path)
+	for k := range s.chunks {
+		if k.path == path {
+			delete(s.chunks, k)
+		}
+	}
+	if sr.r == nil {
+		return nil
+	}
+	return sr.r.Close()
+}
+
+// Close closes every region the session opened. Unflushed edits are lost.
+func (s *Session) Close() error {
+	var errs []error
+	for _, sr := range s.regions {
+		if sr.r != nil {
+			errs = append(errs, sr.r.Close())
+		}
+	}
+	clear(s.regions)
+	clear(s.chunks)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package anvil
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionFlush(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+
+	s := NewSession(tmp)
+	defer s.Close()
+	a, err := s.Chunk(0, 0)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	a["Status"] = "edited"
+	b, err := s.Chunk(0, 0)
+	if err != nil || b["Status"] != "edited" {
+		t.Fatalf("second Chunk did not return the cached map: %v %v", b, err)
+	}
+	if n, err := s.Flush(); err != nil || n != 0 {
+		t.Fatalf("flush of clean chunks: n=%d err=%v", n, err)
+	}
+
+	c, _ := s.Chunk(0, 0)
+	if c["Status"] != "full" {
+		t.Fatalf("unflushed edit reached disk: %v", c)
+	}
+	c["Status"] = "edited"
+	s.MarkDirty(0, 0)
+	if n, err := s.Flush(); err != nil || n != 1 {
+		t.Fatalf("Flush: n=%d err=%v", n, err)
+	}
+
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer r.Close()
+	d, err := r.ReadChunkNBT(0, 0)
+	if err != nil || d["Status"] != "edited" {
+		t.Fatalf("flushed chunk: %v %v", d, err)
+	}
+
+	if _, err := s.Chunk(40, 0); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing region: got %v", err)
+	}
+	if _, err := s.Chunk(1, 0); !errors.Is(err, ErrChunkNotPresent) {
+		t.Fatalf("missing chunk: got %v", err)
+	}
+}
+
+func TestWriteKeepsOtherTimestamps(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("open region: %v", err)
+	}
+	if err := r.WriteChunkNBT(1, 0, map[string]any{"Status": "new"}); err != nil {
+		t.Fatalf("WriteChunkNBT: %v", err)
+	}
+	r.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read region: %v", err)
+	}
+	if ts := binary.BigEndian.Uint32(raw[sectorSize:]); ts != 0x01020304 {
+		t.Fatalf("timestamp of chunk 0,0 changed to %#x", ts)
+	}
+}
+
+func TestSessionDryRun(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+	before, _ := os.ReadFile(path)
+
+	s := NewSession(tmp)
+	defer s.Close()
+	var seen []any
+	s.SetDryRun(func(r *Region, cx, cz int, old, edited map[string]any) error {
+		seen = append(seen, old["Status"], edited["Status"])
+		return nil
+	})
+	c, err := s.Chunk(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c["Status"] = "edited"
+	s.MarkDirty(0, 0)
+	if n, err := s.Flush(); err != nil || n != 1 {
+		t.Fatalf("Flush: n=%d err=%v", n, err)
+	}
+	if len(seen) != 2 || seen[0] != "full" || seen[1] != "edited" {
+		t.Fatalf("dry run saw %v", seen)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Fatal("dry run wrote to the region file")
+	}
+}
+
+func TestEditRegionReleasesCleanChunks(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []int{1, 2} {
+		if err := r.WriteChunkNBT(x, 0, map[string]any{"Status": "full"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+
+	s := NewSession(tmp)
+	defer s.Close()
+	held := 0
+	st, err := s.EditRegion(path, func(chunk map[string]any, cx, cz int) (bool, error) {
+		held = max(held, len(s.chunks))
+		if cx == 1 {
+			chunk["Status"] = "edited"
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil || st.Chunks != 3 || st.Written != 1 {
+		t.Fatalf("EditRegion: %+v %v", st, err)
+	}
+	if held > 2 {
+		t.Fatalf("%d chunks held at once, want at most the dirty one and the current one", held)
+	}
+}
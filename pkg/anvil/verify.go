@@ -0,0 +1,155 @@
+package anvil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ErrVerify is returned when a chunk read back after a verified write does
+// not match what was written. The previous chunk has been put back.
+var ErrVerify = errors.New("written chunk does not match")
+
+// writeVerified writes chunk, already encoded as raw, then reads it back
+// and compares it with chunk. On a mismatch the previous record and
+// timestamp are restored.
+func (r *Region) writeVerified(cx, cz int, chunk map[string]any, raw []byte) error {
+	old, stamp, err := r.ReadChunkRecord(cx, cz)
+	if errors.Is(err, ErrChunkNotPresent) {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("save previous chunk: %w", err)
+	}
+	if err := r.WriteChunkRaw(cx, cz, raw); err != nil {
+		return err
+	}
+	got, err := r.ReadChunkNBT(cx, cz)
+	var bad error
+	switch {
+	case err != nil:
+		bad = fmt.Errorf("%w: read back: %v", ErrVerify, err)
+	default:
+		if p, ok := mismatch("", chunk, got); !ok {
+			if p == "" {
+				p = "root"
+			}
+			bad = fmt.Errorf("%w: differs at %s", ErrVerify, p)
+		}
+	}
+	if bad == nil {
+		return nil
+	}
+	if err := r.WriteChunkRecord(cx, cz, old, stamp); err != nil {
+		return errors.Join(bad, fmt.Errorf("restore previous chunk: %w", err))
+	}
+	return bad
+}
+
+// mismatch compares a value before encoding with the value decoded from
+// it, and returns the first path where they differ. Go types without a tag
+// of their own compare as the tag they encode to: bool as byte and typed
+// maps as compounds.
+func mismatch(path string, mem, disk any) (string, bool) {
+	mv, dv := reflect.ValueOf(mem), reflect.ValueOf(disk)
+	if !mv.IsValid() || !dv.IsValid() {
+		return path, !mv.IsValid() && !dv.IsValid()
+	}
+	if mv.Kind() == reflect.Map {
+		d, ok := disk.(map[string]any)
+		if !ok || mv.Type().Key().Kind() != reflect.String {
+			return path, false
+		}
+		keys := make([]string, 0, mv.Len()+len(d))
+		for _, k := range mv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		for k := range d {
+			if !mv.MapIndex(reflect.ValueOf(k).Convert(mv.Type().Key())).IsValid() {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			e := mv.MapIndex(reflect.ValueOf(k).Convert(mv.Type().Key()))
+			de, ok := d[k]
+			if !e.IsValid() || !ok {
+				return p, false
+			}
+			if p, ok := mismatch(p, e.Interface(), de); !ok {
+				return p, false
+			}
+		}
+		return "", true
+	}
+	switch mv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if dv.Kind() != reflect.Slice || mv.Len() != dv.Len() {
+			return path, false
+		}
+		for i := range mv.Len() {
+			if p, ok := mismatch(path+"["+strconv.Itoa(i)+"]", mv.Index(i).Interface(), dv.Index(i).Interface()); !ok {
+				return p, false
+			}
+		}
+		return "", true
+	case reflect.Bool:
+		d, ok := disk.(int8)
+		return path, ok && (d != 0) == mv.Bool()
+	case reflect.Float32, reflect.Float64:
+		if mv.Type() != dv.Type() {
+			return path, false
+		}
+		a, b := mv.Float(), dv.Float()
+		return path, a == b || (math.IsNaN(a) && math.IsNaN(b))
+	}
+	if mv.Type() != dv.Type() || !mv.Comparable() {
+		return path, false
+	}
+	return path, mem == disk
+}
+
+// verifyRewrite checks that the region file at tmp holds the same chunks,
+// uncompressed, as the region file at path.
+func verifyRewrite(path, tmp string) error {
+	src, err := OpenRegionFile(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := OpenRegionFile(tmp)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	want, err := src.Chunks()
+	if err != nil {
+		return err
+	}
+	got, err := dst.Chunks()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("%w: %d chunks instead of %d", ErrVerify, len(got), len(want))
+	}
+	for _, c := range want {
+		a, err := src.ReadChunkRaw(c[0], c[1])
+		if err != nil {
+			return fmt.Errorf("read chunk %d,%d: %w", c[0], c[1], err)
+		}
+		b, err := dst.ReadChunkRaw(c[0], c[1])
+		if err != nil || !bytes.Equal(a, b) {
+			return fmt.Errorf("%w: chunk %d,%d", ErrVerify, c[0], c[1])
+		}
+	}
+	return nil
+}
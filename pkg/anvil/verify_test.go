@@ -0,0 +1,80 @@
+package anvil
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// skewedInt encodes one more than its value, standing in for an encoder
+// that silently writes the wrong thing.
+type skewedInt int32
+
+func (v skewedInt) TagType() byte { return nbt.TagInt }
+
+func (v skewedInt) MarshalNBT(w io.Writer) error {
+	return binary.Write(w, binary.BigEndian, int32(v)+1)
+}
+
+func TestWriteVerified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	writeTestRegion(t, path, map[string]any{"Status": "full"})
+	r, err := OpenRegionFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+	r.SetWriteOptions(WriteOptions{Verify: true})
+
+	// Go types that decode as other types still verify.
+	good := map[string]any{
+		"Status":   "edited",
+		"keep":     true,
+		"heights":  []int64{1, 2},
+		"light":    []byte{0xff, 2},
+		"Palette":  map[string]string{"a": "b"},
+		"Sections": []map[string]any{{"Y": int8(0)}},
+	}
+	if err := r.WriteChunkNBT(0, 0, good); err != nil {
+		t.Fatalf("verified write: %v", err)
+	}
+	before, stamp, err := r.ReadChunkRecord(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.WriteChunkNBT(0, 0, map[string]any{"Status": "bad", "Level": map[string]any{"n": skewedInt(5)}})
+	if !errors.Is(err, ErrVerify) {
+		t.Fatalf("skewed write: %v", err)
+	}
+	after, stamp2, err := r.ReadChunkRecord(0, 0)
+	if err != nil || string(after) != string(before) || stamp2 != stamp {
+		t.Fatalf("chunk not restored: %v", err)
+	}
+
+	// A chunk that did not exist is removed again.
+	if err := r.WriteChunkNBT(5, 5, map[string]any{"n": skewedInt(1)}); !errors.Is(err, ErrVerify) {
+		t.Fatalf("skewed write of new chunk: %v", err)
+	}
+	if _, err := r.ReadChunkNBT(5, 5); !errors.Is(err, ErrChunkNotPresent) {
+		t.Fatalf("new chunk left behind: %v", err)
+	}
+}
+
+func TestMismatchPath(t *testing.T) {
+	mem := map[string]any{"a": map[string]any{"b": []any{int32(1), int32(2)}}}
+	disk := map[string]any{"a": map[string]any{"b": []any{int32(1), int32(3)}}}
+	if p, ok := mismatch("", mem, disk); ok || p != "a.b[1]" {
+		t.Fatalf("mismatch: %q %v", p, ok)
+	}
+	if p, ok := mismatch("", mem, map[string]any{"a": map[string]any{}}); ok || p != "a.b" {
+		t.Fatalf("missing key: %q %v", p, ok)
+	}
+	if _, ok := mismatch("", map[string]any{"x": int16(1)}, map[string]any{"x": int32(1)}); ok {
+		t.Fatal("different tag types compare equal")
+	}
+}
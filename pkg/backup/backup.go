@@ -0,0 +1,385 @@
+// Package backup saves the stored records of chunks, or whole region files,
+// before they are overwritten, and puts them back on request. A backup set
+// is one timestamped directory holding the saved bytes and a manifest with
+// one JSON line per saved item, appended as items are saved, so a set stays
+// usable even when the command that wrote it failed halfway. Sets that also
+// record what their command wrote serve as an undo journal: Verify checks
+// nothing changed since, and Restore reverts the command.
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+)
+
+const (
+	infoName     = "backup.json"
+	manifestName = "manifest.jsonl"
+	timeLayout   = "20060102-150405"
+)
+
+// Info describes a backup set.
+type Info struct {
+	Dir     string    `json:"-"`
+	Created time.Time `json:"created"`
+	Command string    `json:"command"`
+	// Undone records when the set was undone; see MarkUndone.
+	Undone  *time.Time `json:"undone,omitempty"`
+	Entries int        `json:"-"`
+}
+
+// Entry is one saved item. Kind "chunk" holds the record of chunk (X, Z)
+// of Region, in-region coordinates; File is empty when the chunk did not
+// exist. Kind "region" holds a copy of the whole file. Kind "written"
+// holds what a command wrote: the new record of a chunk in File, or the
+// SHA-256 of a rewritten region file in Sum.
+type Entry struct {
+	Kind      string `json:"kind"`
+	Region    string `json:"region"`
+	X         int    `json:"x,omitempty"`
+	Z         int    `json:"z,omitempty"`
+	Timestamp uint32 `json:"timestamp,omitempty"`
+	File      string `json:"file,omitempty"`
+	Sum       string `json:"sum,omitempty"`
+}
+
+type chunkKey struct {
+	region string
+	x, z   int
+}
+
+// Set is a backup set being written. Its directory is created on the first
+// save. Methods are safe for concurrent use.
+type Set struct {
+	// OnCreate, when set, is called with the directory once it is created.
+	OnCreate func(dir string)
+
+	root    string
+	command string
+
+	mu       sync.Mutex
+	dir      string
+	manifest *os.File
+	chunks   map[chunkKey]bool
+	files    map[string]bool
+	n        int
+}
+
+// New returns a set that will be written to a new timestamped directory
+// under root. command is recorded for listings.
+func New(root, command string) *Set {
+	return &Set{root: root, command: command, chunks: map[chunkKey]bool{}, files: map[string]bool{}}
+}
+
+// Dir returns the set's directory, or "" when nothing was saved yet.
+func (b *Set) Dir() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dir
+}
+
+// SaveChunk saves the current record of chunk (cx, cz) of r, or notes its
+// absence. Only the first call per chunk saves anything, so the set keeps
+// the state from before the command. It has the signature of
+// anvil.WriteHook.
+func (b *Set) SaveChunk(r *anvil.Region, cx, cz int) error {
+	region, err := filepath.Abs(r.Path())
+	if err != nil {
+		return err
+	}
+	key := chunkKey{region, cx, cz}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.chunks[key] || b.files[region] {
+		return nil
+	}
+	record, ts, err := r.ReadChunkRecord(cx, cz)
+	if err != nil && !errors.Is(err, anvil.ErrChunkNotPresent) {
+		return fmt.Errorf("backup: %w", err)
+	}
+	e := Entry{Kind: "chunk", Region: region, X: cx, Z: cz, Timestamp: ts}
+	if record != nil {
+		e.File = fmt.Sprintf("chunks/%06d.bin", b.n)
+	}
+	if err := b.add(e, record); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	b.chunks[key] = true
+	return nil
+}
+
+// SaveFile copies the region file at path into the set.
+func (b *Set) SaveFile(path string) error {
+	region, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.files[region] {
+		return nil
+	}
+	data, err := os.ReadFile(region)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	e := Entry{Kind: "region", Region: region, File: fmt.Sprintf("regions/%06d.mca", b.n)}
+	if err := b.add(e, data); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	b.files[region] = true
+	return nil
+}
+
+// add writes data to e.File and appends e to the manifest.
+func (b *Set) add(e Entry, data []byte) error {
+	if err := b.open(); err != nil {
+		return err
+	}
+	if e.File != "" {
+		p := filepath.Join(b.dir, filepath.FromSlash(e.File))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := b.manifest.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	b.n++
+	return nil
+}
+
+func (b *Set) open() error {
+	if b.manifest != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := os.MkdirAll(b.root, 0o755); err != nil {
+		return err
+	}
+	base := filepath.Join(b.root, now.Format(timeLayout))
+	dir := base
+	for i := 2; ; i++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		dir = fmt.Sprintf("%s-%d", base, i)
+	}
+	info, err := json.MarshalIndent(Info{Created: now, Command: b.command}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, infoName), info, 0o644); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, manifestName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	b.dir, b.manifest = dir, f
+	if b.OnCreate != nil {
+		b.OnCreate(dir)
+	}
+	return nil
+}
+
+// Close closes the manifest.
+func (b *Set) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.manifest == nil {
+		return nil
+	}
+	err := b.manifest.Close()
+	b.manifest = nil
+	return err
+}
+
+// Read returns the description and entries of the backup set in dir.
+func Read(dir string) (Info, []Entry, error) {
+	var info Info
+	data, err := os.ReadFile(filepath.Join(dir, infoName))
+	if err != nil {
+		return info, nil, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, nil, fmt.Errorf("%s: %w", infoName, err)
+	}
+	info.Dir = dir
+	f, err := os.Open(filepath.Join(dir, manifestName))
+	if err != nil {
+		return info, nil, err
+	}
+	defer f.Close()
+	var lines [][]byte
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, append([]byte(nil), sc.Bytes()...))
+	}
+	if err := sc.Err(); err != nil {
+		return info, nil, err
+	}
+	var entries []Entry
+	for i, line := range lines {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// A command killed mid-write leaves a partial last line.
+			if i == len(lines)-1 {
+				break
+			}
+			return info, nil, fmt.Errorf("%s line %d: %w", manifestName, i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	info.Entries = len(entries)
+	return info, entries, nil
+}
+
+// List returns the backup sets under root, oldest first.
+func List(root string) ([]Info, error) {
+	dirs, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Info
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		info, _, err := Read(filepath.Join(root, d.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Name(), err)
+		}
+		out = append(out, info)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+// Prune selects the sets under root to remove: all but the newest keep
+// that were also created before before, when before is not zero. Unless
+// dryRun is set they are deleted. It returns the selected sets, oldest
+// first.
+func Prune(root string, keep int, before time.Time, dryRun bool) ([]Info, error) {
+	sets, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	var out []Info
+	for _, s := range sets[:max(len(sets)-keep, 0)] {
+		if !before.IsZero() && !s.Created.Before(before) {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(s.Dir); err != nil {
+				return out, err
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Plan returns the items Restore would put back, in the order it would.
+func Plan(dir string) ([]Entry, error) {
+	_, entries, err := Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind != "written" {
+			out = append(out, entries[i])
+		}
+	}
+	return out, nil
+}
+
+// Restore puts every saved item of the backup set in dir back, newest
+// first, and returns how many were restored. Chunks that did not exist are
+// removed.
+func Restore(dir string) (int, error) {
+	entries, err := Plan(dir)
+	if err != nil {
+		return 0, err
+	}
+	regions := map[string]*anvil.Region{}
+	defer func() {
+		for _, r := range regions {
+			r.Close()
+		}
+	}()
+	n := 0
+	for _, e := range entries {
+		var data []byte
+		if e.File != "" {
+			if data, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.File))); err != nil {
+				return n, err
+			}
+		}
+		switch e.Kind {
+		case "region":
+			if r, ok := regions[e.Region]; ok {
+				r.Close()
+				delete(regions, e.Region)
+			}
+			if err := replaceFile(e.Region, data); err != nil {
+				return n, err
+			}
+		case "chunk":
+			r, ok := regions[e.Region]
+			if !ok {
+				if r, err = anvil.OpenRegionFile(e.Region); err != nil {
+					return n, err
+				}
+				regions[e.Region] = r
+			}
+			if err := r.WriteChunkRecord(e.X, e.Z, data, e.Timestamp); err != nil {
+				return n, fmt.Errorf("%s: chunk %d,%d: %w", e.Region, e.X, e.Z, err)
+			}
+		default:
+			return n, fmt.Errorf("unknown backup entry kind %q", e.Kind)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o666); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+)
+
+func newRegion(t *testing.T, path string) *anvil.Region {
+	t.Helper()
+	if err := os.WriteFile(path, make([]byte, 8192), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := anvil.OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRestoreChunks(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	r := newRegion(t, path)
+	if err := r.WriteChunkNBT(1, 2, map[string]any{"Status": "before"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = anvil.OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := New(filepath.Join(tmp, "backups"), "test")
+	for i, c := range [][2]int{{1, 2}, {3, 4}, {1, 2}} {
+		if err := set.SaveChunk(r, c[0], c[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.WriteChunkNBT(c[0], c[1], map[string]any{"Status": "after", "N": int32(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+	set.Close()
+
+	info, entries, err := Read(set.Dir())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if info.Command != "test" || len(entries) != 2 {
+		t.Fatalf("info %+v, entries %+v", info, entries)
+	}
+	if entries[1].File != "" {
+		t.Fatalf("absent chunk saved a record: %+v", entries[1])
+	}
+
+	if n, err := Restore(set.Dir()); err != nil || n != 2 {
+		t.Fatalf("Restore: n=%d err=%v", n, err)
+	}
+	r, err = anvil.OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := r.ReadChunkNBT(1, 2)
+	if err != nil || got["Status"] != "before" {
+		t.Fatalf("restored chunk: %v %v", got, err)
+	}
+	if _, err := r.ReadChunkNBT(3, 4); !errors.Is(err, anvil.ErrChunkNotPresent) {
+		t.Fatalf("created chunk not removed: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+	// The restored record may sit in other sectors, but header timestamps
+	// and the chunk must match.
+	if string(after[4096:8192]) != string(before[4096:8192]) {
+		t.Error("timestamps differ after restore")
+	}
+}
+
+func TestRestoreRegionFile(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	r := newRegion(t, path)
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "before"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	before, _ := os.ReadFile(path)
+
+	root := filepath.Join(tmp, "backups")
+	set := New(root, "recompress")
+	if err := set.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	set.Close()
+	if _, err := anvil.RewriteRegion(path, anvil.WriteOptions{Compression: anvil.CompressionNone}); err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := List(root)
+	if err != nil || len(sets) != 1 || sets[0].Entries != 1 {
+		t.Fatalf("List: %+v %v", sets, err)
+	}
+	if _, err := Restore(sets[0].Dir); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("region file differs after restore")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	newRegion(t, path).Close()
+	root := filepath.Join(tmp, "backups")
+	for _, cmd := range []string{"a", "b", "c"} {
+		set := New(root, cmd)
+		if err := set.SaveFile(path); err != nil {
+			t.Fatal(err)
+		}
+		set.Close()
+	}
+
+	if gone, err := Prune(root, 1, time.Now().Add(-time.Hour), false); err != nil || len(gone) != 0 {
+		t.Fatalf("sets older than an hour: %+v %v", gone, err)
+	}
+	gone, err := Prune(root, 1, time.Time{}, true)
+	if err != nil || len(gone) != 2 || gone[0].Command != "a" {
+		t.Fatalf("dry run: %+v %v", gone, err)
+	}
+	if sets, _ := List(root); len(sets) != 3 {
+		t.Fatalf("dry run removed sets: %+v", sets)
+	}
+	if _, err := Prune(root, 1, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if sets, _ := List(root); len(sets) != 1 || sets[0].Command != "c" {
+		t.Fatalf("kept %+v", sets)
+	}
+}
@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+)
+
+// ErrChanged is returned by Verify when chunks or files no longer hold what
+// the set's command wrote.
+var ErrChanged = errors.New("changed since the command ran")
+
+// SaveWritten records the record chunk (cx, cz) of r holds after a write.
+// It has the signature of anvil.WriteHook, to run after each write.
+func (b *Set) SaveWritten(r *anvil.Region, cx, cz int) error {
+	region, err := filepath.Abs(r.Path())
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	record, ts, err := r.ReadChunkRecord(cx, cz)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	e := Entry{Kind: "written", Region: region, X: cx, Z: cz, Timestamp: ts, File: fmt.Sprintf("written/%06d.bin", b.n)}
+	if err := b.add(e, record); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// SaveWrittenFile records the checksum of the region file at path after a
+// command rewrote it.
+func (b *Set) SaveWrittenFile(path string) error {
+	region, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSum(region)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.add(Entry{Kind: "written", Region: region, Sum: sum}, nil); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// Verify checks that every chunk and file the set's command wrote still
+// holds what was written. It returns an error wrapping ErrChanged that
+// names the first few differences.
+func Verify(dir string) error {
+	_, entries, err := Read(dir)
+	if err != nil {
+		return err
+	}
+	type key struct {
+		region string
+		x, z   int
+	}
+	last := map[key]Entry{}
+	var order []key
+	for _, e := range entries {
+		if e.Kind != "written" {
+			continue
+		}
+		k := key{e.Region, e.X, e.Z}
+		if e.Sum != "" {
+			k.x, k.z = -1, -1
+		}
+		if _, ok := last[k]; !ok {
+			order = append(order, k)
+		}
+		last[k] = e
+	}
+
+	var changed []string
+	regions := map[string]*anvil.Region{}
+	defer func() {
+		for _, r := range regions {
+			r.Close()
+		}
+	}()
+	for _, k := range order {
+		e := last[k]
+		if e.Sum != "" {
+			sum, err := fileSum(e.Region)
+			if err != nil {
+				return err
+			}
+			if sum != e.Sum {
+				changed = append(changed, e.Region)
+			}
+			continue
+		}
+		want, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.File)))
+		if err != nil {
+			return err
+		}
+		r, ok := regions[e.Region]
+		if !ok {
+			if r, err = anvil.OpenRegionFile(e.Region); err != nil {
+				return err
+			}
+			regions[e.Region] = r
+		}
+		got, _, err := r.ReadChunkRecord(e.X, e.Z)
+		if err != nil && !errors.Is(err, anvil.ErrChunkNotPresent) {
+			return fmt.Errorf("%s: chunk %d,%d: %w", e.Region, e.X, e.Z, err)
+		}
+		if !bytes.Equal(got, want) {
+			changed = append(changed, fmt.Sprintf("%s chunk %d,%d", e.Region, e.X, e.Z))
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if len(changed) > 3 {
+		changed = append(changed[:3], fmt.Sprintf("%d more", len(changed)-3))
+	}
+	return fmt.Errorf("%w: %s", ErrChanged, strings.Join(changed, ", "))
+}
+
+// MarkUndone records in the set in dir that it was restored, so journals
+// skip it.
+func MarkUndone(dir string) error {
+	info, _, err := Read(dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	info.Undone = &now
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(filepath.Join(dir, infoName), data)
+}
+
+func fileSum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+)
+
+func TestVerifyAndUndo(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "r.0.0.mca")
+	r := newRegion(t, path)
+	defer r.Close()
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "before"}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(tmp, "history")
+	set := New(root, "edit")
+	if err := set.SaveChunk(r, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.SaveWritten(r, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	set.Close()
+
+	if err := Verify(set.Dir()); err != nil {
+		t.Fatalf("Verify of untouched chunk: %v", err)
+	}
+	if err := r.WriteChunkNBT(0, 0, map[string]any{"Status": "later"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(set.Dir()); !errors.Is(err, ErrChanged) {
+		t.Fatalf("Verify after later edit: %v", err)
+	}
+
+	if _, err := Restore(set.Dir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkUndone(set.Dir()); err != nil {
+		t.Fatal(err)
+	}
+	sets, err := List(root)
+	if err != nil || len(sets) != 1 || sets[0].Undone == nil || sets[0].Command != "edit" {
+		t.Fatalf("List: %+v %v", sets, err)
+	}
+	// Restore goes through a fresh handle, so reopen to see its header.
+	r2, err := anvil.OpenRegionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	got, err := r2.ReadChunkNBT(0, 0)
+	if err != nil || got["Status"] != "before" {
+		t.Fatalf("undone chunk: %v %v", got, err)
+	}
+}
@@ -0,0 +1,95 @@
+package chunkedit
+
+import (
+	"fmt"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+const biomeCells = 64
+
+func biomeKey(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func biomeIndex(x, y, z int) int {
+	lx := coords.FloorMod(x, 16) >> 2
+	ly := coords.FloorMod(y, 16) >> 2
+	lz := coords.FloorMod(z, 16) >> 2
+	return ly<<4 | lz<<2 | lx
+}
+
+func loadBiomes(chunk map[string]any, y int) (map[string]any, *palettedContainer, error) {
+	sec, err := sectionFor(chunk, y)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := asMap(sec["biomes"])
+	if !ok {
+		return nil, nil, fmt.Errorf("section %d has no biomes", coords.FloorDiv(y, 16))
+	}
+	pc, err := decodeContainer(m, biomeCells, 1, biomeKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode biomes: %w", err)
+	}
+	return m, pc, nil
+}
+
+// GetBiome returns the biome of the 4x4x4 cell containing block (x, y, z).
+func GetBiome(chunk map[string]any, x, y, z int) (string, error) {
+	_, pc, err := loadBiomes(chunk, y)
+	if err != nil {
+		return "", err
+	}
+	return biomeKey(pc.get(biomeIndex(x, y, z))), nil
+}
+
+// SetBiome sets the biome of the 4x4x4 cell containing block (x, y, z) and
+// reports whether the stored value changed.
+func SetBiome(chunk map[string]any, x, y, z int, biome string) (bool, error) {
+	m, pc, err := loadBiomes(chunk, y)
+	if err != nil {
+		return false, err
+	}
+	if !pc.set(biomeIndex(x, y, z), biome) {
+		return false, nil
+	}
+	pc.encode(m, 1)
+	return true, nil
+}
+
+// SetBiomeBox sets every biome cell overlapping box within this chunk and
+// returns the number of cells changed.
+func SetBiomeBox(chunk map[string]any, box coords.Box, biome string) (int, error) {
+	changed := 0
+	for sy := coords.FloorDiv(box.MinY, 16); sy <= coords.FloorDiv(box.MaxY, 16); sy++ {
+		sec, err := findSection(chunk, sy)
+		if err != nil {
+			return changed, err
+		}
+		m, ok := asMap(sec["biomes"])
+		if !ok {
+			return changed, fmt.Errorf("section %d has no biomes", sy)
+		}
+		pc, err := decodeContainer(m, biomeCells, 1, biomeKey)
+		if err != nil {
+			return changed, fmt.Errorf("decode biomes: %w", err)
+		}
+		n := 0
+		for y := max(box.MinY, sy*16) &^ 3; y <= min(box.MaxY, sy*16+15); y += 4 {
+			for z := box.MinZ &^ 3; z <= box.MaxZ; z += 4 {
+				for x := box.MinX &^ 3; x <= box.MaxX; x += 4 {
+					if pc.set(biomeIndex(x, y, z), biome) {
+						n++
+					}
+				}
+			}
+		}
+		if n > 0 {
+			pc.encode(m, 1)
+			changed += n
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,71 @@
+package chunkedit
+
+import (
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func testBiomeChunk() map[string]any {
+	return map[string]any{
+		"sections": []any{
+			map[string]any{
+				"Y":      int8(4),
+				"biomes": map[string]any{"palette": []any{"minecraft:plains"}},
+			},
+		},
+	}
+}
+
+func TestGetSetBiome(t *testing.T) {
+	chunk := testBiomeChunk()
+
+	got, err := GetBiome(chunk, 5, 70, 9)
+	if err != nil {
+		t.Fatalf("GetBiome: %v", err)
+	}
+	if got != "minecraft:plains" {
+		t.Fatalf("biome: got %q, want minecraft:plains", got)
+	}
+
+	changed, err := SetBiome(chunk, 5, 70, 9, "minecraft:desert")
+	if err != nil || !changed {
+		t.Fatalf("SetBiome: changed=%v err=%v", changed, err)
+	}
+	if got, _ := GetBiome(chunk, 4, 68, 8); got != "minecraft:desert" {
+		t.Fatalf("same cell: got %q, want minecraft:desert", got)
+	}
+	if got, _ := GetBiome(chunk, 0, 70, 9); got != "minecraft:plains" {
+		t.Fatalf("neighbour cell: got %q, want minecraft:plains", got)
+	}
+
+	sec, _ := findSection(chunk, 4)
+	biomes := sec["biomes"].(map[string]any)
+	if data, ok := biomes["data"].([]int64); !ok || len(data) != 1 {
+		t.Fatalf("expected 1 packed long, got %#v", biomes["data"])
+	}
+
+	if _, err := GetBiome(chunk, 0, 0, 0); err == nil {
+		t.Fatalf("expected error for missing section")
+	}
+}
+
+func TestSetBiomeBox(t *testing.T) {
+	chunk := testBiomeChunk()
+
+	n, err := SetBiomeBox(chunk, coords.NewBox(0, 64, 0, 15, 79, 15), "minecraft:ocean")
+	if err != nil {
+		t.Fatalf("SetBiomeBox: %v", err)
+	}
+	if n != biomeCells {
+		t.Fatalf("cells changed: got %d, want %d", n, biomeCells)
+	}
+	sec, _ := findSection(chunk, 4)
+	biomes := sec["biomes"].(map[string]any)
+	if _, ok := biomes["data"]; ok {
+		t.Fatalf("expected single-entry palette without data")
+	}
+	if p := biomes["palette"].([]any); len(p) != 1 || p[0] != "minecraft:ocean" {
+		t.Fatalf("palette: got %v", p)
+	}
+}
@@ -1,3 +1,7 @@
+// Package chunkedit inspects and edits decoded chunk NBT: block states,
+// biomes, block entities, heightmaps and light in terrain chunks, entities
+// in entity chunks and records in POI chunks. Positions are absolute block
+// coordinates.
 package chunkedit
 
 import (
@@ -39,8 +43,12 @@ func intsEqual(a any, b int) bool {
 	}
 }
 
+// BlockEntityKeys are the chunk tags that may hold block entities, newest
+// format first. Partial decoders only need these to list block entities.
+var BlockEntityKeys = []string{"block_entities", "BlockEntities", "TileEntities"}
+
 func findBlockEntityIndex(chunk map[string]any, x, y, z int) (int, string, map[string]any) {
-	arr, key := getArray(chunk, "block_entities", "BlockEntities", "TileEntities")
+	arr, key := getArray(chunk, BlockEntityKeys...)
 	if arr == nil {
 		return -1, "", nil
 	}
@@ -59,6 +67,29 @@ func findBlockEntityIndex(chunk map[string]any, x, y, z int) (int, string, map[s
 	return -1, key, nil
 }
 
+// ListBlockEntities returns the chunk's block entity compounds as stored,
+// without copying them. Their x, y and z tags hold absolute positions;
+// BlockEntityPos reads them.
+func ListBlockEntities(chunk map[string]any) []map[string]any {
+	arr, _ := getArray(chunk, BlockEntityKeys...)
+	out := make([]map[string]any, 0, len(arr))
+	for _, v := range arr {
+		if m, ok := asMap(v); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// BlockEntityPos returns the absolute position stored in a block entity.
+func BlockEntityPos(ent map[string]any) (x, y, z int, ok bool) {
+	x, xok := asInt(ent["x"])
+	y, yok := asInt(ent["y"])
+	z, zok := asInt(ent["z"])
+	return x, y, z, xok && yok && zok
+}
+
+// GetBlockEntity returns the block entity of chunk at absolute (x, y, z).
 func GetBlockEntity(chunk map[string]any, x, y, z int) (map[string]any, bool) {
 	_, key, ent := findBlockEntityIndex(chunk, x, y, z)
 	if key == "" || ent == nil {
@@ -67,6 +98,8 @@ func GetBlockEntity(chunk map[string]any, x, y, z int) (map[string]any, bool) {
 	return ent, true
 }
 
+// DeleteBlockEntity removes the block entity at absolute (x, y, z) and
+// reports whether there was one.
 func DeleteBlockEntity(chunk map[string]any, x, y, z int) bool {
 	idx, key, _ := findBlockEntityIndex(chunk, x, y, z)
 	if key == "" || idx < 0 {
@@ -78,13 +111,16 @@ func DeleteBlockEntity(chunk map[string]any, x, y, z int) bool {
 	return true
 }
 
+// CreateOrUpdateBlockEntity merges data into the block entity at absolute
+// (x, y, z), creating it with id when there is none. An empty id keeps the
+// stored one.
 func CreateOrUpdateBlockEntity(chunk map[string]any, x, y, z int, id string, data map[string]any) {
 	idx, key, ent := findBlockEntityIndex(chunk, x, y, z)
 	if key == "" {
 		key = "block_entities"
 	}
 	if idx < 0 || ent == nil {
-		ent = map[string]any{"x": x, "y": y, "z": z}
+		ent = map[string]any{"x": int32(x), "y": int32(y), "z": int32(z)}
 		if id != "" {
 			ent["id"] = id
 		}
@@ -104,6 +140,8 @@ func CreateOrUpdateBlockEntity(chunk map[string]any, x, y, z int, id string, dat
 	}
 }
 
+// MergeJSONData copies the top-level fields of the JSON object raw into dst.
+// An empty raw is a no-op.
 func MergeJSONData(dst map[string]any, raw string) error {
 	if raw == "" {
 		return nil
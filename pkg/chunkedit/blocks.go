@@ -0,0 +1,421 @@
+package chunkedit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+const blockCells = 4096
+
+// BlockState is a block name with its properties, as stored in a section
+// palette.
+type BlockState struct {
+	Name       string
+	Properties map[string]string
+}
+
+// ParseBlockState parses the command syntax name[key=value,...]; a name
+// without a namespace is assumed to be minecraft:.
+func ParseBlockState(s string) (BlockState, error) {
+	s = strings.TrimSpace(s)
+	var st BlockState
+	name, props, hasProps := strings.Cut(s, "[")
+	if hasProps {
+		if !strings.HasSuffix(props, "]") {
+			return st, fmt.Errorf("invalid block state %q: missing ]", s)
+		}
+		props = strings.TrimSuffix(props, "]")
+	}
+	if name == "" {
+		return st, fmt.Errorf("invalid block state %q: empty name", s)
+	}
+	if !strings.Contains(name, ":") {
+		name = "minecraft:" + name
+	}
+	st.Name = name
+	if strings.TrimSpace(props) == "" {
+		return st, nil
+	}
+	st.Properties = map[string]string{}
+	for _, kv := range strings.Split(props, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return st, fmt.Errorf("invalid block state %q: bad property %q", s, kv)
+		}
+		st.Properties[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return st, nil
+}
+
+// String formats s in the syntax ParseBlockState reads, with properties
+// sorted by key.
+func (s BlockState) String() string {
+	if len(s.Properties) == 0 {
+		return s.Name
+	}
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + s.Properties[k]
+	}
+	return s.Name + "[" + strings.Join(parts, ",") + "]"
+}
+
+// Matches reports whether s has the same name as pattern and agrees with
+// every property pattern sets; properties pattern omits are ignored.
+func (s BlockState) Matches(pattern BlockState) bool {
+	if s.Name != pattern.Name {
+		return false
+	}
+	for k, v := range pattern.Properties {
+		if s.Properties[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAir reports whether s is one of the air blocks.
+func (s BlockState) IsAir() bool {
+	switch s.Name {
+	case "minecraft:air", "minecraft:cave_air", "minecraft:void_air":
+		return true
+	}
+	return false
+}
+
+func (s BlockState) toNBT() map[string]any {
+	m := map[string]any{"Name": s.Name}
+	if len(s.Properties) > 0 {
+		props := make(map[string]any, len(s.Properties))
+		for k, v := range s.Properties {
+			props[k] = v
+		}
+		m["Properties"] = props
+	}
+	return m
+}
+
+func blockStateFromNBT(v any) BlockState {
+	m, _ := asMap(v)
+	var st BlockState
+	st.Name, _ = m["Name"].(string)
+	if props, ok := asMap(m["Properties"]); ok && len(props) > 0 {
+		st.Properties = make(map[string]string, len(props))
+		for k, pv := range props {
+			st.Properties[k] = fmt.Sprint(pv)
+		}
+	}
+	return st
+}
+
+func blockStateKey(v any) string {
+	return blockStateFromNBT(v).String()
+}
+
+func blockIndex(x, y, z int) int {
+	return coords.FloorMod(y, 16)<<8 | coords.FloorMod(z, 16)<<4 | coords.FloorMod(x, 16)
+}
+
+func loadBlockStates(chunk map[string]any, sy int) (map[string]any, *palettedContainer, error) {
+	sec, err := findSection(chunk, sy)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := asMap(sec["block_states"])
+	if !ok {
+		return nil, nil, fmt.Errorf("section %d has no block_states", sy)
+	}
+	pc, err := decodeContainer(m, blockCells, 4, blockStateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode block states: %w", err)
+	}
+	return m, pc, nil
+}
+
+// GetBlockState returns the block at (x, y, z) in chunk. Coordinates are
+// absolute; only their position within the chunk is used.
+func GetBlockState(chunk map[string]any, x, y, z int) (BlockState, error) {
+	_, pc, err := loadBlockStates(chunk, coords.FloorDiv(y, 16))
+	if err != nil {
+		return BlockState{}, err
+	}
+	return blockStateFromNBT(pc.get(blockIndex(x, y, z))), nil
+}
+
+// VisitBlocks calls fn for every block of box inside this chunk, decoding
+// each section once.
+func VisitBlocks(chunk map[string]any, box coords.Box, fn func(x, y, z int, st BlockState)) error {
+	for sy := coords.FloorDiv(box.MinY, 16); sy <= coords.FloorDiv(box.MaxY, 16); sy++ {
+		_, pc, err := loadBlockStates(chunk, sy)
+		if err != nil {
+			return err
+		}
+		states := make([]BlockState, len(pc.palette))
+		for i, p := range pc.palette {
+			states[i] = blockStateFromNBT(p)
+		}
+		for y := max(box.MinY, sy*16); y <= min(box.MaxY, sy*16+15); y++ {
+			for z := box.MinZ; z <= box.MaxZ; z++ {
+				for x := box.MinX; x <= box.MaxX; x++ {
+					fn(x, y, z, states[pc.indices[blockIndex(x, y, z)]])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SetBlockState sets the block at absolute (x, y, z) in chunk and reports
+// whether it changed. Block entities are kept in sync, as with FillBox.
+func SetBlockState(chunk map[string]any, x, y, z int, state BlockState) (bool, error) {
+	n, err := FillBox(chunk, coords.NewBox(x, y, z, x, y, z), state)
+	return n > 0, err
+}
+
+// FillBox sets every block of box inside this chunk to state and returns
+// the number of blocks changed. Block entities are kept in sync.
+func FillBox(chunk map[string]any, box coords.Box, state BlockState) (int, error) {
+	return editBlocks(chunk, box, func(BlockState) (BlockState, bool) {
+		return state, true
+	})
+}
+
+// ReplaceBox sets every block of box inside this chunk that matches match
+// to with and returns the number of blocks changed.
+func ReplaceBox(chunk map[string]any, box coords.Box, match, with BlockState) (int, error) {
+	return editBlocks(chunk, box, func(cur BlockState) (BlockState, bool) {
+		return with, cur.Matches(match)
+	})
+}
+
+type blockPos struct{ x, y, z int }
+
+// editBlocks applies fn to every block of box inside this chunk, decoding
+// and re-encoding each affected section once. fn is evaluated once per
+// distinct palette entry rather than once per block.
+func editBlocks(chunk map[string]any, box coords.Box, fn func(BlockState) (BlockState, bool)) (int, error) {
+	changed := map[blockPos]BlockState{}
+	for sy := coords.FloorDiv(box.MinY, 16); sy <= coords.FloorDiv(box.MaxY, 16); sy++ {
+		m, pc, err := loadBlockStates(chunk, sy)
+		if err != nil {
+			return 0, err
+		}
+		type decision struct {
+			state  BlockState
+			target int
+		}
+		decided := map[int]decision{}
+		dirty := false
+		for y := max(box.MinY, sy*16); y <= min(box.MaxY, sy*16+15); y++ {
+			for z := box.MinZ; z <= box.MaxZ; z++ {
+				for x := box.MinX; x <= box.MaxX; x++ {
+					i := blockIndex(x, y, z)
+					cur := pc.indices[i]
+					d, seen := decided[cur]
+					if !seen {
+						d.target = cur
+						cs := blockStateFromNBT(pc.palette[cur])
+						if st, ok := fn(cs); ok && st.String() != cs.String() {
+							d.state, d.target = st, pc.indexOf(st.toNBT())
+						}
+						decided[cur] = d
+					}
+					if d.target == cur {
+						continue
+					}
+					pc.indices[i] = d.target
+					changed[blockPos{x, y, z}] = d.state
+					dirty = true
+				}
+			}
+		}
+		if dirty {
+			pc.encode(m, 4)
+		}
+	}
+	syncBlockEntities(chunk, changed)
+	return len(changed), nil
+}
+
+// PlaceBlocks writes blocks from palette into box inside this chunk. fn
+// returns the palette index for each position, or -1 to leave the block
+// untouched. It returns the number of blocks changed.
+func PlaceBlocks(chunk map[string]any, box coords.Box, palette []BlockState, fn func(x, y, z int) int) (int, error) {
+	changed := map[blockPos]BlockState{}
+	keys := make([]string, len(palette))
+	for i, st := range palette {
+		keys[i] = st.String()
+	}
+	for sy := coords.FloorDiv(box.MinY, 16); sy <= coords.FloorDiv(box.MaxY, 16); sy++ {
+		m, pc, err := loadBlockStates(chunk, sy)
+		if err != nil {
+			return 0, err
+		}
+		current := make([]string, len(pc.palette))
+		for i, p := range pc.palette {
+			current[i] = blockStateKey(p)
+		}
+		target := make([]int, len(palette))
+		for i := range target {
+			target[i] = -1
+		}
+		dirty := false
+		for y := max(box.MinY, sy*16); y <= min(box.MaxY, sy*16+15); y++ {
+			for z := box.MinZ; z <= box.MaxZ; z++ {
+				for x := box.MinX; x <= box.MaxX; x++ {
+					p := fn(x, y, z)
+					if p < 0 {
+						continue
+					}
+					i := blockIndex(x, y, z)
+					cur := pc.indices[i]
+					if cur == target[p] || (cur < len(current) && current[cur] == keys[p]) {
+						continue
+					}
+					if target[p] < 0 {
+						target[p] = pc.indexOf(palette[p].toNBT())
+					}
+					pc.indices[i] = target[p]
+					changed[blockPos{x, y, z}] = palette[p]
+					dirty = true
+				}
+			}
+		}
+		if dirty {
+			pc.encode(m, 4)
+		}
+	}
+	syncBlockEntities(chunk, changed)
+	return len(changed), nil
+}
+
+// syncBlockEntities drops block entities at changed positions whose type no
+// longer matches the new block and creates empty ones for new blocks that
+// need them.
+func syncBlockEntities(chunk map[string]any, changed map[blockPos]BlockState) {
+	if len(changed) == 0 {
+		return
+	}
+	arr, key := getArray(chunk, "block_entities", "BlockEntities", "TileEntities")
+	if key == "" {
+		key = "block_entities"
+	}
+	kept := make([]any, 0, len(arr))
+	have := map[blockPos]bool{}
+	for _, v := range arr {
+		m, ok := asMap(v)
+		if !ok {
+			kept = append(kept, v)
+			continue
+		}
+		x, _ := asInt(m["x"])
+		y, _ := asInt(m["y"])
+		z, _ := asInt(m["z"])
+		pos := blockPos{x, y, z}
+		st, ok := changed[pos]
+		if !ok {
+			kept = append(kept, v)
+			continue
+		}
+		id, _ := m["id"].(string)
+		if want, ok := BlockEntityID(st.Name); ok && want == id {
+			kept = append(kept, v)
+			have[pos] = true
+		}
+	}
+	for pos, st := range changed {
+		id, ok := BlockEntityID(st.Name)
+		if !ok || have[pos] {
+			continue
+		}
+		kept = append(kept, newBlockEntity(pos.x, pos.y, pos.z, id))
+	}
+	chunk[key] = kept
+}
+
+func newBlockEntity(x, y, z int, id string) map[string]any {
+	return map[string]any{
+		"id":         id,
+		"x":          int32(x),
+		"y":          int32(y),
+		"z":          int32(z),
+		"keepPacked": int8(0),
+	}
+}
+
+var blockEntityIDs = map[string]string{
+	"minecraft:barrel":                  "minecraft:barrel",
+	"minecraft:beacon":                  "minecraft:beacon",
+	"minecraft:bee_nest":                "minecraft:beehive",
+	"minecraft:beehive":                 "minecraft:beehive",
+	"minecraft:bell":                    "minecraft:bell",
+	"minecraft:blast_furnace":           "minecraft:blast_furnace",
+	"minecraft:brewing_stand":           "minecraft:brewing_stand",
+	"minecraft:calibrated_sculk_sensor": "minecraft:calibrated_sculk_sensor",
+	"minecraft:campfire":                "minecraft:campfire",
+	"minecraft:chain_command_block":     "minecraft:command_block",
+	"minecraft:chest":                   "minecraft:chest",
+	"minecraft:chiseled_bookshelf":      "minecraft:chiseled_bookshelf",
+	"minecraft:command_block":           "minecraft:command_block",
+	"minecraft:comparator":              "minecraft:comparator",
+	"minecraft:conduit":                 "minecraft:conduit",
+	"minecraft:crafter":                 "minecraft:crafter",
+	"minecraft:daylight_detector":       "minecraft:daylight_detector",
+	"minecraft:decorated_pot":           "minecraft:decorated_pot",
+	"minecraft:dispenser":               "minecraft:dispenser",
+	"minecraft:dropper":                 "minecraft:dropper",
+	"minecraft:enchanting_table":        "minecraft:enchanting_table",
+	"minecraft:end_gateway":             "minecraft:end_gateway",
+	"minecraft:end_portal":              "minecraft:end_portal",
+	"minecraft:ender_chest":             "minecraft:ender_chest",
+	"minecraft:furnace":                 "minecraft:furnace",
+	"minecraft:hopper":                  "minecraft:hopper",
+	"minecraft:jigsaw":                  "minecraft:jigsaw",
+	"minecraft:jukebox":                 "minecraft:jukebox",
+	"minecraft:lectern":                 "minecraft:lectern",
+	"minecraft:moving_piston":           "minecraft:piston",
+	"minecraft:repeating_command_block": "minecraft:command_block",
+	"minecraft:sculk_catalyst":          "minecraft:sculk_catalyst",
+	"minecraft:sculk_sensor":            "minecraft:sculk_sensor",
+	"minecraft:sculk_shrieker":          "minecraft:sculk_shrieker",
+	"minecraft:shulker_box":             "minecraft:shulker_box",
+	"minecraft:smoker":                  "minecraft:smoker",
+	"minecraft:soul_campfire":           "minecraft:campfire",
+	"minecraft:spawner":                 "minecraft:mob_spawner",
+	"minecraft:structure_block":         "minecraft:structure_block",
+	"minecraft:suspicious_gravel":       "minecraft:brushable_block",
+	"minecraft:suspicious_sand":         "minecraft:brushable_block",
+	"minecraft:trapped_chest":           "minecraft:trapped_chest",
+	"minecraft:trial_spawner":           "minecraft:trial_spawner",
+	"minecraft:vault":                   "minecraft:vault",
+}
+
+// BlockEntityID returns the block entity id a block needs, if any.
+func BlockEntityID(name string) (string, bool) {
+	if id, ok := blockEntityIDs[name]; ok {
+		return id, true
+	}
+	switch {
+	case strings.HasSuffix(name, "_hanging_sign"):
+		return "minecraft:hanging_sign", true
+	case strings.HasSuffix(name, "_sign"):
+		return "minecraft:sign", true
+	case strings.HasSuffix(name, "_bed"):
+		return "minecraft:bed", true
+	case strings.HasSuffix(name, "_banner"):
+		return "minecraft:banner", true
+	case strings.HasSuffix(name, "_shulker_box"):
+		return "minecraft:shulker_box", true
+	case strings.HasSuffix(name, "_head"), strings.HasSuffix(name, "_skull"):
+		return "minecraft:skull", true
+	}
+	return "", false
+}
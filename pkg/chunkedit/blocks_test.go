@@ -0,0 +1,128 @@
+package chunkedit
+
+import (
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func testBlockChunk() map[string]any {
+	return map[string]any{
+		"sections": []any{
+			map[string]any{
+				"Y": int8(0),
+				"block_states": map[string]any{
+					"palette": []any{map[string]any{"Name": "minecraft:air"}},
+				},
+			},
+		},
+		"block_entities": []any{
+			map[string]any{"id": "minecraft:furnace", "x": int32(2), "y": int32(1), "z": int32(2)},
+		},
+	}
+}
+
+func TestParseBlockState(t *testing.T) {
+	st, err := ParseBlockState("oak_log[axis=y, waterlogged=false]")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := st.String(); got != "minecraft:oak_log[axis=y,waterlogged=false]" {
+		t.Fatalf("String: got %q", got)
+	}
+	if _, err := ParseBlockState("stone[axis=y"); err == nil {
+		t.Fatalf("expected error for unterminated properties")
+	}
+	if !st.Matches(BlockState{Name: "minecraft:oak_log"}) {
+		t.Fatalf("expected name-only pattern to match")
+	}
+	if st.Matches(BlockState{Name: "minecraft:oak_log", Properties: map[string]string{"axis": "x"}}) {
+		t.Fatalf("expected axis=x pattern not to match")
+	}
+}
+
+func TestFillAndReplaceBox(t *testing.T) {
+	chunk := testBlockChunk()
+	stone := BlockState{Name: "minecraft:stone"}
+
+	n, err := FillBox(chunk, coords.NewBox(0, 0, 0, 3, 3, 3), stone)
+	if err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+	if n != 64 {
+		t.Fatalf("changed: got %d, want 64", n)
+	}
+	if got, _ := GetBlockState(chunk, 3, 3, 3); got.Name != "minecraft:stone" {
+		t.Fatalf("inside box: got %v", got)
+	}
+	if got, _ := GetBlockState(chunk, 4, 3, 3); !got.IsAir() {
+		t.Fatalf("outside box: got %v", got)
+	}
+	if _, ok := GetBlockEntity(chunk, 2, 1, 2); ok {
+		t.Fatalf("expected furnace block entity to be removed")
+	}
+
+	chest := BlockState{Name: "minecraft:chest", Properties: map[string]string{"facing": "north"}}
+	n, err = ReplaceBox(chunk, coords.NewBox(0, 0, 0, 15, 15, 15), stone, chest)
+	if err != nil {
+		t.Fatalf("ReplaceBox: %v", err)
+	}
+	if n != 64 {
+		t.Fatalf("replaced: got %d, want 64", n)
+	}
+	ent, ok := GetBlockEntity(chunk, 1, 2, 3)
+	if !ok || ent["id"] != "minecraft:chest" {
+		t.Fatalf("expected chest block entity, got %v", ent)
+	}
+	ent["Lock"] = "key"
+
+	n, _ = ReplaceBox(chunk, coords.NewBox(0, 0, 0, 15, 15, 15), BlockState{Name: "minecraft:chest"},
+		BlockState{Name: "minecraft:chest", Properties: map[string]string{"facing": "south"}})
+	if n != 64 {
+		t.Fatalf("re-facing: got %d, want 64", n)
+	}
+	if ent, _ := GetBlockEntity(chunk, 1, 2, 3); ent["Lock"] != "key" {
+		t.Fatalf("expected chest contents to survive a state change, got %v", ent)
+	}
+}
+
+func TestPlaceBlocks(t *testing.T) {
+	chunk := testBlockChunk()
+	palette := []BlockState{{Name: "minecraft:air"}, {Name: "minecraft:barrel"}}
+
+	n, err := PlaceBlocks(chunk, coords.NewBox(0, 0, 0, 2, 0, 0), palette, func(x, y, z int) int {
+		if x == 0 {
+			return -1
+		}
+		return x - 1
+	})
+	if err != nil {
+		t.Fatalf("PlaceBlocks: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("changed: got %d, want 1 (air over air is unchanged)", n)
+	}
+	if got, _ := GetBlockState(chunk, 2, 0, 0); got.Name != "minecraft:barrel" {
+		t.Fatalf("placed block: got %v", got)
+	}
+	if ent, ok := GetBlockEntity(chunk, 2, 0, 0); !ok || ent["id"] != "minecraft:barrel" {
+		t.Fatalf("expected barrel block entity, got %v", ent)
+	}
+}
+
+func TestBlockYRange(t *testing.T) {
+	chunk := testBlockChunk()
+	secs := chunk["sections"].([]any)
+	chunk["sections"] = append(secs,
+		map[string]any{"Y": int8(-1), "block_states": map[string]any{}},
+		map[string]any{"Y": int8(-2)},
+		map[string]any{"Y": int8(3), "block_states": map[string]any{}},
+	)
+	lo, hi, err := BlockYRange(chunk)
+	if err != nil || lo != -16 || hi != 63 {
+		t.Fatalf("got %d..%d, %v; want -16..63", lo, hi, err)
+	}
+	if _, _, err := BlockYRange(map[string]any{}); err == nil {
+		t.Fatal("expected error without sections")
+	}
+}
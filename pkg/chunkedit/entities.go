@@ -0,0 +1,232 @@
+package chunkedit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ListEntities returns the entities of a chunk from an entities region
+// file (or the Entities list of a pre-1.17 terrain chunk).
+func ListEntities(chunk map[string]any) []map[string]any {
+	arr, _ := getArray(chunk, "Entities")
+	if arr == nil {
+		if level, ok := asMap(chunk["Level"]); ok {
+			arr, _ = getArray(level, "Entities")
+		}
+	}
+	out := make([]map[string]any, 0, len(arr))
+	for _, v := range arr {
+		if m, ok := asMap(v); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// DeleteEntities removes every entity for which match returns true and
+// returns how many were removed.
+func DeleteEntities(chunk map[string]any, match func(ent map[string]any) bool) int {
+	holder := chunk
+	arr, key := getArray(holder, "Entities")
+	if key == "" {
+		level, ok := asMap(chunk["Level"])
+		if !ok {
+			return 0
+		}
+		holder = level
+		if arr, key = getArray(holder, "Entities"); key == "" {
+			return 0
+		}
+	}
+	kept := make([]any, 0, len(arr))
+	for _, v := range arr {
+		if m, ok := asMap(v); ok && match(m) {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	holder[key] = kept
+	return len(arr) - len(kept)
+}
+
+// EntityID returns the id of an entity, or "" when it has none.
+func EntityID(ent map[string]any) string {
+	id, _ := ent["id"].(string)
+	return id
+}
+
+// EntityPos returns the absolute position stored in an entity's Pos tag.
+func EntityPos(ent map[string]any) (x, y, z float64, ok bool) {
+	pos, ok := ent["Pos"].([]any)
+	if !ok || len(pos) != 3 {
+		return 0, 0, 0, false
+	}
+	var out [3]float64
+	for i, v := range pos {
+		switch t := v.(type) {
+		case float64:
+			out[i] = t
+		case float32:
+			out[i] = float64(t)
+		default:
+			return 0, 0, 0, false
+		}
+	}
+	return out[0], out[1], out[2], true
+}
+
+// EntityUUID returns the entity's UUID in the usual dashed hex form. Both
+// the int array form and the older UUIDMost/UUIDLeast pair are read.
+func EntityUUID(ent map[string]any) (string, bool) {
+	var b [16]byte
+	switch u := ent["UUID"].(type) {
+	case []int32:
+		if len(u) != 4 {
+			return "", false
+		}
+		for i, v := range u {
+			b[i*4] = byte(v >> 24)
+			b[i*4+1] = byte(v >> 16)
+			b[i*4+2] = byte(v >> 8)
+			b[i*4+3] = byte(v)
+		}
+	default:
+		most, mok := ent["UUIDMost"].(int64)
+		least, lok := ent["UUIDLeast"].(int64)
+		if !mok || !lok {
+			return "", false
+		}
+		for i := range 8 {
+			b[i] = byte(most >> (56 - 8*i))
+			b[8+i] = byte(least >> (56 - 8*i))
+		}
+	}
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], true
+}
+
+// ParseUUID parses a dashed or plain hex UUID into the int array form
+// stored in entity NBT.
+func ParseUUID(s string) ([]int32, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(s), "-", ""))
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("invalid UUID %q", s)
+	}
+	out := make([]int32, 4)
+	for i := range out {
+		out[i] = int32(uint32(b[i*4])<<24 | uint32(b[i*4+1])<<16 | uint32(b[i*4+2])<<8 | uint32(b[i*4+3]))
+	}
+	return out, nil
+}
+
+// NewUUID returns a random version 4 UUID in the int array form.
+func NewUUID() []int32 {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	out := make([]int32, 4)
+	for i := range out {
+		out[i] = int32(uint32(b[i*4])<<24 | uint32(b[i*4+1])<<16 | uint32(b[i*4+2])<<8 | uint32(b[i*4+3]))
+	}
+	return out
+}
+
+// FindEntity returns the entity with the given UUID, in any form accepted
+// by ParseUUID.
+func FindEntity(chunk map[string]any, uuid string) (map[string]any, bool) {
+	parsed, err := ParseUUID(uuid)
+	if err != nil {
+		return nil, false
+	}
+	want, _ := EntityUUID(map[string]any{"UUID": parsed})
+	for _, ent := range ListEntities(chunk) {
+		if u, ok := EntityUUID(ent); ok && u == want {
+			return ent, true
+		}
+	}
+	return nil, false
+}
+
+// MergeJSONTyped merges a JSON object into dst like MergeJSONData, but
+// values replacing an existing tag keep that tag's type, so patching Health
+// or Rotation does not turn them into doubles.
+func MergeJSONTyped(dst map[string]any, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		dst[k] = typedLike(dst[k], v)
+	}
+	return nil
+}
+
+// typedLike converts a decoded JSON value to the NBT types of old.
+func typedLike(old, v any) any {
+	switch t := v.(type) {
+	case float64:
+		return numberLike(old, t)
+	case []any:
+		switch o := old.(type) {
+		case []int32:
+			out := make([]int32, len(t))
+			for i, e := range t {
+				f, _ := e.(float64)
+				out[i] = int32(f)
+			}
+			return out
+		case []int64:
+			out := make([]int64, len(t))
+			for i, e := range t {
+				f, _ := e.(float64)
+				out[i] = int64(f)
+			}
+			return out
+		case []byte:
+			out := make([]byte, len(t))
+			for i, e := range t {
+				f, _ := e.(float64)
+				out[i] = byte(int8(f))
+			}
+			return out
+		case []any:
+			if len(o) == 0 {
+				return t
+			}
+			for i, e := range t {
+				t[i] = typedLike(o[0], e)
+			}
+		}
+		return t
+	case map[string]any:
+		o, _ := old.(map[string]any)
+		for k, e := range t {
+			t[k] = typedLike(o[k], e)
+		}
+		return t
+	}
+	return v
+}
+
+func numberLike(old any, f float64) any {
+	switch old.(type) {
+	case int8:
+		return int8(f)
+	case int16:
+		return int16(f)
+	case int32:
+		return int32(f)
+	case int64:
+		return int64(f)
+	case float32:
+		return float32(f)
+	}
+	return f
+}
@@ -0,0 +1,91 @@
+package chunkedit
+
+import "testing"
+
+func TestEntityUUID(t *testing.T) {
+	const u = "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+	parsed, err := ParseUUID(u)
+	if err != nil {
+		t.Fatalf("ParseUUID: %v", err)
+	}
+	got, ok := EntityUUID(map[string]any{"UUID": parsed})
+	if !ok || got != u {
+		t.Fatalf("got %q, want %q", got, u)
+	}
+	legacy := map[string]any{"UUIDMost": int64(0x0f1e2d3c4b5a6978), "UUIDLeast": int64(-0x7869_5a4b_3c2d_1e10)}
+	if got, _ := EntityUUID(legacy); got != u {
+		t.Fatalf("legacy: got %q, want %q", got, u)
+	}
+	if _, err := ParseUUID("not-a-uuid"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFindAndDeleteEntities(t *testing.T) {
+	chunk := map[string]any{"Entities": []any{
+		map[string]any{"id": "minecraft:item", "UUID": []int32{0, 0, 0, 1}},
+		map[string]any{"id": "minecraft:pig", "UUID": []int32{0, 0, 0, 2}},
+	}}
+	if ent, ok := FindEntity(chunk, "00000000000000000000000000000002"); !ok || EntityID(ent) != "minecraft:pig" {
+		t.Fatalf("FindEntity: got %v, %v", ent, ok)
+	}
+	n := DeleteEntities(chunk, func(e map[string]any) bool { return EntityID(e) == "minecraft:item" })
+	if n != 1 || len(ListEntities(chunk)) != 1 {
+		t.Fatalf("DeleteEntities: removed %d, left %d", n, len(ListEntities(chunk)))
+	}
+}
+
+func TestMergeJSONTyped(t *testing.T) {
+	ent := map[string]any{
+		"Health":   float32(20),
+		"Age":      int16(0),
+		"UUID":     []int32{1, 2, 3, 4},
+		"Rotation": []any{float32(0), float32(0)},
+	}
+	err := MergeJSONTyped(ent, `{"Health":5,"Age":100,"UUID":[5,6,7,8],"Rotation":[90,10],"Tags":["x"]}`)
+	if err != nil {
+		t.Fatalf("MergeJSONTyped: %v", err)
+	}
+	if ent["Health"] != float32(5) || ent["Age"] != int16(100) {
+		t.Fatalf("scalars: got %T %T", ent["Health"], ent["Age"])
+	}
+	if u, ok := ent["UUID"].([]int32); !ok || u[3] != 8 {
+		t.Fatalf("UUID: got %#v", ent["UUID"])
+	}
+	if r, ok := ent["Rotation"].([]any); !ok || r[0] != float32(90) {
+		t.Fatalf("Rotation: got %#v", ent["Rotation"])
+	}
+}
+
+func TestEntityFilter(t *testing.T) {
+	item := map[string]any{"id": "minecraft:item", "Age": int16(5000), "Item": map[string]any{"id": "minecraft:dirt"}}
+	named := map[string]any{"id": "minecraft:item", "Age": int16(10), "CustomName": `"keep"`}
+	cases := []struct {
+		expr       string
+		item, name bool
+	}{
+		{"Age>1000", true, false},
+		{"Age<=10", false, true},
+		{"!CustomName", true, false},
+		{"CustomName", false, true},
+		{"Item.id=minecraft:dirt", true, false},
+		{"Item.id!=minecraft:dirt", false, true},
+		{"Age=5000", true, false},
+	}
+	for _, c := range cases {
+		p, err := ParseEntityPredicate(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+		if p.Match(item) != c.item || p.Match(named) != c.name {
+			t.Errorf("%q: got %v/%v, want %v/%v", c.expr, p.Match(item), p.Match(named), c.item, c.name)
+		}
+	}
+	if _, err := ParseEntityPredicate("Age>old"); err == nil {
+		t.Fatal("expected error for non-numeric comparison")
+	}
+	f := EntityFilter{Types: []string{"minecraft:experience_orb", "minecraft:item"}, Where: []EntityPredicate{{Key: "CustomName", Op: "absent"}}}
+	if !f.Match(item) || f.Match(named) || f.Match(map[string]any{"id": "minecraft:pig"}) {
+		t.Fatal("EntityFilter.Match mismatch")
+	}
+}
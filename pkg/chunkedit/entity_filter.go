@@ -0,0 +1,140 @@
+package chunkedit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EntityPredicate tests one field of an entity. Key may be a dotted path
+// into nested compounds, such as Item.id.
+type EntityPredicate struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+var predicateOps = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// ParseEntityPredicate parses "key" (present), "!key" (absent) or
+// "key<op>value" with op one of =, !=, >, >=, <, <=. Ordering operators
+// compare numerically.
+func ParseEntityPredicate(s string) (EntityPredicate, error) {
+	s = strings.TrimSpace(s)
+	for _, op := range predicateOps {
+		if k, v, ok := strings.Cut(s, op); ok {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				return EntityPredicate{}, fmt.Errorf("invalid predicate %q: empty key", s)
+			}
+			p := EntityPredicate{Key: k, Op: op, Value: strings.TrimSpace(v)}
+			if op != "=" && op != "!=" {
+				if _, err := strconv.ParseFloat(p.Value, 64); err != nil {
+					return EntityPredicate{}, fmt.Errorf("invalid predicate %q: %s needs a number", s, op)
+				}
+			}
+			return p, nil
+		}
+	}
+	if k, ok := strings.CutPrefix(s, "!"); ok && k != "" {
+		return EntityPredicate{Key: k, Op: "absent"}, nil
+	}
+	if s == "" {
+		return EntityPredicate{}, fmt.Errorf("empty predicate")
+	}
+	return EntityPredicate{Key: s, Op: "present"}, nil
+}
+
+func lookupPath(m map[string]any, path string) (any, bool) {
+	var cur any = m
+	for _, part := range strings.Split(path, ".") {
+		cm, ok := asMap(cur)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = cm[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func asNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+	n, ok := asInt(v)
+	return float64(n), ok
+}
+
+// Match reports whether ent satisfies the predicate. A missing key only
+// matches "absent" and "!=".
+func (p EntityPredicate) Match(ent map[string]any) bool {
+	v, ok := lookupPath(ent, p.Key)
+	switch p.Op {
+	case "present":
+		return ok
+	case "absent":
+		return !ok
+	}
+	if !ok {
+		return p.Op == "!="
+	}
+	if p.Op == "=" || p.Op == "!=" {
+		eq := fmt.Sprint(v) == p.Value
+		if n, isNum := asNumber(v); isNum {
+			if want, err := strconv.ParseFloat(p.Value, 64); err == nil {
+				eq = n == want
+			}
+		}
+		return eq == (p.Op == "=")
+	}
+	n, isNum := asNumber(v)
+	if !isNum {
+		return false
+	}
+	want, _ := strconv.ParseFloat(p.Value, 64)
+	switch p.Op {
+	case ">":
+		return n > want
+	case ">=":
+		return n >= want
+	case "<":
+		return n < want
+	default:
+		return n <= want
+	}
+}
+
+// EntityFilter matches entities of any of Types (all types when empty)
+// that satisfy every predicate in Where.
+type EntityFilter struct {
+	Types []string
+	Where []EntityPredicate
+}
+
+// Match reports whether ent has one of the filter's types and satisfies
+// every predicate.
+func (f EntityFilter) Match(ent map[string]any) bool {
+	if len(f.Types) > 0 {
+		id, found := EntityID(ent), false
+		for _, t := range f.Types {
+			if t == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, p := range f.Where {
+		if !p.Match(ent) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package chunkedit
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Heightmap types stored in full chunks. The _WG variants only exist in
+// chunks that are still generating and are recomputed only when present.
+var heightmapTypes = []string{"MOTION_BLOCKING", "MOTION_BLOCKING_NO_LEAVES", "OCEAN_FLOOR", "WORLD_SURFACE"}
+
+var worldgenHeightmaps = map[string]string{
+	"OCEAN_FLOOR_WG":   "OCEAN_FLOOR",
+	"WORLD_SURFACE_WG": "WORLD_SURFACE",
+}
+
+var nonSolidBlocks = map[string]bool{
+	"minecraft:air": true, "minecraft:cave_air": true, "minecraft:void_air": true,
+	"minecraft:short_grass": true, "minecraft:grass": true, "minecraft:tall_grass": true,
+	"minecraft:fern": true, "minecraft:large_fern": true, "minecraft:dead_bush": true,
+	"minecraft:dandelion": true, "minecraft:poppy": true, "minecraft:blue_orchid": true,
+	"minecraft:allium": true, "minecraft:azure_bluet": true, "minecraft:oxeye_daisy": true,
+	"minecraft:cornflower": true, "minecraft:lily_of_the_valley": true, "minecraft:wither_rose": true,
+	"minecraft:sunflower": true, "minecraft:lilac": true, "minecraft:rose_bush": true,
+	"minecraft:peony": true, "minecraft:torchflower": true, "minecraft:pitcher_plant": true,
+	"minecraft:pink_petals": true, "minecraft:vine": true, "minecraft:glow_lichen": true,
+	"minecraft:sculk_vein": true, "minecraft:cobweb": true, "minecraft:snow": true,
+	"minecraft:redstone_wire": true, "minecraft:tripwire": true, "minecraft:tripwire_hook": true,
+	"minecraft:lever": true, "minecraft:ladder": true, "minecraft:nether_portal": true,
+	"minecraft:end_portal": true, "minecraft:fire": true, "minecraft:soul_fire": true,
+	"minecraft:sugar_cane": true, "minecraft:wheat": true, "minecraft:carrots": true,
+	"minecraft:potatoes": true, "minecraft:beetroots": true, "minecraft:melon_stem": true,
+	"minecraft:pumpkin_stem": true, "minecraft:attached_melon_stem": true,
+	"minecraft:attached_pumpkin_stem": true, "minecraft:sweet_berry_bush": true,
+	"minecraft:cave_vines": true, "minecraft:cave_vines_plant": true,
+	"minecraft:weeping_vines": true, "minecraft:weeping_vines_plant": true,
+	"minecraft:twisting_vines": true, "minecraft:twisting_vines_plant": true,
+	"minecraft:hanging_roots": true, "minecraft:spore_blossom": true,
+	"minecraft:small_dripleaf": true, "minecraft:big_dripleaf_stem": true,
+	"minecraft:lily_pad": true, "minecraft:structure_void": true, "minecraft:light": true,
+	"minecraft:brown_mushroom": true, "minecraft:red_mushroom": true,
+	"minecraft:crimson_fungus": true, "minecraft:warped_fungus": true,
+	"minecraft:crimson_roots": true, "minecraft:warped_roots": true,
+	"minecraft:nether_sprouts": true, "minecraft:nether_wart": true,
+	"minecraft:bamboo_sapling": true, "minecraft:frogspawn": true,
+	"minecraft:mangrove_propagule": true, "minecraft:end_gateway": true,
+	"minecraft:water": true, "minecraft:lava": true, "minecraft:bubble_column": true,
+	"minecraft:kelp": true, "minecraft:kelp_plant": true,
+	"minecraft:seagrass": true, "minecraft:tall_seagrass": true,
+}
+
+var nonSolidSuffixes = []string{
+	"_sapling", "torch", "_sign", "_banner", "_button", "_pressure_plate", "rail",
+	"_carpet", "_tulip", "_coral", "_coral_fan", "_head", "_skull", "candle",
+}
+
+var fluidBlocks = map[string]bool{
+	"minecraft:water": true, "minecraft:lava": true, "minecraft:bubble_column": true,
+	"minecraft:kelp": true, "minecraft:kelp_plant": true,
+	"minecraft:seagrass": true, "minecraft:tall_seagrass": true,
+}
+
+// blocksMotion approximates the game's BlockState.blocksMotion from the
+// block name, since the collision shapes are not available offline.
+func blocksMotion(st BlockState) bool {
+	if nonSolidBlocks[st.Name] {
+		return false
+	}
+	for _, s := range nonSolidSuffixes {
+		if strings.HasSuffix(st.Name, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasFluid(st BlockState) bool {
+	return fluidBlocks[st.Name] || st.Properties["waterlogged"] == "true"
+}
+
+func heightmapMatches(kind string, st BlockState) bool {
+	switch kind {
+	case "WORLD_SURFACE":
+		return !st.IsAir()
+	case "OCEAN_FLOOR":
+		return blocksMotion(st)
+	case "MOTION_BLOCKING":
+		return blocksMotion(st) || hasFluid(st)
+	case "MOTION_BLOCKING_NO_LEAVES":
+		return (blocksMotion(st) || hasFluid(st)) && !strings.HasSuffix(st.Name, "_leaves")
+	}
+	return false
+}
+
+type blockSection struct {
+	y  int
+	pc *palettedContainer
+}
+
+// blockSections decodes every section that carries block states, sorted by
+// descending Y.
+func blockSections(chunk map[string]any) ([]blockSection, error) {
+	secs, err := chunkSections(chunk)
+	if err != nil {
+		return nil, err
+	}
+	var out []blockSection
+	for _, v := range secs {
+		sec, ok := asMap(v)
+		if !ok {
+			continue
+		}
+		sy, ok := asInt(sec["Y"])
+		if !ok {
+			continue
+		}
+		m, ok := asMap(sec["block_states"])
+		if !ok {
+			continue
+		}
+		pc, err := decodeContainer(m, blockCells, 4, blockStateKey)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, blockSection{y: sy, pc: pc})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].y > out[j].y })
+	return out, nil
+}
+
+// RecomputeHeightmaps rebuilds the chunk's Heightmaps from its block states.
+// height is the dimension's build height, which sets the bits per entry; 0
+// assumes the vanilla height for the chunk's bottom, 384 from Y -64 and 256
+// otherwise, or the span of the stored sections when larger.
+func RecomputeHeightmaps(chunk map[string]any, height int) error {
+	secs, err := blockSections(chunk)
+	if err != nil {
+		return err
+	}
+	if len(secs) == 0 {
+		return nil
+	}
+	minSection := secs[len(secs)-1].y
+	if y, ok := asInt(chunk["yPos"]); ok {
+		minSection = y
+	}
+	minY := minSection * 16
+	if height == 0 {
+		height = 256
+		if minY == -64 {
+			height = 384
+		}
+		height = max(height, (secs[0].y-minSection+1)*16)
+	}
+
+	hm, ok := asMap(chunk["Heightmaps"])
+	if !ok {
+		hm = map[string]any{}
+		chunk["Heightmaps"] = hm
+	}
+	kinds := append([]string(nil), heightmapTypes...)
+	for wg := range worldgenHeightmaps {
+		if _, ok := hm[wg]; ok {
+			kinds = append(kinds, wg)
+		}
+	}
+	for _, kind := range kinds {
+		base := kind
+		if b, ok := worldgenHeightmaps[kind]; ok {
+			base = b
+		}
+		matches := make([][]bool, len(secs))
+		for i, s := range secs {
+			matches[i] = make([]bool, len(s.pc.palette))
+			for j, p := range s.pc.palette {
+				matches[i][j] = heightmapMatches(base, blockStateFromNBT(p))
+			}
+		}
+		heights := make([]int, 256)
+		for col := range heights {
+			heights[col] = max(columnTop(secs, matches, col)-minY, 0)
+		}
+		hm[kind] = packHeightmap(heights, height)
+	}
+	return nil
+}
+
+// columnTop returns one above the highest matching block of column col
+// (z*16+x), or the lowest section's bottom when nothing matches.
+func columnTop(secs []blockSection, matches [][]bool, col int) int {
+	for i, s := range secs {
+		for ly := 15; ly >= 0; ly-- {
+			if matches[i][s.pc.indices[ly<<8|col]] {
+				return s.y*16 + ly + 1
+			}
+		}
+	}
+	return secs[len(secs)-1].y * 16
+}
+
+func packHeightmap(heights []int, height int) []int64 {
+	n := bits.Len(uint(height))
+	perLong := 64 / n
+	data := make([]int64, (len(heights)+perLong-1)/perLong)
+	for i, h := range heights {
+		data[i/perLong] |= int64(uint64(h) << (uint(i%perLong) * uint(n)))
+	}
+	return data
+}
+
+// ResetLight removes stored sky and block light and clears isLightOn so the
+// server relights the chunk when it is next loaded.
+func ResetLight(chunk map[string]any) error {
+	secs, err := chunkSections(chunk)
+	if err != nil {
+		return err
+	}
+	for _, v := range secs {
+		if sec, ok := asMap(v); ok {
+			delete(sec, "SkyLight")
+			delete(sec, "BlockLight")
+		}
+	}
+	chunk["isLightOn"] = int8(0)
+	return nil
+}
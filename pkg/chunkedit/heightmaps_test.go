@@ -0,0 +1,83 @@
+package chunkedit
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func unpackHeightmap(data []int64, height, col int) int {
+	n := bits.Len(uint(height))
+	perLong := 64 / n
+	return int(uint64(data[col/perLong]) >> (uint(col%perLong) * uint(n)) & (1<<n - 1))
+}
+
+func TestRecomputeHeightmaps(t *testing.T) {
+	chunk := testBlockChunk()
+	chunk["yPos"] = int32(0)
+	if _, err := FillBox(chunk, coords.NewBox(0, 0, 0, 0, 2, 0), BlockState{Name: "minecraft:stone"}); err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+	if _, err := FillBox(chunk, coords.NewBox(0, 3, 0, 0, 3, 0), BlockState{Name: "minecraft:torch"}); err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+	if _, err := FillBox(chunk, coords.NewBox(1, 5, 0, 1, 5, 0), BlockState{Name: "minecraft:oak_leaves"}); err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+
+	// Only section 0 is stored; the entries still span the build height.
+	if err := RecomputeHeightmaps(chunk, 0); err != nil {
+		t.Fatalf("RecomputeHeightmaps: %v", err)
+	}
+	hm := chunk["Heightmaps"].(map[string]any)
+	cases := []struct {
+		kind string
+		col  int
+		want int
+	}{
+		{"WORLD_SURFACE", 0, 4},
+		{"MOTION_BLOCKING", 0, 3},
+		{"OCEAN_FLOOR", 0, 3},
+		{"MOTION_BLOCKING", 1, 6},
+		{"MOTION_BLOCKING_NO_LEAVES", 1, 0},
+		{"WORLD_SURFACE", 2, 0},
+	}
+	for _, c := range cases {
+		data, ok := hm[c.kind].([]int64)
+		if !ok {
+			t.Fatalf("%s: missing heightmap", c.kind)
+		}
+		if len(data) != 37 {
+			t.Fatalf("%s: %d longs, want 37 for 9-bit entries", c.kind, len(data))
+		}
+		if got := unpackHeightmap(data, 256, c.col); got != c.want {
+			t.Fatalf("%s column %d: got %d, want %d", c.kind, c.col, got, c.want)
+		}
+	}
+
+	if err := RecomputeHeightmaps(chunk, 4064); err != nil {
+		t.Fatalf("RecomputeHeightmaps: %v", err)
+	}
+	data := hm["MOTION_BLOCKING"].([]int64)
+	if got := unpackHeightmap(data, 4064, 1); len(data) != 52 || got != 6 {
+		t.Fatalf("height 4064: %d longs, column 1 at %d", len(data), got)
+	}
+}
+
+func TestResetLight(t *testing.T) {
+	chunk := testBlockChunk()
+	chunk["isLightOn"] = int8(1)
+	sec, _ := findSection(chunk, 0)
+	sec["SkyLight"] = make([]byte, 2048)
+
+	if err := ResetLight(chunk); err != nil {
+		t.Fatalf("ResetLight: %v", err)
+	}
+	if _, ok := sec["SkyLight"]; ok {
+		t.Fatalf("expected SkyLight to be removed")
+	}
+	if chunk["isLightOn"] != int8(0) {
+		t.Fatalf("isLightOn: got %v, want 0", chunk["isLightOn"])
+	}
+}
@@ -0,0 +1,130 @@
+package chunkedit
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// palettedContainer is the decoded form of a section's block_states or
+// biomes compound: a palette plus one palette index per cell.
+type palettedContainer struct {
+	palette []any
+	indices []int
+	keyOf   func(any) string
+}
+
+func bitsFor(paletteLen, minBits int) int {
+	if paletteLen <= 1 {
+		return 0
+	}
+	return max(bits.Len(uint(paletteLen-1)), minBits)
+}
+
+func toInt64Slice(v any) ([]int64, bool) {
+	switch t := v.(type) {
+	case []int64:
+		return t, true
+	case []any:
+		out := make([]int64, len(t))
+		for i, e := range t {
+			n, ok := e.(int64)
+			if !ok {
+				return nil, false
+			}
+			out[i] = n
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
+func decodeContainer(m map[string]any, size, minBits int, keyOf func(any) string) (*palettedContainer, error) {
+	palette, ok := m["palette"].([]any)
+	if !ok || len(palette) == 0 {
+		return nil, fmt.Errorf("missing palette")
+	}
+	pc := &palettedContainer{
+		palette: append([]any(nil), palette...),
+		indices: make([]int, size),
+		keyOf:   keyOf,
+	}
+	n := bitsFor(len(palette), minBits)
+	if n == 0 {
+		return pc, nil
+	}
+	data, ok := toInt64Slice(m["data"])
+	if !ok {
+		return nil, fmt.Errorf("missing data for palette of %d entries", len(palette))
+	}
+	perLong := 64 / n
+	if want := (size + perLong - 1) / perLong; len(data) != want {
+		return nil, fmt.Errorf("data length %d, want %d for %d bits per entry", len(data), want, n)
+	}
+	mask := uint64(1)<<n - 1
+	for i := range size {
+		v := int(uint64(data[i/perLong]) >> (uint(i%perLong) * uint(n)) & mask)
+		if v >= len(palette) {
+			return nil, fmt.Errorf("palette index %d out of range at cell %d", v, i)
+		}
+		pc.indices[i] = v
+	}
+	return pc, nil
+}
+
+func (pc *palettedContainer) get(i int) any {
+	return pc.palette[pc.indices[i]]
+}
+
+// indexOf returns the palette index for v, appending it if needed.
+func (pc *palettedContainer) indexOf(v any) int {
+	key := pc.keyOf(v)
+	for idx, p := range pc.palette {
+		if pc.keyOf(p) == key {
+			return idx
+		}
+	}
+	pc.palette = append(pc.palette, v)
+	return len(pc.palette) - 1
+}
+
+func (pc *palettedContainer) set(i int, v any) bool {
+	if pc.keyOf(pc.palette[pc.indices[i]]) == pc.keyOf(v) {
+		return false
+	}
+	pc.indices[i] = pc.indexOf(v)
+	return true
+}
+
+// encode writes the container back into m, dropping palette entries that
+// are no longer referenced.
+func (pc *palettedContainer) encode(m map[string]any, minBits int) {
+	remap := make([]int, len(pc.palette))
+	for i := range remap {
+		remap[i] = -1
+	}
+	var palette []any
+	for _, idx := range pc.indices {
+		if remap[idx] < 0 {
+			remap[idx] = len(palette)
+			palette = append(palette, pc.palette[idx])
+		}
+	}
+	for i, idx := range pc.indices {
+		pc.indices[i] = remap[idx]
+	}
+	pc.palette = palette
+
+	m["palette"] = append([]any(nil), palette...)
+	n := bitsFor(len(palette), minBits)
+	if n == 0 {
+		delete(m, "data")
+		return
+	}
+	perLong := 64 / n
+	data := make([]int64, (len(pc.indices)+perLong-1)/perLong)
+	for i, v := range pc.indices {
+		data[i/perLong] |= int64(uint64(v) << (uint(i%perLong) * uint(n)))
+	}
+	m["data"] = data
+}
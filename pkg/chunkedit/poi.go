@@ -0,0 +1,142 @@
+package chunkedit
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// POI is one point-of-interest record from a poi region chunk.
+type POI struct {
+	Type        string `json:"type"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Z           int    `json:"z"`
+	FreeTickets int    `json:"free_tickets"`
+}
+
+func poiFromNBT(m map[string]any) (POI, bool) {
+	p := POI{}
+	p.Type, _ = m["type"].(string)
+	pos, ok := m["pos"].([]int32)
+	if !ok || len(pos) != 3 {
+		return p, false
+	}
+	p.X, p.Y, p.Z = int(pos[0]), int(pos[1]), int(pos[2])
+	p.FreeTickets, _ = asInt(m["free_tickets"])
+	return p, true
+}
+
+// poiSections returns the chunk's POI sections sorted by section Y.
+func poiSections(chunk map[string]any) []map[string]any {
+	secs, ok := asMap(chunk["Sections"])
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(secs))
+	for k := range secs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(keys[i])
+		b, _ := strconv.Atoi(keys[j])
+		return a < b
+	})
+	out := make([]map[string]any, 0, len(keys))
+	for _, k := range keys {
+		if sec, ok := asMap(secs[k]); ok {
+			out = append(out, sec)
+		}
+	}
+	return out
+}
+
+// ListPOIs returns the POI records of a chunk from a poi region file.
+func ListPOIs(chunk map[string]any) []POI {
+	var out []POI
+	for _, sec := range poiSections(chunk) {
+		recs, _ := getArray(sec, "Records")
+		for _, v := range recs {
+			if m, ok := asMap(v); ok {
+				if p, ok := poiFromNBT(m); ok {
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// DeletePOIs removes every record for which match returns true and returns
+// how many were removed.
+func DeletePOIs(chunk map[string]any, match func(POI) bool) int {
+	n := 0
+	for _, sec := range poiSections(chunk) {
+		recs, key := getArray(sec, "Records")
+		if key == "" {
+			continue
+		}
+		kept := make([]any, 0, len(recs))
+		for _, v := range recs {
+			if m, ok := asMap(v); ok {
+				if p, ok := poiFromNBT(m); ok && match(p) {
+					n++
+					continue
+				}
+			}
+			kept = append(kept, v)
+		}
+		sec[key] = kept
+	}
+	return n
+}
+
+// poiBlocks lists the blocks each vanilla POI type sits on. Entries
+// starting with an underscore match name suffixes; entries without a
+// namespace match anywhere in the name, covering copper variants.
+var poiBlocks = map[string][]string{
+	"minecraft:home":          {"_bed"},
+	"minecraft:armorer":       {"minecraft:blast_furnace"},
+	"minecraft:butcher":       {"minecraft:smoker"},
+	"minecraft:cartographer":  {"minecraft:cartography_table"},
+	"minecraft:cleric":        {"minecraft:brewing_stand"},
+	"minecraft:farmer":        {"minecraft:composter"},
+	"minecraft:fisherman":     {"minecraft:barrel"},
+	"minecraft:fletcher":      {"minecraft:fletching_table"},
+	"minecraft:leatherworker": {"_cauldron", "minecraft:cauldron"},
+	"minecraft:librarian":     {"minecraft:lectern"},
+	"minecraft:mason":         {"minecraft:stonecutter"},
+	"minecraft:shepherd":      {"minecraft:loom"},
+	"minecraft:toolsmith":     {"minecraft:smithing_table"},
+	"minecraft:weaponsmith":   {"minecraft:grindstone"},
+	"minecraft:meeting":       {"minecraft:bell"},
+	"minecraft:beehive":       {"minecraft:beehive"},
+	"minecraft:bee_nest":      {"minecraft:bee_nest"},
+	"minecraft:nether_portal": {"minecraft:nether_portal"},
+	"minecraft:lodestone":     {"minecraft:lodestone"},
+	"minecraft:lightning_rod": {"lightning_rod"},
+}
+
+// POIBlockMatches reports whether block is a valid block for a POI of type
+// typ. known is false for POI types this table does not cover.
+func POIBlockMatches(typ, block string) (match, known bool) {
+	want, ok := poiBlocks[typ]
+	if !ok {
+		return false, false
+	}
+	for _, w := range want {
+		switch {
+		case strings.HasPrefix(w, "_"):
+			if strings.HasSuffix(block, w) {
+				return true, true
+			}
+		case !strings.Contains(w, ":"):
+			if strings.Contains(block, w) {
+				return true, true
+			}
+		case block == w:
+			return true, true
+		}
+	}
+	return false, true
+}
@@ -0,0 +1,50 @@
+package chunkedit
+
+import "testing"
+
+func testPOIChunk() map[string]any {
+	rec := func(typ string, x, y, z int32) any {
+		return map[string]any{"type": typ, "pos": []int32{x, y, z}, "free_tickets": int32(0)}
+	}
+	return map[string]any{"Sections": map[string]any{
+		"4": map[string]any{"Valid": int8(1), "Records": []any{rec("minecraft:home", 1, 70, 1)}},
+		"-1": map[string]any{"Valid": int8(1), "Records": []any{
+			rec("minecraft:librarian", 2, -10, 2), rec("minecraft:home", 3, -12, 3),
+		}},
+	}}
+}
+
+func TestListAndDeletePOIs(t *testing.T) {
+	chunk := testPOIChunk()
+	pois := ListPOIs(chunk)
+	if len(pois) != 3 || pois[0].Y != -10 || pois[2].Type != "minecraft:home" || pois[2].Y != 70 {
+		t.Fatalf("ListPOIs: got %+v", pois)
+	}
+	n := DeletePOIs(chunk, func(p POI) bool { return p.Type == "minecraft:home" })
+	if n != 2 {
+		t.Fatalf("DeletePOIs: removed %d, want 2", n)
+	}
+	if left := ListPOIs(chunk); len(left) != 1 || left[0].Type != "minecraft:librarian" {
+		t.Fatalf("after delete: got %+v", left)
+	}
+}
+
+func TestPOIBlockMatches(t *testing.T) {
+	cases := []struct {
+		typ, block   string
+		match, known bool
+	}{
+		{"minecraft:home", "minecraft:red_bed", true, true},
+		{"minecraft:home", "minecraft:air", false, true},
+		{"minecraft:leatherworker", "minecraft:water_cauldron", true, true},
+		{"minecraft:lightning_rod", "minecraft:waxed_exposed_lightning_rod", true, true},
+		{"minecraft:librarian", "minecraft:bookshelf", false, true},
+		{"example:custom", "minecraft:stone", false, false},
+	}
+	for _, c := range cases {
+		match, known := POIBlockMatches(c.typ, c.block)
+		if match != c.match || known != c.known {
+			t.Errorf("%s on %s: got %v/%v, want %v/%v", c.typ, c.block, match, known, c.match, c.known)
+		}
+	}
+}
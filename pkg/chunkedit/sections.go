@@ -0,0 +1,89 @@
+package chunkedit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+// ErrUnsupportedFormat is returned for chunks saved before 1.18, which have
+// no top-level sections list.
+var ErrUnsupportedFormat = errors.New("unsupported chunk format: expected 1.18+ sections")
+
+func asInt(v any) (int, bool) {
+	switch t := v.(type) {
+	case int8:
+		return int(t), true
+	case int16:
+		return int(t), true
+	case int32:
+		return int(t), true
+	case int64:
+		return int(t), true
+	case int:
+		return t, true
+	default:
+		return 0, false
+	}
+}
+
+func chunkSections(chunk map[string]any) ([]any, error) {
+	arr, key := getArray(chunk, "sections")
+	if key == "" {
+		return nil, ErrUnsupportedFormat
+	}
+	return arr, nil
+}
+
+func findSection(chunk map[string]any, sy int) (map[string]any, error) {
+	arr, err := chunkSections(chunk)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range arr {
+		m, ok := asMap(v)
+		if !ok {
+			continue
+		}
+		if y, ok := asInt(m["Y"]); ok && y == sy {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("section %d not present in chunk", sy)
+}
+
+func sectionFor(chunk map[string]any, y int) (map[string]any, error) {
+	return findSection(chunk, coords.FloorDiv(y, 16))
+}
+
+// BlockYRange returns the lowest and highest block Y covered by the
+// chunk's sections that store blocks. Light-only sections are ignored.
+func BlockYRange(chunk map[string]any) (minY, maxY int, err error) {
+	arr, err := chunkSections(chunk)
+	if err != nil {
+		return 0, 0, err
+	}
+	lo, hi, found := 0, 0, false
+	for _, v := range arr {
+		m, ok := asMap(v)
+		if !ok {
+			continue
+		}
+		sy, ok := asInt(m["Y"])
+		if _, hasBlocks := m["block_states"]; !ok || !hasBlocks {
+			continue
+		}
+		if !found || sy < lo {
+			lo = sy
+		}
+		if !found || sy > hi {
+			hi = sy
+		}
+		found = true
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("chunk has no block sections")
+	}
+	return lo * 16, hi*16 + 15, nil
+}
@@ -0,0 +1,138 @@
+package chunkedit
+
+import (
+	"sort"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+// BlockCounts tallies block states overall and per Y level.
+type BlockCounts struct {
+	ByName bool
+	Total  map[string]int
+	ByY    map[int]map[string]int
+}
+
+// NewBlockCounts returns empty counts. byName counts block names without
+// their properties.
+func NewBlockCounts(byName bool) *BlockCounts {
+	return &BlockCounts{
+		ByName: byName,
+		Total:  map[string]int{},
+		ByY:    map[int]map[string]int{},
+	}
+}
+
+func (c *BlockCounts) add(y int, key string, n int) {
+	c.Total[key] += n
+	level := c.ByY[y]
+	if level == nil {
+		level = map[string]int{}
+		c.ByY[y] = level
+	}
+	level[key] += n
+}
+
+// Merge adds the counts of o to c.
+func (c *BlockCounts) Merge(o *BlockCounts) {
+	for y, level := range o.ByY {
+		for key, n := range level {
+			c.add(y, key, n)
+		}
+	}
+}
+
+// AddChunk counts the blocks of chunk, limited to clip when it is non-nil.
+// Sections outside the stored range are skipped.
+func (c *BlockCounts) AddChunk(chunk map[string]any, clip *coords.Box) error {
+	secs, err := chunkSections(chunk)
+	if err != nil {
+		return err
+	}
+	for _, v := range secs {
+		sec, ok := asMap(v)
+		if !ok {
+			continue
+		}
+		sy, ok := asInt(sec["Y"])
+		if !ok {
+			continue
+		}
+		if clip != nil && (sy*16+15 < clip.MinY || sy*16 > clip.MaxY) {
+			continue
+		}
+		m, ok := asMap(sec["block_states"])
+		if !ok {
+			continue
+		}
+		pc, err := decodeContainer(m, blockCells, 4, blockStateKey)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, len(pc.palette))
+		for i, p := range pc.palette {
+			st := blockStateFromNBT(p)
+			if c.ByName {
+				keys[i] = st.Name
+			} else {
+				keys[i] = st.String()
+			}
+		}
+		tally := make([]int, len(pc.palette))
+		for ly := range 16 {
+			y := sy*16 + ly
+			if clip != nil && (y < clip.MinY || y > clip.MaxY) {
+				continue
+			}
+			clear(tally)
+			if clip == nil {
+				for _, idx := range pc.indices[ly<<8 : (ly+1)<<8] {
+					tally[idx]++
+				}
+			} else {
+				for z := clip.MinZ; z <= clip.MaxZ; z++ {
+					for x := clip.MinX; x <= clip.MaxX; x++ {
+						tally[pc.indices[blockIndex(x, y, z)]]++
+					}
+				}
+			}
+			for idx, n := range tally {
+				if n > 0 {
+					c.add(y, keys[idx], n)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// BlockCount is one entry of Sorted.
+type BlockCount struct {
+	Block string `json:"block"`
+	Count int    `json:"count"`
+}
+
+// Sorted returns counts ordered by descending count, then by block.
+func Sorted(counts map[string]int) []BlockCount {
+	out := make([]BlockCount, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, BlockCount{Block: k, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Block < out[j].Block
+	})
+	return out
+}
+
+// Levels returns the Y levels present in ByY in ascending order.
+func (c *BlockCounts) Levels() []int {
+	ys := make([]int, 0, len(c.ByY))
+	for y := range c.ByY {
+		ys = append(ys, y)
+	}
+	sort.Ints(ys)
+	return ys
+}
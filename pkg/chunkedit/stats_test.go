@@ -0,0 +1,43 @@
+package chunkedit
+
+import (
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+func TestBlockCounts(t *testing.T) {
+	chunk := testBlockChunk()
+	if _, err := FillBox(chunk, coords.NewBox(0, 0, 0, 15, 0, 15), BlockState{Name: "minecraft:stone"}); err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+	if _, err := FillBox(chunk, coords.NewBox(0, 1, 0, 0, 1, 0), BlockState{Name: "minecraft:oak_log", Properties: map[string]string{"axis": "y"}}); err != nil {
+		t.Fatalf("FillBox: %v", err)
+	}
+
+	counts := NewBlockCounts(true)
+	if err := counts.AddChunk(chunk, nil); err != nil {
+		t.Fatalf("AddChunk: %v", err)
+	}
+	if got := counts.Total["minecraft:stone"]; got != 256 {
+		t.Fatalf("stone: got %d, want 256", got)
+	}
+	if got := counts.ByY[1]["minecraft:oak_log"]; got != 1 {
+		t.Fatalf("oak_log at y=1: got %d, want 1", got)
+	}
+	if got := counts.Total["minecraft:air"]; got != blockCells-257 {
+		t.Fatalf("air: got %d, want %d", got, blockCells-257)
+	}
+
+	clip := coords.NewBox(0, 0, 0, 1, 1, 1)
+	boxed := NewBlockCounts(false)
+	if err := boxed.AddChunk(chunk, &clip); err != nil {
+		t.Fatalf("AddChunk clipped: %v", err)
+	}
+	if got := boxed.Total["minecraft:oak_log[axis=y]"]; got != 1 {
+		t.Fatalf("clipped oak_log: got %d, want 1", got)
+	}
+	if got := Sorted(boxed.Total)[0]; got.Block != "minecraft:stone" || got.Count != 4 {
+		t.Fatalf("top entry: got %+v", got)
+	}
+}
@@ -0,0 +1,140 @@
+// Package coords converts between block, chunk, section and region
+// coordinates and defines the inclusive Box used by area edits.
+package coords
+
+import (
+	"fmt"
+)
+
+// FloorDiv divides a by b, rounding towards negative infinity.
+func FloorDiv(a, b int) int {
+	q := a / b
+	r := a % b
+	if (r != 0) && ((r > 0) != (b > 0)) {
+		q--
+	}
+	return q
+}
+
+// FloorMod returns a modulo b for positive b, always in [0, b).
+func FloorMod(a, b int) int {
+	r := a % b
+	if r < 0 {
+		r += b
+	}
+	return r
+}
+
+// WorldToRegionXZ returns the region holding absolute block (x, z).
+func WorldToRegionXZ(x, z int) (int, int) {
+	return FloorDiv(x, 512), FloorDiv(z, 512)
+}
+
+// RegionFileName returns the file name of region (rx, rz), r.<rx>.<rz>.mca.
+func RegionFileName(rx, rz int) string {
+	return fmt.Sprintf("r.%d.%d.mca", rx, rz)
+}
+
+// ParseRegionFileName reads the region coordinates from a file name made
+// by RegionFileName.
+func ParseRegionFileName(name string) (rx, rz int, ok bool) {
+	var ext string
+	n, err := fmt.Sscanf(name, "r.%d.%d.%s", &rx, &rz, &ext)
+	if err != nil || n != 3 || ext != "mca" {
+		return 0, 0, false
+	}
+	return rx, rz, true
+}
+
+// WorldToChunkXZ returns the absolute chunk holding absolute block (x, z).
+func WorldToChunkXZ(x, z int) (int, int) {
+	return FloorDiv(x, 16), FloorDiv(z, 16)
+}
+
+// InRegionChunkIndex converts absolute chunk (cx, cz) to its position
+// inside its region file, 0 to 31 on each axis.
+func InRegionChunkIndex(cx, cz int) (int, int) {
+	return FloorMod(cx, 32), FloorMod(cz, 32)
+}
+
+// LocalBlockXZ converts absolute block (x, z) to its position inside its
+// chunk, 0 to 15 on each axis.
+func LocalBlockXZ(x, z int) (int, int) {
+	return FloorMod(x, 16), FloorMod(z, 16)
+}
+
+// Box is an inclusive box of absolute block coordinates. Build it with
+// NewBox so that the minimum corner comes first.
+type Box struct {
+	MinX, MinY, MinZ int
+	MaxX, MaxY, MaxZ int
+}
+
+// NewBox returns the box spanning two opposite corners, given in any order.
+func NewBox(x1, y1, z1, x2, y2, z2 int) Box {
+	return Box{
+		MinX: min(x1, x2), MinY: min(y1, y2), MinZ: min(z1, z2),
+		MaxX: max(x1, x2), MaxY: max(y1, y2), MaxZ: max(z1, z2),
+	}
+}
+
+// Contains reports whether absolute block (x, y, z) is inside the box.
+func (b Box) Contains(x, y, z int) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY && z >= b.MinZ && z <= b.MaxZ
+}
+
+// Volume returns the number of blocks in the box.
+func (b Box) Volume() int {
+	return (b.MaxX - b.MinX + 1) * (b.MaxY - b.MinY + 1) * (b.MaxZ - b.MinZ + 1)
+}
+
+// ChunkRange returns the inclusive absolute chunk bounds covered by the box.
+func (b Box) ChunkRange() (cx0, cz0, cx1, cz1 int) {
+	cx0, cz0 = WorldToChunkXZ(b.MinX, b.MinZ)
+	cx1, cz1 = WorldToChunkXZ(b.MaxX, b.MaxZ)
+	return cx0, cz0, cx1, cz1
+}
+
+// ClipToChunk intersects the box with the column of absolute chunk (cx, cz).
+func (b Box) ClipToChunk(cx, cz int) Box {
+	return Box{
+		MinX: max(b.MinX, cx*16), MinY: b.MinY, MinZ: max(b.MinZ, cz*16),
+		MaxX: min(b.MaxX, cx*16+15), MaxY: b.MaxY, MaxZ: min(b.MaxZ, cz*16+15),
+	}
+}
+
+// ChunkToRegionXZ returns the region holding absolute chunk (cx, cz).
+func ChunkToRegionXZ(cx, cz int) (int, int) {
+	return FloorDiv(cx, 32), FloorDiv(cz, 32)
+}
+
+// SectionY returns the index of the 16-block chunk section containing y.
+func SectionY(y int) int {
+	return FloorDiv(y, 16)
+}
+
+// ChunkOrigin returns the block X/Z of the north-west corner of chunk (cx, cz).
+func ChunkOrigin(cx, cz int) (int, int) {
+	return cx * 16, cz * 16
+}
+
+// RegionOrigin returns the block X/Z of the north-west corner of region (rx, rz).
+func RegionOrigin(rx, rz int) (int, int) {
+	return rx * 512, rz * 512
+}
+
+// RegionHeaderIndex returns the slot of in-region chunk (lx, lz) in the
+// region file's location and timestamp tables.
+func RegionHeaderIndex(lx, lz int) int {
+	return lz*32 + lx
+}
+
+// OverworldToNether scales overworld block X/Z to the nether, as portals do.
+func OverworldToNether(x, z int) (int, int) {
+	return FloorDiv(x, 8), FloorDiv(z, 8)
+}
+
+// NetherToOverworld scales nether block X/Z to the overworld.
+func NetherToOverworld(x, z int) (int, int) {
+	return x * 8, z * 8
+}
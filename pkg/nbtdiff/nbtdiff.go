@@ -0,0 +1,458 @@
+// Package nbtdiff compares decoded NBT values, as produced by go-mc's nbt
+// decoder, and reports the paths that were added, removed or changed along
+// with their tag types.
+package nbtdiff
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind says how a path differs.
+type Kind string
+
+// Kinds of change.
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is one differing path. Old and New are SNBT-like renderings of the
+// values; long arrays and lists are summarised.
+type Change struct {
+	Kind    Kind   `json:"kind"`
+	Path    string `json:"path"`
+	OldType string `json:"old_type,omitempty"`
+	NewType string `json:"new_type,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	// Detail counts the differing elements of an array of unchanged type
+	// and length.
+	Detail string `json:"detail,omitempty"`
+}
+
+// String formats c as one line of diff output, such as
+// "~ path: int 1 -> int 2".
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s %s", c.Path, c.NewType, c.New)
+	case Removed:
+		return fmt.Sprintf("- %s: %s %s", c.Path, c.OldType, c.Old)
+	}
+	s := fmt.Sprintf("~ %s: %s %s -> %s %s", c.Path, c.OldType, c.Old, c.NewType, c.New)
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+	return s
+}
+
+// Options tune a comparison.
+type Options struct {
+	// Ignore skips compound keys with one of these names at any depth, and
+	// the exact paths listed.
+	Ignore []string
+}
+
+// Diff returns the differences from a to b in path order. Lists of
+// compounds that carry an identity, such as block entities (x, y, z),
+// entities (UUID) or sections (Y), are matched by it rather than by index.
+func Diff(a, b any, opt Options) []Change {
+	d := differ{ignore: map[string]bool{}}
+	for _, k := range opt.Ignore {
+		d.ignore[k] = true
+	}
+	d.diff("", a, b)
+	return d.out
+}
+
+type differ struct {
+	ignore map[string]bool
+	out    []Change
+}
+
+// add records a change. Added and removed compounds, and lists holding
+// compounds, are expanded so that every leaf gets its own path.
+func (d *differ) add(kind Kind, path string, a, b any) {
+	if kind != Changed {
+		v := a
+		if kind == Added {
+			v = b
+		}
+		if d.expand(kind, path, v) {
+			return
+		}
+	}
+	c := Change{Kind: kind, Path: path}
+	if kind != Added {
+		c.OldType, c.Old = TypeName(a), Format(a)
+	}
+	if kind != Removed {
+		c.NewType, c.New = TypeName(b), Format(b)
+	}
+	d.out = append(d.out, c)
+}
+
+func (d *differ) expand(kind Kind, path string, v any) bool {
+	if m, ok := v.(map[string]any); ok && len(m) > 0 {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := joinKey(path, k)
+			if d.ignore[k] || d.ignore[p] {
+				continue
+			}
+			d.addSide(kind, p, m[k])
+		}
+		return true
+	}
+	l, ok := asList(v)
+	if !ok || len(l) == 0 {
+		return false
+	}
+	if _, ok := l[0].(map[string]any); !ok {
+		return false
+	}
+	if ids, ok := identities(l); ok {
+		for i, e := range l {
+			d.addSide(kind, path+"["+ids[i]+"]", e)
+		}
+		return true
+	}
+	for i, e := range l {
+		d.addSide(kind, path+"["+strconv.Itoa(i)+"]", e)
+	}
+	return true
+}
+
+func (d *differ) addSide(kind Kind, path string, v any) {
+	if kind == Added {
+		d.add(kind, path, nil, v)
+	} else {
+		d.add(kind, path, v, nil)
+	}
+}
+
+func (d *differ) diff(path string, a, b any) {
+	if ma, ok := a.(map[string]any); ok {
+		if mb, ok := b.(map[string]any); ok {
+			d.compound(path, ma, mb)
+			return
+		}
+	}
+	la, aList := asList(a)
+	lb, bList := asList(b)
+	if aList && bList && TypeName(a) == TypeName(b) {
+		d.list(path, la, lb)
+		return
+	}
+	if TypeName(a) != TypeName(b) {
+		d.add(Changed, path, a, b)
+		return
+	}
+	if n, total, ok := arrayDiff(a, b); ok {
+		if n > 0 {
+			d.add(Changed, path, a, b)
+			if total > 0 {
+				d.out[len(d.out)-1].Detail = fmt.Sprintf("%d of %d elements differ", n, total)
+			}
+		}
+		return
+	}
+	if !equalScalar(a, b) {
+		d.add(Changed, path, a, b)
+	}
+}
+
+func (d *differ) compound(path string, a, b map[string]any) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := joinKey(path, k)
+		if d.ignore[k] || d.ignore[p] {
+			continue
+		}
+		va, okA := a[k]
+		vb, okB := b[k]
+		switch {
+		case !okA:
+			d.add(Added, p, nil, vb)
+		case !okB:
+			d.add(Removed, p, va, nil)
+		default:
+			d.diff(p, va, vb)
+		}
+	}
+}
+
+func (d *differ) list(path string, a, b []any) {
+	ia, okA := identities(a)
+	ib, okB := identities(b)
+	if okA && okB {
+		pos := make(map[string]int, len(b))
+		for i, id := range ib {
+			pos[id] = i
+		}
+		seen := make(map[string]bool, len(a))
+		for i, id := range ia {
+			p := path + "[" + id + "]"
+			seen[id] = true
+			if j, ok := pos[id]; ok {
+				d.diff(p, a[i], b[j])
+			} else {
+				d.add(Removed, p, a[i], nil)
+			}
+		}
+		for j, id := range ib {
+			if !seen[id] {
+				d.add(Added, path+"["+id+"]", nil, b[j])
+			}
+		}
+		return
+	}
+	for i := 0; i < max(len(a), len(b)); i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= len(a):
+			d.add(Added, p, nil, b[i])
+		case i >= len(b):
+			d.add(Removed, p, a[i], nil)
+		default:
+			d.diff(p, a[i], b[i])
+		}
+	}
+}
+
+// identities returns a unique identity for every compound of l, or false
+// when some element has none or two share one.
+func identities(l []any) ([]string, bool) {
+	if len(l) == 0 {
+		return nil, true
+	}
+	ids := make([]string, len(l))
+	seen := make(map[string]bool, len(l))
+	for i, v := range l {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		id, ok := identity(m)
+		if !ok || seen[id] {
+			return nil, false
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+	return ids, true
+}
+
+func identity(m map[string]any) (string, bool) {
+	if x, ok := m["x"]; ok {
+		y, okY := m["y"]
+		z, okZ := m["z"]
+		if okY && okZ {
+			return fmt.Sprintf("x=%v,y=%v,z=%v", x, y, z), true
+		}
+	}
+	if u, ok := m["UUID"]; ok {
+		return "UUID=" + Format(u), true
+	}
+	if y, ok := m["Y"]; ok {
+		return fmt.Sprintf("Y=%v", y), true
+	}
+	return "", false
+}
+
+func joinKey(path, k string) string {
+	if strings.ContainsAny(k, ".[] ") {
+		k = strconv.Quote(k)
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func asList(v any) ([]any, bool) {
+	switch l := v.(type) {
+	case []any:
+		return l, true
+	case []map[string]any:
+		out := make([]any, len(l))
+		for i, m := range l {
+			out[i] = m
+		}
+		return out, true
+	case []string:
+		out := make([]any, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// arrayDiff counts the differing elements of two typed arrays. total is
+// zero when the lengths differ.
+func arrayDiff(a, b any) (n, total int, ok bool) {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != reflect.Slice || vb.Kind() != reflect.Slice {
+		return 0, 0, false
+	}
+	if va.Len() != vb.Len() {
+		return 1, 0, true
+	}
+	for i := range va.Len() {
+		if !equalScalar(va.Index(i).Interface(), vb.Index(i).Interface()) {
+			n++
+		}
+	}
+	return n, va.Len(), true
+}
+
+// equalScalar compares two values of the same tag type. Values such as
+// typed maps or nested slices, which == cannot compare, are compared
+// deeply.
+func equalScalar(a, b any) bool {
+	a, b = byteValue(a), byteValue(b)
+	switch x := a.(type) {
+	case float32:
+		y, ok := b.(float32)
+		return ok && (x == y || (math.IsNaN(float64(x)) && math.IsNaN(float64(y))))
+	case float64:
+		y, ok := b.(float64)
+		return ok && (x == y || (math.IsNaN(x) && math.IsNaN(y)))
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsValid() && vb.IsValid() && va.Comparable() && vb.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// byteValue returns a bool, which is encoded as a byte tag, as that byte.
+func byteValue(v any) any {
+	if b, ok := v.(bool); ok {
+		if b {
+			return int8(1)
+		}
+		return int8(0)
+	}
+	return v
+}
+
+// TypeName returns the NBT tag name of a decoded value.
+func TypeName(v any) string {
+	switch v.(type) {
+	case int8, bool:
+		return "byte"
+	case int16:
+		return "short"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float32:
+		return "float"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case []byte, []int8:
+		return "byte_array"
+	case []int32:
+		return "int_array"
+	case []int64:
+		return "long_array"
+	case map[string]any:
+		return "compound"
+	case nil:
+		return "end"
+	}
+	if _, ok := asList(v); ok {
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+const maxElems = 8
+
+// Format renders v in SNBT-like form. Arrays and lists longer than eight
+// elements and all compounds are summarised.
+func Format(v any) string {
+	switch x := v.(type) {
+	case int8:
+		return strconv.Itoa(int(x)) + "b"
+	case bool:
+		if x {
+			return "1b"
+		}
+		return "0b"
+	case int16:
+		return strconv.Itoa(int(x)) + "s"
+	case int32:
+		return strconv.Itoa(int(x))
+	case int64:
+		return strconv.FormatInt(x, 10) + "L"
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32) + "f"
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64) + "d"
+	case string:
+		return strconv.Quote(x)
+	case map[string]any:
+		if len(x) == 0 {
+			return "{}"
+		}
+		return fmt.Sprintf("{%d entries}", len(x))
+	case []byte:
+		return formatArray("B", len(x), func(i int) string { return strconv.Itoa(int(int8(x[i]))) })
+	case []int8:
+		return formatArray("B", len(x), func(i int) string { return strconv.Itoa(int(x[i])) })
+	case []int32:
+		return formatArray("I", len(x), func(i int) string { return strconv.Itoa(int(x[i])) })
+	case []int64:
+		return formatArray("L", len(x), func(i int) string { return strconv.FormatInt(x[i], 10) })
+	case nil:
+		return ""
+	}
+	if l, ok := asList(v); ok {
+		if len(l) > maxElems {
+			return fmt.Sprintf("[%d elements]", len(l))
+		}
+		parts := make([]string, len(l))
+		for i, e := range l {
+			parts[i] = Format(e)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	return fmt.Sprint(v)
+}
+
+func formatArray(prefix string, n int, elem func(int) string) string {
+	if n > maxElems {
+		return fmt.Sprintf("[%s; %d elements]", prefix, n)
+	}
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = elem(i)
+	}
+	return "[" + prefix + ";" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,87 @@
+package nbtdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := map[string]any{
+		"DataVersion": int32(3953),
+		"LastUpdate":  int64(10),
+		"Status":      "full",
+		"block_entities": []any{
+			map[string]any{"id": "minecraft:chest", "x": int32(1), "y": int32(64), "z": int32(2), "Lock": "a"},
+			map[string]any{"id": "minecraft:sign", "x": int32(3), "y": int32(64), "z": int32(2)},
+		},
+		"data": []int64{1, 2, 3},
+	}
+	b := map[string]any{
+		"DataVersion": int16(3953),
+		"LastUpdate":  int64(20),
+		"block_entities": []any{
+			map[string]any{"id": "minecraft:sign", "x": int32(3), "y": int32(64), "z": int32(2)},
+			map[string]any{"id": "minecraft:chest", "x": int32(1), "y": int32(64), "z": int32(2), "Lock": "b"},
+			map[string]any{"id": "minecraft:barrel", "x": int32(5), "y": int32(64), "z": int32(2)},
+		},
+		"data": []int64{1, 5, 3},
+		"new":  int8(1),
+	}
+
+	got := Diff(a, b, Options{Ignore: []string{"LastUpdate"}})
+	// Keys are visited in byte order, so capitals come first.
+	want := []string{
+		"~ DataVersion: int 3953 -> short 3953s",
+		"- Status: string \"full\"",
+		"~ block_entities[x=1,y=64,z=2].Lock: string \"a\" -> string \"b\"",
+		"+ block_entities[x=5,y=64,z=2].id: string \"minecraft:barrel\"",
+		"+ block_entities[x=5,y=64,z=2].x: int 5",
+		"+ block_entities[x=5,y=64,z=2].y: int 64",
+		"+ block_entities[x=5,y=64,z=2].z: int 2",
+		"~ data: long_array [L;1,2,3] -> long_array [L;1,5,3] (1 of 3 elements differ)",
+		"+ new: byte 1b",
+	}
+	var lines []string
+	for _, c := range got {
+		lines = append(lines, c.String())
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("diff:\n%q\nwant:\n%q", lines, want)
+	}
+
+	if d := Diff(a, a, Options{}); len(d) != 0 {
+		t.Fatalf("diff of equal values: %v", d)
+	}
+}
+
+func TestDiffListsByIndex(t *testing.T) {
+	a := map[string]any{"Pos": []any{1.0, 2.0}}
+	b := map[string]any{"Pos": []any{1.0, 3.0, 4.0}}
+	got := Diff(a, b, Options{})
+	if len(got) != 2 || got[0].Path != "Pos[1]" || got[1].Kind != Added || got[1].Path != "Pos[2]" {
+		t.Fatalf("diff: %v", got)
+	}
+}
+
+func TestDiffUncomparable(t *testing.T) {
+	a := map[string]any{
+		"flag":  true,
+		"names": map[string]string{"a": "b"},
+		"rows":  [][]int64{{1}, {2}},
+	}
+	b := map[string]any{
+		"flag":  int8(1),
+		"names": map[string]string{"a": "b"},
+		"rows":  [][]int64{{1}, {2}},
+	}
+	if d := Diff(a, b, Options{}); len(d) != 0 {
+		t.Fatalf("diff of equal values: %v", d)
+	}
+	b["flag"] = int8(0)
+	b["names"] = map[string]string{"a": "c"}
+	b["rows"] = [][]int64{{1}, {3}}
+	got := Diff(a, b, Options{})
+	if len(got) != 3 || got[0].Path != "flag" || got[1].Path != "names" || got[2].Detail != "1 of 2 elements differ" {
+		t.Fatalf("diff: %v", got)
+	}
+}
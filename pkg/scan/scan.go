@@ -0,0 +1,193 @@
+// Package scan walks region files with a bounded pool of workers. Each
+// worker owns one region at a time, so chunks of different regions are
+// read, decoded and written concurrently.
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+)
+
+// Chunk is one decoded chunk handed to a Func.
+type Chunk struct {
+	Region string
+	X, Z   int
+	Data   map[string]any
+}
+
+// Func handles one chunk and reports whether Data changed and must be
+// written back. It is called from several goroutines at once.
+type Func func(c Chunk) (bool, error)
+
+// Progress is passed to Options.Progress each time a region is finished.
+type Progress struct {
+	Regions int
+	Done    int
+	Chunks  int
+	Written int
+}
+
+// Options tunes a scan.
+type Options struct {
+	// Workers bounds the number of regions processed at once; zero means
+	// GOMAXPROCS.
+	Workers int
+	// Progress, when set, is called after every region. Calls never
+	// overlap.
+	Progress func(Progress)
+	// Keys, when set, limits decoding to these top-level tags of each
+	// chunk. Such partial chunks are read-only: a Func reporting a change
+	// fails the scan with ErrPartialChunk.
+	Keys []string
+	// Write selects the compression of chunks written back.
+	Write anvil.WriteOptions
+	// BeforeWrite, when set, runs before each chunk is written back. It is
+	// called from several goroutines at once.
+	BeforeWrite anvil.WriteHook
+	// AfterWrite, when set, runs after each chunk is written back. It is
+	// called from several goroutines at once.
+	AfterWrite anvil.WriteHook
+	// DryRun, when set, receives the chunks that would be written instead.
+	// It is called from several goroutines at once.
+	DryRun anvil.DryRunFunc
+}
+
+// ErrPartialChunk is returned when a Func reports a change to a chunk
+// decoded with Options.Keys.
+var ErrPartialChunk = errors.New("chunk decoded with Keys cannot be written back")
+
+// Dir scans every region file in dir.
+func Dir(ctx context.Context, dir string, opt Options, fn Func) (anvil.Stats, error) {
+	paths, err := anvil.NewSession(dir).RegionFiles()
+	if err != nil {
+		return anvil.Stats{}, err
+	}
+	return Files(ctx, paths, opt, fn)
+}
+
+// Files scans the given region files. The first error, from fn or from a
+// region, stops the scan, as does cancelling ctx. Edits to a region are
+// written chunk by chunk once all of its chunks were handled, so a scan
+// stopped before then leaves the region untouched, but a failed write can
+// leave it partly edited.
+func Files(ctx context.Context, paths []string, opt Options, fn Func) (anvil.Stats, error) {
+	return Regions(ctx, paths, opt, func(ctx context.Context, path string) (anvil.Stats, error) {
+		return scanFile(ctx, path, opt, fn)
+	})
+}
+
+// RegionFunc processes the whole region file at path. It is called from
+// several goroutines at once and should give up once ctx is done.
+type RegionFunc func(ctx context.Context, path string) (anvil.Stats, error)
+
+// Regions runs fn over the given region files on the worker pool of Files,
+// with the same progress reporting and error handling. Options.Keys is
+// ignored.
+func Regions(ctx context.Context, paths []string, opt Options, fn RegionFunc) (anvil.Stats, error) {
+	workers := opt.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(paths))
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		st       anvil.Stats
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				rs, err := fn(scanCtx, path)
+				mu.Lock()
+				st.Add(rs)
+				done++
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				if opt.Progress != nil {
+					opt.Progress(Progress{Regions: len(paths), Done: done, Chunks: st.Chunks, Written: st.Written})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, p := range paths {
+		select {
+		case jobs <- p:
+		case <-scanCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return st, firstErr
+	}
+	return st, ctx.Err()
+}
+
+func scanFile(ctx context.Context, path string, opt Options, fn Func) (anvil.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return anvil.Stats{}, err
+	}
+	s := anvil.NewFileSession(path)
+	defer s.Close()
+	s.SetWriteOptions(opt.Write)
+	s.SetBeforeWrite(opt.BeforeWrite)
+	s.SetAfterWrite(opt.AfterWrite)
+	s.SetDryRun(opt.DryRun)
+	if len(opt.Keys) > 0 {
+		return scanKeys(ctx, s, path, opt.Keys, fn)
+	}
+	return s.EditRegion(path, func(chunk map[string]any, cx, cz int) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return fn(Chunk{Region: path, X: cx, Z: cz, Data: chunk})
+	})
+}
+
+func scanKeys(ctx context.Context, s *anvil.Session, path string, keys []string, fn Func) (anvil.Stats, error) {
+	var st anvil.Stats
+	present, err := s.StoredChunks(path)
+	if err != nil {
+		return st, err
+	}
+	for _, c := range present {
+		if err := ctx.Err(); err != nil {
+			return st, err
+		}
+		cx, cz := c[0], c[1]
+		data, err := s.ChunkKeys(cx, cz, keys...)
+		if err != nil {
+			return st, fmt.Errorf("%s: read chunk %d,%d: %w", path, cx, cz, err)
+		}
+		st.Chunks++
+		changed, err := fn(Chunk{Region: path, X: cx, Z: cz, Data: data})
+		if err == nil && changed {
+			err = ErrPartialChunk
+		}
+		if err != nil {
+			return st, fmt.Errorf("%s: chunk %d,%d: %w", path, cx, cz, err)
+		}
+	}
+	return st, nil
+}
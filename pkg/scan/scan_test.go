@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/anvil"
+	"github.com/Zeptile/nbt-cli/pkg/coords"
+)
+
+// writeRegions creates n regions along X with two chunks each.
+func writeRegions(t *testing.T, dir string, n int) {
+	t.Helper()
+	for rx := range n {
+		path := filepath.Join(dir, coords.RegionFileName(rx, 0))
+		if err := os.WriteFile(path, make([]byte, 8192), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		r, err := anvil.OpenRegionFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for lx := range 2 {
+			if err := r.WriteChunkNBT(lx, 0, map[string]any{"Status": "full"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Close()
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	writeRegions(t, dir, 5)
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]bool{}
+		last Progress
+	)
+	opt := Options{Workers: 3, Progress: func(p Progress) { last = p }}
+	st, err := Dir(context.Background(), dir, opt, func(c Chunk) (bool, error) {
+		mu.Lock()
+		seen[fmt.Sprint(c.X, c.Z)] = true
+		mu.Unlock()
+		if c.X == 32 {
+			c.Data["Status"] = "edited"
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("Dir: %v", err)
+	}
+	if st.Chunks != 10 || st.Written != 1 || len(seen) != 10 || !seen["129 0"] {
+		t.Fatalf("stats %+v, seen %v", st, seen)
+	}
+	if last.Done != 5 || last.Regions != 5 || last.Chunks != 10 {
+		t.Fatalf("last progress %+v", last)
+	}
+
+	s := anvil.NewSession(dir)
+	defer s.Close()
+	chunk, err := s.Chunk(32, 0)
+	if err != nil || chunk["Status"] != "edited" {
+		t.Fatalf("edited chunk: %v %v", chunk, err)
+	}
+}
+
+func TestDirStopsOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeRegions(t, dir, 4)
+
+	boom := errors.New("boom")
+	st, err := Dir(context.Background(), dir, Options{Workers: 1}, func(c Chunk) (bool, error) {
+		c.Data["Status"] = "edited"
+		if c.X == 1 {
+			return true, boom
+		}
+		return true, nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("got %v, want boom", err)
+	}
+	if st.Written != 0 {
+		t.Fatalf("region with an error was written: %+v", st)
+	}
+}
+
+func TestDirCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeRegions(t, dir, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	_, err := Dir(ctx, dir, Options{}, func(c Chunk) (bool, error) {
+		called = true
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) || called {
+		t.Fatalf("got err=%v called=%v", err, called)
+	}
+}
+
+func TestDirKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeRegions(t, dir, 2)
+
+	st, err := Dir(context.Background(), dir, Options{Keys: []string{"Status"}}, func(c Chunk) (bool, error) {
+		if len(c.Data) != 1 || c.Data["Status"] != "full" {
+			return false, fmt.Errorf("unexpected partial chunk %v", c.Data)
+		}
+		return false, nil
+	})
+	if err != nil || st.Chunks != 4 {
+		t.Fatalf("stats %+v, err %v", st, err)
+	}
+
+	_, err = Dir(context.Background(), dir, Options{Keys: []string{"Status"}}, func(c Chunk) (bool, error) {
+		return true, nil
+	})
+	if !errors.Is(err, ErrPartialChunk) {
+		t.Fatalf("got %v, want ErrPartialChunk", err)
+	}
+}
@@ -0,0 +1,254 @@
+package schematic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+// LegacyReport lists what a legacy import could not carry over.
+type LegacyReport struct {
+	// Unknown counts blocks, keyed "id:data", that had no translation and
+	// were imported as air.
+	Unknown map[string]int
+	// DroppedTileEntities counts tile entities whose block no longer has a
+	// block entity or whose position was invalid.
+	DroppedTileEntities int
+	// DroppedEntities counts entities, which are not imported.
+	DroppedEntities int
+}
+
+// LegacyBlockState translates a pre-1.13 numeric block id and data value
+// into a modern block state. Unknown data values fall back to data 0 of the
+// same id; unknown ids report false.
+func LegacyBlockState(id, data int) (chunkedit.BlockState, bool) {
+	fn, ok := legacyBlocks[id]
+	if !ok {
+		return chunkedit.BlockState{Name: "minecraft:air"}, false
+	}
+	st, err := chunkedit.ParseBlockState(fn(data & 15))
+	if err != nil {
+		return chunkedit.BlockState{Name: "minecraft:air"}, false
+	}
+	return st, true
+}
+
+// ReadLegacy reads a gzip-compressed MCEdit (Alpha) .schematic, translating
+// numeric block ids and tile entities to their modern equivalents.
+func ReadLegacy(r io.Reader) (*Schematic, *LegacyReport, error) {
+	root, err := readRoot(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fromLegacy(root)
+}
+
+// Read reads a Sponge schematic or a legacy MCEdit .schematic, detected from
+// its content. The report is nil for Sponge schematics.
+func Read(r io.Reader) (*Schematic, *LegacyReport, error) {
+	root, err := readRoot(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := root["Blocks"].([]byte); ok {
+		return fromLegacy(root)
+	}
+	s, err := fromSponge(root)
+	return s, nil, err
+}
+
+func fromLegacy(root map[string]any) (*Schematic, *LegacyReport, error) {
+	if m, ok := root["Materials"].(string); ok && m != "Alpha" {
+		return nil, nil, fmt.Errorf("unsupported schematic materials %q", m)
+	}
+	w, _ := toInt(root["Width"])
+	h, _ := toInt(root["Height"])
+	l, _ := toInt(root["Length"])
+	s := New(w&0xFFFF, h&0xFFFF, l&0xFFFF)
+
+	ids, _ := root["Blocks"].([]byte)
+	data, _ := root["Data"].([]byte)
+	add, _ := root["AddBlocks"].([]byte)
+	if len(ids) != len(s.Blocks) || len(data) != len(s.Blocks) {
+		return nil, nil, fmt.Errorf("schematic has %d blocks and %d data values, want %d", len(ids), len(data), len(s.Blocks))
+	}
+
+	report := &LegacyReport{Unknown: map[string]int{}}
+	cache := map[int]int{}
+	for i, b := range ids {
+		id := int(b)
+		if i>>1 < len(add) {
+			if i&1 == 0 {
+				id |= int(add[i>>1]&0x0F) << 8
+			} else {
+				id |= int(add[i>>1]&0xF0) << 4
+			}
+		}
+		key := id<<4 | int(data[i]&15)
+		p, ok := cache[key]
+		if !ok {
+			st, known := LegacyBlockState(id, int(data[i]&15))
+			if !known {
+				report.Unknown[fmt.Sprintf("%d:%d", id, data[i]&15)]++
+			}
+			p = s.PaletteID(st)
+			cache[key] = p
+		} else if s.Palette[p].IsAir() && id != 0 {
+			report.Unknown[fmt.Sprintf("%d:%d", id, data[i]&15)]++
+		}
+		s.Blocks[i] = p
+	}
+	fixDoublePlants(s)
+	fixDoors(s)
+
+	tiles, _ := root["TileEntities"].([]any)
+	for _, v := range tiles {
+		m, _ := v.(map[string]any)
+		x, okx := toInt(m["x"])
+		y, oky := toInt(m["y"])
+		z, okz := toInt(m["z"])
+		if !okx || !oky || !okz || !s.Contains(x, y, z) {
+			report.DroppedTileEntities++
+			continue
+		}
+		st := legacyTileState(s.At(x, y, z), m)
+		s.Set(x, y, z, st)
+		id, ok := chunkedit.BlockEntityID(st.Name)
+		if !ok {
+			report.DroppedTileEntities++
+			continue
+		}
+		s.BlockEntities = append(s.BlockEntities, BlockEntity{
+			X: x, Y: y, Z: z,
+			ID:   id,
+			Data: legacyTileData(m),
+		})
+	}
+	ents, _ := root["Entities"].([]any)
+	report.DroppedEntities = len(ents)
+
+	if err := s.validate(); err != nil {
+		return nil, nil, err
+	}
+	if len(s.Palette) == 0 {
+		return nil, nil, errors.New("schematic has no blocks")
+	}
+	return s, report, nil
+}
+
+// fixDoublePlants gives upper plant halves the kind of the half below, which
+// legacy data values only stored on the lower half.
+func fixDoublePlants(s *Schematic) {
+	for y := 1; y < s.Height; y++ {
+		for z := range s.Length {
+			for x := range s.Width {
+				st := s.At(x, y, z)
+				if st.Properties["half"] != "upper" || st.Name != "minecraft:sunflower" {
+					continue
+				}
+				below := s.At(x, y-1, z)
+				if below.Properties["half"] == "lower" && below.Name != st.Name {
+					s.Set(x, y, z, chunkedit.BlockState{Name: below.Name, Properties: map[string]string{"half": "upper"}})
+				}
+			}
+		}
+	}
+}
+
+// fixDoors completes both halves of every door. Legacy data values kept
+// facing and open on the lower half and hinge and powered on the upper one.
+func fixDoors(s *Schematic) {
+	for y := 1; y < s.Height; y++ {
+		for z := range s.Length {
+			for x := range s.Width {
+				up := s.At(x, y, z)
+				if up.Properties["half"] != "upper" || !strings.HasSuffix(up.Name, "_door") {
+					continue
+				}
+				low := s.At(x, y-1, z)
+				if low.Name != up.Name || low.Properties["half"] != "lower" {
+					continue
+				}
+				half := func(h string) chunkedit.BlockState {
+					return chunkedit.BlockState{Name: up.Name, Properties: map[string]string{
+						"facing":  low.Properties["facing"],
+						"half":    h,
+						"hinge":   up.Properties["hinge"],
+						"open":    low.Properties["open"],
+						"powered": up.Properties["powered"],
+					}}
+				}
+				s.Set(x, y-1, z, half("lower"))
+				s.Set(x, y, z, half("upper"))
+			}
+		}
+	}
+}
+
+var skullNames = []string{"skeleton", "wither_skeleton", "zombie", "player", "creeper", "dragon"}
+
+// legacyTileState refines a translated block with details that legacy
+// versions kept in the tile entity: skull type and rotation, banner and bed
+// color.
+func legacyTileState(st chunkedit.BlockState, m map[string]any) chunkedit.BlockState {
+	props := make(map[string]string, len(st.Properties))
+	for k, v := range st.Properties {
+		props[k] = v
+	}
+	switch st.Name {
+	case "minecraft:skeleton_skull", "minecraft:skeleton_wall_skull":
+		typ, _ := toInt(m["SkullType"])
+		kind := pick(skullNames, typ)
+		suffix := "_head"
+		if kind == "skeleton" || kind == "wither_skeleton" {
+			suffix = "_skull"
+		}
+		name := "minecraft:" + kind + suffix
+		if st.Name == "minecraft:skeleton_wall_skull" {
+			name = "minecraft:" + kind + "_wall" + suffix
+		} else if rot, ok := toInt(m["Rot"]); ok {
+			props["rotation"] = fmt.Sprint(rot & 15)
+		}
+		return chunkedit.BlockState{Name: name, Properties: props}
+	case "minecraft:white_banner", "minecraft:white_wall_banner":
+		base, ok := toInt(m["Base"])
+		if !ok {
+			return st
+		}
+		name := strings.Replace(st.Name, "white", colors[15-base&15], 1)
+		return chunkedit.BlockState{Name: name, Properties: props}
+	case "minecraft:red_bed":
+		// Beds before 1.12 had no tile entity and were all red.
+		color, ok := toInt(m["color"])
+		if !ok {
+			return st
+		}
+		return chunkedit.BlockState{Name: "minecraft:" + colors[color&15] + "_bed", Properties: props}
+	}
+	return st
+}
+
+// legacyTileData converts the fields of a legacy tile entity that changed
+// format. Everything else, including inventories, is copied as is.
+func legacyTileData(m map[string]any) map[string]any {
+	data := without(m, "id", "x", "y", "z", "SkullType", "Rot", "Base", "color")
+	if _, ok := m["Text1"]; ok {
+		msgs := make([]any, 4)
+		for i := range msgs {
+			text, _ := m[fmt.Sprintf("Text%d", i+1)].(string)
+			if text == "" {
+				text = `""`
+			}
+			msgs[i] = text
+			delete(data, fmt.Sprintf("Text%d", i+1))
+		}
+		empty := []any{`""`, `""`, `""`, `""`}
+		data["front_text"] = map[string]any{"messages": msgs, "color": "black", "has_glowing_text": int8(0)}
+		data["back_text"] = map[string]any{"messages": empty, "color": "black", "has_glowing_text": int8(0)}
+		data["is_waxed"] = int8(0)
+	}
+	return data
+}
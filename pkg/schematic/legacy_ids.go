@@ -0,0 +1,559 @@
+package schematic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// legacyFn translates a pre-1.13 data value into a modern block state
+// string for one numeric block id.
+type legacyFn func(data int) string
+
+var colors = []string{
+	"white", "orange", "magenta", "light_blue", "yellow", "lime", "pink", "gray",
+	"light_gray", "cyan", "purple", "blue", "brown", "green", "red", "black",
+}
+
+var woods = []string{"oak", "spruce", "birch", "jungle", "acacia", "dark_oak"}
+
+// Direction tables as used by the 1.12 block classes.
+var (
+	horizontal = []string{"south", "west", "north", "east"}
+	front      = []string{"down", "up", "north", "south", "west", "east"}
+	trapdoor   = []string{"north", "south", "west", "east"}
+	stairsDir  = []string{"east", "west", "south", "north"}
+	doorDir    = []string{"east", "south", "west", "north"}
+	railShapes = []string{
+		"north_south", "east_west", "ascending_east", "ascending_west", "ascending_north",
+		"ascending_south", "south_east", "south_west", "north_west", "north_east",
+	}
+)
+
+func pick(list []string, i int) string {
+	if i < 0 || i >= len(list) {
+		return list[0]
+	}
+	return list[i]
+}
+
+func bit(data, mask int) string {
+	return fmt.Sprint(data&mask != 0)
+}
+
+// withProp appends a property to a block state string.
+func withProp(state, prop string) string {
+	if strings.HasSuffix(state, "]") {
+		return strings.TrimSuffix(state, "]") + "," + prop + "]"
+	}
+	return state + "[" + prop + "]"
+}
+
+func simple(name string) legacyFn {
+	return func(int) string { return name }
+}
+
+func variants(names ...string) legacyFn {
+	return func(d int) string { return pick(names, d) }
+}
+
+func colored(suffix string) legacyFn {
+	return func(d int) string { return colors[d&15] + "_" + suffix }
+}
+
+func axisOf(d int) string {
+	return pick([]string{"y", "x", "z", "y"}, d&3)
+}
+
+func logs(names ...string) legacyFn {
+	return func(d int) string {
+		name := pick(names, d&3)
+		if d>>2 == 3 {
+			return name + "_wood[axis=y]"
+		}
+		return name + "_log[axis=" + axisOf(d>>2) + "]"
+	}
+}
+
+func leaves(names ...string) legacyFn {
+	return func(d int) string {
+		return pick(names, d&3) + "_leaves[distance=7,persistent=" + bit(d, 4) + "]"
+	}
+}
+
+func pillar(name string) legacyFn {
+	return func(d int) string { return name + "[axis=" + axisOf(d>>2) + "]" }
+}
+
+func stairs(name string) legacyFn {
+	return func(d int) string {
+		half := "bottom"
+		if d&4 != 0 {
+			half = "top"
+		}
+		return name + "[facing=" + stairsDir[d&3] + ",half=" + half + ",shape=straight]"
+	}
+}
+
+func slabs(double bool, names ...string) legacyFn {
+	return func(d int) string {
+		typ := "bottom"
+		switch {
+		case double:
+			typ = "double"
+		case d&8 != 0:
+			typ = "top"
+		}
+		return pick(names, d&7) + "_slab[type=" + typ + "]"
+	}
+}
+
+func facingFront(name string) legacyFn {
+	return func(d int) string { return name + "[facing=" + pick(front, d&7) + "]" }
+}
+
+func facingWall(name string) legacyFn {
+	return func(d int) string {
+		f := pick(front, d&7)
+		if f == "down" || f == "up" {
+			f = "north"
+		}
+		return name + "[facing=" + f + "]"
+	}
+}
+
+func facingHorizontal(name string) legacyFn {
+	return func(d int) string { return name + "[facing=" + horizontal[d&3] + "]" }
+}
+
+func aged(name string) legacyFn {
+	return func(d int) string { return name + "[age=" + fmt.Sprint(d) + "]" }
+}
+
+func door(name string) legacyFn {
+	return func(d int) string {
+		if d&8 != 0 {
+			hinge := "left"
+			if d&1 != 0 {
+				hinge = "right"
+			}
+			return name + "[half=upper,hinge=" + hinge + ",powered=" + bit(d, 2) + "]"
+		}
+		return name + "[facing=" + doorDir[d&3] + ",half=lower,open=" + bit(d, 4) + "]"
+	}
+}
+
+func fenceGate(name string) legacyFn {
+	return func(d int) string {
+		return name + "[facing=" + horizontal[d&3] + ",open=" + bit(d, 4) + ",powered=" + bit(d, 8) + "]"
+	}
+}
+
+func trapdoorOf(name string) legacyFn {
+	return func(d int) string {
+		half := "bottom"
+		if d&8 != 0 {
+			half = "top"
+		}
+		return name + "[facing=" + trapdoor[d&3] + ",half=" + half + ",open=" + bit(d, 4) + "]"
+	}
+}
+
+func torch(name, wallName string) legacyFn {
+	return func(d int) string {
+		switch d {
+		case 1:
+			return wallName + "[facing=east]"
+		case 2:
+			return wallName + "[facing=west]"
+		case 3:
+			return wallName + "[facing=south]"
+		case 4:
+			return wallName + "[facing=north]"
+		}
+		return name
+	}
+}
+
+func button(name string) legacyFn {
+	return func(d int) string {
+		face, facing := "wall", "north"
+		switch d & 7 {
+		case 0:
+			face = "ceiling"
+		case 1:
+			facing = "east"
+		case 2:
+			facing = "west"
+		case 3:
+			facing = "south"
+		case 5:
+			face = "floor"
+		}
+		return name + "[face=" + face + ",facing=" + facing + ",powered=" + bit(d, 8) + "]"
+	}
+}
+
+func rail(name string, powered bool) legacyFn {
+	return func(d int) string {
+		if !powered {
+			return name + "[shape=" + pick(railShapes, d) + "]"
+		}
+		return name + "[powered=" + bit(d, 8) + ",shape=" + pick(railShapes, d&7) + "]"
+	}
+}
+
+func piston(name string) legacyFn {
+	return func(d int) string {
+		return name + "[extended=" + bit(d, 8) + ",facing=" + pick(front, d&7) + "]"
+	}
+}
+
+func pressurePlate(name string) legacyFn {
+	return func(d int) string { return name + "[powered=" + bit(d, 1) + "]" }
+}
+
+func weightedPlate(name string) legacyFn {
+	return func(d int) string { return name + "[power=" + fmt.Sprint(d&15) + "]" }
+}
+
+func fluid(name string) legacyFn {
+	return func(d int) string { return name + "[level=" + fmt.Sprint(d&15) + "]" }
+}
+
+func repeater(powered bool) legacyFn {
+	return func(d int) string {
+		return "repeater[delay=" + fmt.Sprint(d>>2+1) + ",facing=" + horizontal[d&3] + ",locked=false,powered=" + fmt.Sprint(powered) + "]"
+	}
+}
+
+func comparator(powered bool) legacyFn {
+	return func(d int) string {
+		mode := "compare"
+		if d&4 != 0 {
+			mode = "subtract"
+		}
+		return "comparator[facing=" + horizontal[d&3] + ",mode=" + mode + ",powered=" + fmt.Sprint(powered || d&8 != 0) + "]"
+	}
+}
+
+func lever(d int) string {
+	face, facing := "wall", "north"
+	switch d & 7 {
+	case 0:
+		face, facing = "ceiling", "west"
+	case 1:
+		facing = "east"
+	case 2:
+		facing = "west"
+	case 3:
+		facing = "south"
+	case 5:
+		face = "floor"
+	case 6:
+		face, facing = "floor", "west"
+	case 7:
+		face = "ceiling"
+	}
+	return "lever[face=" + face + ",facing=" + facing + ",powered=" + bit(d, 8) + "]"
+}
+
+func bed(d int) string {
+	part := "foot"
+	if d&8 != 0 {
+		part = "head"
+	}
+	return "red_bed[facing=" + horizontal[d&3] + ",occupied=" + bit(d, 4) + ",part=" + part + "]"
+}
+
+func skull(d int) string {
+	if d&7 == 1 {
+		return "skeleton_skull[rotation=0]"
+	}
+	return facingWall("skeleton_wall_skull")(d)
+}
+
+func doublePlant(d int) string {
+	if d&8 != 0 {
+		return "sunflower[half=upper]"
+	}
+	return pick([]string{"sunflower", "lilac", "tall_grass", "large_fern", "rose_bush", "peony"}, d&7) + "[half=lower]"
+}
+
+func mushroomBlock(name string) legacyFn {
+	return func(d int) string {
+		if d == 10 || d == 15 {
+			return "mushroom_stem"
+		}
+		return name
+	}
+}
+
+func cauldron(d int) string {
+	if d&3 == 0 {
+		return "cauldron"
+	}
+	return "water_cauldron[level=" + fmt.Sprint(d&3) + "]"
+}
+
+func portal(d int) string {
+	if d&3 == 2 {
+		return "nether_portal[axis=z]"
+	}
+	return "nether_portal[axis=x]"
+}
+
+var legacyBlocks = map[int]legacyFn{
+	0:  simple("air"),
+	1:  variants("stone", "granite", "polished_granite", "diorite", "polished_diorite", "andesite", "polished_andesite"),
+	2:  simple("grass_block[snowy=false]"),
+	3:  variants("dirt", "coarse_dirt", "podzol"),
+	4:  simple("cobblestone"),
+	5:  func(d int) string { return pick(woods, d) + "_planks" },
+	6:  func(d int) string { return pick(woods, d&7) + "_sapling[stage=" + fmt.Sprint(d>>3&1) + "]" },
+	7:  simple("bedrock"),
+	8:  fluid("water"),
+	9:  fluid("water"),
+	10: fluid("lava"),
+	11: fluid("lava"),
+	12: variants("sand", "red_sand"),
+	13: simple("gravel"),
+	14: simple("gold_ore"),
+	15: simple("iron_ore"),
+	16: simple("coal_ore"),
+	17: logs("oak", "spruce", "birch", "jungle"),
+	18: leaves("oak", "spruce", "birch", "jungle"),
+	19: variants("sponge", "wet_sponge"),
+	20: simple("glass"),
+	21: simple("lapis_ore"),
+	22: simple("lapis_block"),
+	23: func(d int) string { return "dispenser[facing=" + pick(front, d&7) + ",triggered=" + bit(d, 8) + "]" },
+	24: variants("sandstone", "chiseled_sandstone", "cut_sandstone"),
+	25: simple("note_block"),
+	26: bed,
+	27: rail("powered_rail", true),
+	28: rail("detector_rail", true),
+	29: piston("sticky_piston"),
+	30: simple("cobweb"),
+	31: variants("dead_bush", "short_grass", "fern"),
+	32: simple("dead_bush"),
+	33: piston("piston"),
+	34: func(d int) string {
+		typ := "normal"
+		if d&8 != 0 {
+			typ = "sticky"
+		}
+		return "piston_head[facing=" + pick(front, d&7) + ",short=false,type=" + typ + "]"
+	},
+	35: colored("wool"),
+	36: simple("moving_piston"),
+	37: simple("dandelion"),
+	38: variants("poppy", "blue_orchid", "allium", "azure_bluet", "red_tulip", "orange_tulip", "white_tulip", "pink_tulip", "oxeye_daisy"),
+	39: simple("brown_mushroom"),
+	40: simple("red_mushroom"),
+	41: simple("gold_block"),
+	42: simple("iron_block"),
+	43: func(d int) string {
+		switch d {
+		case 8:
+			return "smooth_stone"
+		case 9:
+			return "smooth_sandstone"
+		case 15:
+			return "smooth_quartz"
+		}
+		return slabs(true, "smooth_stone", "sandstone", "petrified_oak", "cobblestone", "brick", "stone_brick", "nether_brick", "quartz")(d)
+	},
+	44:  slabs(false, "smooth_stone", "sandstone", "petrified_oak", "cobblestone", "brick", "stone_brick", "nether_brick", "quartz"),
+	45:  simple("bricks"),
+	46:  simple("tnt"),
+	47:  simple("bookshelf"),
+	48:  simple("mossy_cobblestone"),
+	49:  simple("obsidian"),
+	50:  torch("torch", "wall_torch"),
+	51:  simple("fire"),
+	52:  simple("spawner"),
+	53:  stairs("oak_stairs"),
+	54:  facingWall("chest"),
+	55:  func(d int) string { return "redstone_wire[power=" + fmt.Sprint(d&15) + "]" },
+	56:  simple("diamond_ore"),
+	57:  simple("diamond_block"),
+	58:  simple("crafting_table"),
+	59:  aged("wheat"),
+	60:  func(d int) string { return "farmland[moisture=" + fmt.Sprint(d&7) + "]" },
+	61:  func(d int) string { return withProp(facingWall("furnace")(d), "lit=false") },
+	62:  func(d int) string { return withProp(facingWall("furnace")(d), "lit=true") },
+	63:  func(d int) string { return "oak_sign[rotation=" + fmt.Sprint(d&15) + "]" },
+	64:  door("oak_door"),
+	65:  facingWall("ladder"),
+	66:  rail("rail", false),
+	67:  stairs("cobblestone_stairs"),
+	68:  facingWall("oak_wall_sign"),
+	69:  lever,
+	70:  pressurePlate("stone_pressure_plate"),
+	71:  door("iron_door"),
+	72:  pressurePlate("oak_pressure_plate"),
+	73:  simple("redstone_ore[lit=false]"),
+	74:  simple("redstone_ore[lit=true]"),
+	75:  func(d int) string { return withProp(torch("redstone_torch", "redstone_wall_torch")(d), "lit=false") },
+	76:  func(d int) string { return withProp(torch("redstone_torch", "redstone_wall_torch")(d), "lit=true") },
+	77:  button("stone_button"),
+	78:  func(d int) string { return "snow[layers=" + fmt.Sprint(d&7+1) + "]" },
+	79:  simple("ice"),
+	80:  simple("snow_block"),
+	81:  aged("cactus"),
+	82:  simple("clay"),
+	83:  aged("sugar_cane"),
+	84:  simple("jukebox"),
+	85:  simple("oak_fence"),
+	86:  facingHorizontal("carved_pumpkin"),
+	87:  simple("netherrack"),
+	88:  simple("soul_sand"),
+	89:  simple("glowstone"),
+	90:  portal,
+	91:  facingHorizontal("jack_o_lantern"),
+	92:  func(d int) string { return "cake[bites=" + fmt.Sprint(d&7) + "]" },
+	93:  repeater(false),
+	94:  repeater(true),
+	95:  colored("stained_glass"),
+	96:  trapdoorOf("oak_trapdoor"),
+	97:  variants("infested_stone", "infested_cobblestone", "infested_stone_bricks", "infested_mossy_stone_bricks", "infested_cracked_stone_bricks", "infested_chiseled_stone_bricks"),
+	98:  variants("stone_bricks", "mossy_stone_bricks", "cracked_stone_bricks", "chiseled_stone_bricks"),
+	99:  mushroomBlock("brown_mushroom_block"),
+	100: mushroomBlock("red_mushroom_block"),
+	101: simple("iron_bars"),
+	102: simple("glass_pane"),
+	103: simple("melon"),
+	104: aged("pumpkin_stem"),
+	105: aged("melon_stem"),
+	106: func(d int) string {
+		return "vine[east=" + bit(d, 8) + ",north=" + bit(d, 4) + ",south=" + bit(d, 1) + ",up=false,west=" + bit(d, 2) + "]"
+	},
+	107: fenceGate("oak_fence_gate"),
+	108: stairs("brick_stairs"),
+	109: stairs("stone_brick_stairs"),
+	110: simple("mycelium"),
+	111: simple("lily_pad"),
+	112: simple("nether_bricks"),
+	113: simple("nether_brick_fence"),
+	114: stairs("nether_brick_stairs"),
+	115: aged("nether_wart"),
+	116: simple("enchanting_table"),
+	117: simple("brewing_stand"),
+	118: cauldron,
+	119: simple("end_portal"),
+	120: func(d int) string { return "end_portal_frame[eye=" + bit(d, 4) + ",facing=" + horizontal[d&3] + "]" },
+	121: simple("end_stone"),
+	122: simple("dragon_egg"),
+	123: simple("redstone_lamp[lit=false]"),
+	124: simple("redstone_lamp[lit=true]"),
+	125: slabs(true, woods...),
+	126: slabs(false, woods...),
+	127: func(d int) string { return "cocoa[age=" + fmt.Sprint(d>>2&3) + ",facing=" + horizontal[d&3] + "]" },
+	128: stairs("sandstone_stairs"),
+	129: simple("emerald_ore"),
+	130: facingWall("ender_chest"),
+	131: func(d int) string {
+		return "tripwire_hook[attached=" + bit(d, 4) + ",facing=" + horizontal[d&3] + ",powered=" + bit(d, 8) + "]"
+	},
+	132: simple("tripwire"),
+	133: simple("emerald_block"),
+	134: stairs("spruce_stairs"),
+	135: stairs("birch_stairs"),
+	136: stairs("jungle_stairs"),
+	137: facingFront("command_block"),
+	138: simple("beacon"),
+	139: variants("cobblestone_wall", "mossy_cobblestone_wall"),
+	140: simple("flower_pot"),
+	141: aged("carrots"),
+	142: aged("potatoes"),
+	143: button("oak_button"),
+	144: skull,
+	145: func(d int) string {
+		return pick([]string{"anvil", "chipped_anvil", "damaged_anvil"}, d>>2) + "[facing=" + horizontal[d&3] + "]"
+	},
+	146: facingWall("trapped_chest"),
+	147: weightedPlate("light_weighted_pressure_plate"),
+	148: weightedPlate("heavy_weighted_pressure_plate"),
+	149: comparator(false),
+	150: comparator(true),
+	151: func(d int) string { return "daylight_detector[inverted=false,power=" + fmt.Sprint(d&15) + "]" },
+	152: simple("redstone_block"),
+	153: simple("nether_quartz_ore"),
+	154: func(d int) string {
+		return "hopper[enabled=" + fmt.Sprint(d&8 == 0) + ",facing=" + pick(front, d&7) + "]"
+	},
+	155: variants("quartz_block", "chiseled_quartz_block", "quartz_pillar[axis=y]", "quartz_pillar[axis=x]", "quartz_pillar[axis=z]"),
+	156: stairs("quartz_stairs"),
+	157: rail("activator_rail", true),
+	158: func(d int) string { return "dropper[facing=" + pick(front, d&7) + ",triggered=" + bit(d, 8) + "]" },
+	159: colored("terracotta"),
+	160: colored("stained_glass_pane"),
+	161: leaves("acacia", "dark_oak"),
+	162: logs("acacia", "dark_oak"),
+	163: stairs("acacia_stairs"),
+	164: stairs("dark_oak_stairs"),
+	165: simple("slime_block"),
+	166: simple("barrier"),
+	167: trapdoorOf("iron_trapdoor"),
+	168: variants("prismarine", "prismarine_bricks", "dark_prismarine"),
+	169: simple("sea_lantern"),
+	170: pillar("hay_block"),
+	171: colored("carpet"),
+	172: simple("terracotta"),
+	173: simple("coal_block"),
+	174: simple("packed_ice"),
+	175: doublePlant,
+	176: func(d int) string { return "white_banner[rotation=" + fmt.Sprint(d&15) + "]" },
+	177: facingWall("white_wall_banner"),
+	178: func(d int) string { return "daylight_detector[inverted=true,power=" + fmt.Sprint(d&15) + "]" },
+	179: variants("red_sandstone", "chiseled_red_sandstone", "cut_red_sandstone"),
+	180: stairs("red_sandstone_stairs"),
+	181: slabs(true, "red_sandstone"),
+	182: slabs(false, "red_sandstone"),
+	183: fenceGate("spruce_fence_gate"),
+	184: fenceGate("birch_fence_gate"),
+	185: fenceGate("jungle_fence_gate"),
+	186: fenceGate("dark_oak_fence_gate"),
+	187: fenceGate("acacia_fence_gate"),
+	188: simple("spruce_fence"),
+	189: simple("birch_fence"),
+	190: simple("jungle_fence"),
+	191: simple("dark_oak_fence"),
+	192: simple("acacia_fence"),
+	193: door("spruce_door"),
+	194: door("birch_door"),
+	195: door("jungle_door"),
+	196: door("acacia_door"),
+	197: door("dark_oak_door"),
+	198: facingFront("end_rod"),
+	199: simple("chorus_plant"),
+	200: aged("chorus_flower"),
+	201: simple("purpur_block"),
+	202: pillar("purpur_pillar"),
+	203: stairs("purpur_stairs"),
+	204: slabs(true, "purpur"),
+	205: slabs(false, "purpur"),
+	206: simple("end_stone_bricks"),
+	207: aged("beetroots"),
+	208: simple("dirt_path"),
+	209: simple("end_gateway"),
+	210: facingFront("repeating_command_block"),
+	211: facingFront("chain_command_block"),
+	212: aged("frosted_ice"),
+	213: simple("magma_block"),
+	214: simple("nether_wart_block"),
+	215: simple("red_nether_bricks"),
+	216: pillar("bone_block"),
+	217: simple("structure_void"),
+	218: func(d int) string { return "observer[facing=" + pick(front, d&7) + ",powered=" + bit(d, 8) + "]" },
+	251: colored("concrete"),
+	252: colored("concrete_powder"),
+	255: variants("structure_block[mode=save]", "structure_block[mode=load]", "structure_block[mode=corner]", "structure_block[mode=data]"),
+}
+
+func init() {
+	for i, c := range colors {
+		legacyBlocks[219+i] = facingFront(c + "_shulker_box")
+		legacyBlocks[235+i] = facingHorizontal(c + "_glazed_terracotta")
+	}
+}
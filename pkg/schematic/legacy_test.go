@@ -0,0 +1,139 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func TestLegacyBlockState(t *testing.T) {
+	cases := []struct {
+		id, data int
+		want     string
+	}{
+		{1, 3, "minecraft:diorite"},
+		{17, 9, "minecraft:spruce_log[axis=z]"},
+		{35, 14, "minecraft:red_wool"},
+		{53, 6, "minecraft:oak_stairs[facing=south,half=top,shape=straight]"},
+		{54, 4, "minecraft:chest[facing=west]"},
+		{61, 3, "minecraft:furnace[facing=south,lit=false]"},
+		{76, 1, "minecraft:redstone_wall_torch[facing=east,lit=true]"},
+		{44, 12, "minecraft:brick_slab[type=top]"},
+		{235, 2, "minecraft:white_glazed_terracotta[facing=north]"},
+		{250, 0, "minecraft:black_glazed_terracotta[facing=south]"},
+		{1, 15, "minecraft:stone"},
+	}
+	for _, c := range cases {
+		st, ok := LegacyBlockState(c.id, c.data)
+		if !ok || st.String() != c.want {
+			t.Errorf("%d:%d: got %q (%v), want %q", c.id, c.data, st.String(), ok, c.want)
+		}
+	}
+	if _, ok := LegacyBlockState(253, 0); ok {
+		t.Fatal("253:0 should be unknown")
+	}
+}
+
+func TestReadLegacy(t *testing.T) {
+	// 2x2x1: stone, unknown id 253, chest with a tile entity, a sign.
+	root := map[string]any{
+		"Width":     int16(2),
+		"Height":    int16(2),
+		"Length":    int16(1),
+		"Materials": "Alpha",
+		"Blocks":    []byte{1, 253, 54, 63},
+		"Data":      []byte{0, 0, 5, 4},
+		"TileEntities": []any{
+			map[string]any{"id": "Chest", "x": int32(0), "y": int32(1), "z": int32(0), "Lock": "key"},
+			map[string]any{"id": "Sign", "x": int32(1), "y": int32(1), "z": int32(0),
+				"Text1": `"hi"`, "Text2": "", "Text3": "", "Text4": ""},
+		},
+		"Entities": []any{map[string]any{"id": "Pig"}},
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := nbt.NewEncoder(zw).Encode(root, "Schematic"); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+
+	s, report, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if report == nil || report.Unknown["253:0"] != 1 || report.DroppedEntities != 1 {
+		t.Fatalf("report: got %+v", report)
+	}
+	if got := s.At(0, 0, 0).String(); got != "minecraft:stone" {
+		t.Fatalf("block: got %q", got)
+	}
+	if got := s.At(1, 0, 0).Name; got != "minecraft:air" {
+		t.Fatalf("unknown block: got %q", got)
+	}
+	if got := s.At(0, 1, 0).String(); got != "minecraft:chest[facing=east]" {
+		t.Fatalf("chest: got %q", got)
+	}
+	if len(s.BlockEntities) != 2 {
+		t.Fatalf("block entities: got %+v", s.BlockEntities)
+	}
+	for _, be := range s.BlockEntities {
+		switch be.ID {
+		case "minecraft:chest":
+			if be.Data["Lock"] != "key" {
+				t.Fatalf("chest data: got %v", be.Data)
+			}
+		case "minecraft:sign":
+			front, _ := be.Data["front_text"].(map[string]any)
+			msgs, _ := front["messages"].([]any)
+			if len(msgs) != 4 || msgs[0] != `"hi"` || msgs[1] != `""` {
+				t.Fatalf("sign text: got %v", be.Data)
+			}
+		default:
+			t.Fatalf("unexpected block entity %q", be.ID)
+		}
+	}
+}
+
+func TestReadLegacyDoorsAndBeds(t *testing.T) {
+	// 2x2x1: an open east-facing oak door with its hinge on the right at
+	// x=0, a blue bed foot with its tile entity at x=1 below air.
+	root := map[string]any{
+		"Width":     int16(2),
+		"Height":    int16(2),
+		"Length":    int16(1),
+		"Materials": "Alpha",
+		"Blocks":    []byte{64, 26, 64, 0},
+		"Data":      []byte{0 | 4, 0, 8 | 1, 0},
+		"TileEntities": []any{
+			map[string]any{"id": "Bed", "x": int32(1), "y": int32(0), "z": int32(0), "color": int32(11)},
+		},
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := nbt.NewEncoder(zw).Encode(root, "Schematic"); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+
+	s, _, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := map[int]string{
+		0: "minecraft:oak_door[facing=east,half=lower,hinge=right,open=true,powered=false]",
+		1: "minecraft:oak_door[facing=east,half=upper,hinge=right,open=true,powered=false]",
+	}
+	for y, w := range want {
+		if got := s.At(0, y, 0).String(); got != w {
+			t.Errorf("door y=%d: got %q, want %q", y, got, w)
+		}
+	}
+	if got := s.At(1, 0, 0).String(); got != "minecraft:blue_bed[facing=south,occupied=false,part=foot]" {
+		t.Errorf("bed: got %q", got)
+	}
+	if len(s.BlockEntities) != 1 || s.BlockEntities[0].ID != "minecraft:bed" || s.BlockEntities[0].Data["color"] != nil {
+		t.Errorf("bed block entity: %+v", s.BlockEntities)
+	}
+}
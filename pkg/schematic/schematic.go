@@ -0,0 +1,193 @@
+// Package schematic reads and writes Sponge and legacy MCEdit schematics and
+// vanilla structure templates, and transforms them for pasting.
+package schematic
+
+import (
+	"fmt"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+// BlockEntity is a block entity positioned relative to the schematic origin.
+// Data holds every field except the position and id.
+type BlockEntity struct {
+	X, Y, Z int
+	ID      string
+	Data    map[string]any
+}
+
+// Entity is an entity positioned relative to the schematic origin. Data
+// holds every field except Pos and id.
+type Entity struct {
+	X, Y, Z float64
+	ID      string
+	Data    map[string]any
+}
+
+// Schematic is a format-neutral box of blocks. Blocks are stored as palette
+// indices in x, then z, then y order.
+type Schematic struct {
+	Width, Height, Length int
+	// Offset is the Sponge Offset tag, the absolute position the schematic
+	// was copied from. Pasting ignores it.
+	Offset        [3]int
+	DataVersion   int
+	Palette       []chunkedit.BlockState
+	Blocks        []int
+	BlockEntities []BlockEntity
+	Entities      []Entity
+
+	paletteIndex map[string]int
+}
+
+// New returns an empty schematic of the given size. Every block is palette
+// index 0, so callers set a palette entry before writing it.
+func New(width, height, length int) *Schematic {
+	return &Schematic{
+		Width:  width,
+		Height: height,
+		Length: length,
+		Blocks: make([]int, width*height*length),
+	}
+}
+
+// Index returns the position in Blocks of (x, y, z), relative to the
+// schematic origin.
+func (s *Schematic) Index(x, y, z int) int {
+	return (y*s.Length+z)*s.Width + x
+}
+
+// Contains reports whether (x, y, z), relative to the schematic origin, is
+// inside the schematic.
+func (s *Schematic) Contains(x, y, z int) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < s.Width && y < s.Height && z < s.Length
+}
+
+// PaletteID returns the palette index of st, adding it if needed.
+func (s *Schematic) PaletteID(st chunkedit.BlockState) int {
+	if s.paletteIndex == nil {
+		s.paletteIndex = make(map[string]int, len(s.Palette))
+		for i, p := range s.Palette {
+			s.paletteIndex[p.String()] = i
+		}
+	}
+	key := st.String()
+	if id, ok := s.paletteIndex[key]; ok {
+		return id
+	}
+	s.Palette = append(s.Palette, st)
+	s.paletteIndex[key] = len(s.Palette) - 1
+	return len(s.Palette) - 1
+}
+
+// Set stores st at (x, y, z), relative to the schematic origin, adding it to
+// the palette if needed.
+func (s *Schematic) Set(x, y, z int, st chunkedit.BlockState) {
+	s.Blocks[s.Index(x, y, z)] = s.PaletteID(st)
+}
+
+// At returns the block at (x, y, z), relative to the schematic origin.
+func (s *Schematic) At(x, y, z int) chunkedit.BlockState {
+	return s.Palette[s.Blocks[s.Index(x, y, z)]]
+}
+
+func (s *Schematic) validate() error {
+	if s.Width <= 0 || s.Height <= 0 || s.Length <= 0 {
+		return fmt.Errorf("invalid size %dx%dx%d", s.Width, s.Height, s.Length)
+	}
+	if len(s.Blocks) != s.Width*s.Height*s.Length {
+		return fmt.Errorf("block count %d does not match size %dx%dx%d", len(s.Blocks), s.Width, s.Height, s.Length)
+	}
+	for i, b := range s.Blocks {
+		if b < 0 || b >= len(s.Palette) {
+			return fmt.Errorf("palette index %d out of range at block %d", b, i)
+		}
+	}
+	return nil
+}
+
+func toInt(v any) (int, bool) {
+	switch t := v.(type) {
+	case int8:
+		return int(t), true
+	case int16:
+		return int(t), true
+	case int32:
+		return int(t), true
+	case int64:
+		return int(t), true
+	case int:
+		return t, true
+	case float64:
+		return int(t), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	default:
+		n, ok := toInt(v)
+		return float64(n), ok
+	}
+}
+
+// intTriple reads a position stored as an int array or a list of numbers.
+func intTriple(v any) ([3]int, bool) {
+	var out [3]int
+	switch t := v.(type) {
+	case []int32:
+		if len(t) != 3 {
+			return out, false
+		}
+		for i, n := range t {
+			out[i] = int(n)
+		}
+		return out, true
+	case []any:
+		if len(t) != 3 {
+			return out, false
+		}
+		for i, e := range t {
+			n, ok := toInt(e)
+			if !ok {
+				return out, false
+			}
+			out[i] = n
+		}
+		return out, true
+	}
+	return out, false
+}
+
+func floatTriple(v any) ([3]float64, bool) {
+	var out [3]float64
+	arr, ok := v.([]any)
+	if !ok || len(arr) != 3 {
+		return out, false
+	}
+	for i, e := range arr {
+		f, ok := toFloat(e)
+		if !ok {
+			return out, false
+		}
+		out[i] = f
+	}
+	return out, true
+}
+
+func without(m map[string]any, keys ...string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, k := range keys {
+		delete(out, k)
+	}
+	return out
+}
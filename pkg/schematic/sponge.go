@@ -0,0 +1,265 @@
+package schematic
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Tnze/go-mc/nbt"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+// WriteSponge writes s as a gzip-compressed Sponge schematic of the given
+// version (2 or 3).
+func WriteSponge(w io.Writer, s *Schematic, version int) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if version != 2 && version != 3 {
+		return fmt.Errorf("unsupported sponge schematic version %d", version)
+	}
+	// Sizes are unsigned shorts, stored in short tags.
+	if s.Width > 0xFFFF || s.Height > 0xFFFF || s.Length > 0xFFFF {
+		return fmt.Errorf("size %dx%dx%d exceeds the sponge limit of 65535", s.Width, s.Height, s.Length)
+	}
+
+	palette := make(map[string]any, len(s.Palette))
+	for i, st := range s.Palette {
+		palette[st.String()] = int32(i)
+	}
+	data := encodeVarints(s.Blocks)
+
+	root := map[string]any{
+		"Version":     int32(version),
+		"DataVersion": int32(s.DataVersion),
+		"Width":       int16(s.Width),
+		"Height":      int16(s.Height),
+		"Length":      int16(s.Length),
+		"Offset":      []int32{int32(s.Offset[0]), int32(s.Offset[1]), int32(s.Offset[2])},
+		"Metadata": map[string]any{
+			"WEOffsetX": int32(0),
+			"WEOffsetY": int32(0),
+			"WEOffsetZ": int32(0),
+		},
+	}
+
+	blockEntities := make([]any, 0, len(s.BlockEntities))
+	for _, be := range s.BlockEntities {
+		pos := []int32{int32(be.X), int32(be.Y), int32(be.Z)}
+		if version == 2 {
+			m := without(be.Data, "Pos", "Id")
+			m["Pos"], m["Id"] = pos, be.ID
+			blockEntities = append(blockEntities, m)
+		} else {
+			blockEntities = append(blockEntities, map[string]any{"Pos": pos, "Id": be.ID, "Data": be.Data})
+		}
+	}
+	entities := make([]any, 0, len(s.Entities))
+	for _, e := range s.Entities {
+		pos := []any{e.X, e.Y, e.Z}
+		if version == 2 {
+			m := without(e.Data, "Pos", "Id")
+			m["Pos"], m["Id"] = pos, e.ID
+			entities = append(entities, m)
+		} else {
+			entities = append(entities, map[string]any{"Pos": pos, "Id": e.ID, "Data": e.Data})
+		}
+	}
+
+	var (
+		tagName string
+		doc     any
+	)
+	if version == 2 {
+		root["PaletteMax"] = int32(len(s.Palette))
+		root["Palette"] = palette
+		root["BlockData"] = data
+		root["BlockEntities"] = blockEntities
+		if len(entities) > 0 {
+			root["Entities"] = entities
+		}
+		tagName, doc = "Schematic", root
+	} else {
+		root["Blocks"] = map[string]any{
+			"Palette":       palette,
+			"Data":          data,
+			"BlockEntities": blockEntities,
+		}
+		if len(entities) > 0 {
+			root["Entities"] = entities
+		}
+		tagName, doc = "", map[string]any{"Schematic": root}
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := nbt.NewEncoder(zw).Encode(doc, tagName); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadSponge reads a gzip-compressed Sponge schematic of version 1, 2 or 3.
+func ReadSponge(r io.Reader) (*Schematic, error) {
+	root, err := readRoot(r)
+	if err != nil {
+		return nil, err
+	}
+	return fromSponge(root)
+}
+
+func readRoot(r io.Reader) (map[string]any, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var root map[string]any
+	if _, err := nbt.NewDecoder(zr).Decode(&root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func fromSponge(root map[string]any) (*Schematic, error) {
+	var err error
+	if inner, ok := root["Schematic"].(map[string]any); ok {
+		root = inner
+	}
+	version, _ := toInt(root["Version"])
+	w, _ := toInt(root["Width"])
+	h, _ := toInt(root["Height"])
+	l, _ := toInt(root["Length"])
+	// Sizes are unsigned shorts stored in short tags.
+	s := New(w&0xFFFF, h&0xFFFF, l&0xFFFF)
+	s.DataVersion, _ = toInt(root["DataVersion"])
+	if off, ok := intTriple(root["Offset"]); ok {
+		s.Offset = off
+	}
+
+	blocks := root
+	if version >= 3 {
+		b, ok := root["Blocks"].(map[string]any)
+		if !ok {
+			return nil, errors.New("schematic has no Blocks container")
+		}
+		blocks = b
+	}
+	paletteTag, dataTag := "Palette", "BlockData"
+	if version >= 3 {
+		dataTag = "Data"
+	}
+	palette, ok := blocks[paletteTag].(map[string]any)
+	if !ok {
+		return nil, errors.New("schematic has no block palette")
+	}
+	s.Palette = make([]chunkedit.BlockState, len(palette))
+	seen := make([]bool, len(palette))
+	for key, v := range palette {
+		id, ok := toInt(v)
+		if !ok || id < 0 || id >= len(palette) || seen[id] {
+			return nil, fmt.Errorf("invalid palette id for %q", key)
+		}
+		st, err := chunkedit.ParseBlockState(key)
+		if err != nil {
+			return nil, err
+		}
+		s.Palette[id], seen[id] = st, true
+	}
+	raw, _ := blocks[dataTag].([]byte)
+	if s.Blocks, err = decodeVarints(raw, len(s.Blocks)); err != nil {
+		return nil, err
+	}
+
+	beKey := "BlockEntities"
+	if _, ok := blocks[beKey]; !ok {
+		beKey = "TileEntities"
+	}
+	beList, _ := blocks[beKey].([]any)
+	for _, v := range beList {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		pos, ok := intTriple(m["Pos"])
+		if !ok {
+			return nil, errors.New("block entity without Pos")
+		}
+		be := BlockEntity{X: pos[0], Y: pos[1], Z: pos[2]}
+		be.ID, _ = m["Id"].(string)
+		if data, ok := m["Data"].(map[string]any); ok && version >= 3 {
+			be.Data = without(data, "x", "y", "z", "id")
+		} else {
+			be.Data = without(m, "Pos", "Id", "ContentVersion")
+		}
+		s.BlockEntities = append(s.BlockEntities, be)
+	}
+
+	entList, _ := root["Entities"].([]any)
+	for _, v := range entList {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		pos, ok := floatTriple(m["Pos"])
+		if !ok {
+			return nil, errors.New("entity without Pos")
+		}
+		e := Entity{X: pos[0], Y: pos[1], Z: pos[2]}
+		e.ID, _ = m["Id"].(string)
+		if data, ok := m["Data"].(map[string]any); ok && version >= 3 {
+			e.Data = without(data, "Pos", "id")
+		} else {
+			e.Data = without(m, "Pos", "Id")
+		}
+		s.Entities = append(s.Entities, e)
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func encodeVarints(vals []int) []byte {
+	out := make([]byte, 0, len(vals))
+	for _, v := range vals {
+		u := uint32(v)
+		for u >= 0x80 {
+			out = append(out, byte(u)|0x80)
+			u >>= 7
+		}
+		out = append(out, byte(u))
+	}
+	return out
+}
+
+func decodeVarints(data []byte, n int) ([]int, error) {
+	out := make([]int, 0, n)
+	var (
+		v     uint32
+		shift uint
+	)
+	for _, b := range data {
+		v |= uint32(b&0x7F) << shift
+		if b&0x80 != 0 {
+			shift += 7
+			if shift > 28 {
+				return nil, errors.New("varint too long in block data")
+			}
+			continue
+		}
+		out = append(out, int(v))
+		v, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, errors.New("truncated varint in block data")
+	}
+	if len(out) != n {
+		return nil, fmt.Errorf("block data has %d entries, want %d", len(out), n)
+	}
+	return out, nil
+}
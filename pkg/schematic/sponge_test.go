@@ -0,0 +1,90 @@
+package schematic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+func testSchematic() *Schematic {
+	s := New(2, 3, 200)
+	s.DataVersion = 3465
+	s.Offset = [3]int{10, -5, 7}
+	s.PaletteID(chunkedit.BlockState{Name: "minecraft:air"})
+	s.Set(1, 2, 199, chunkedit.BlockState{Name: "minecraft:chest", Properties: map[string]string{"facing": "west"}})
+	for i := range 150 {
+		s.Set(0, 0, i, chunkedit.BlockState{Name: "minecraft:stone"})
+	}
+	s.BlockEntities = []BlockEntity{{X: 1, Y: 2, Z: 199, ID: "minecraft:chest", Data: map[string]any{"Lock": "key"}}}
+	s.Entities = []Entity{{X: 0.5, Y: 1, Z: 3.25, ID: "minecraft:pig", Data: map[string]any{"Health": float32(10)}}}
+	return s
+}
+
+func TestSpongeRoundTrip(t *testing.T) {
+	for _, version := range []int{2, 3} {
+		var buf bytes.Buffer
+		if err := WriteSponge(&buf, testSchematic(), version); err != nil {
+			t.Fatalf("v%d WriteSponge: %v", version, err)
+		}
+		s, err := ReadSponge(&buf)
+		if err != nil {
+			t.Fatalf("v%d ReadSponge: %v", version, err)
+		}
+		if s.Width != 2 || s.Height != 3 || s.Length != 200 || s.DataVersion != 3465 {
+			t.Fatalf("v%d header: got %dx%dx%d dv=%d", version, s.Width, s.Height, s.Length, s.DataVersion)
+		}
+		if s.Offset != [3]int{10, -5, 7} {
+			t.Fatalf("v%d offset: got %v", version, s.Offset)
+		}
+		if got := s.At(1, 2, 199).String(); got != "minecraft:chest[facing=west]" {
+			t.Fatalf("v%d block: got %q", version, got)
+		}
+		if got := s.At(0, 0, 149).Name; got != "minecraft:stone" {
+			t.Fatalf("v%d block: got %q", version, got)
+		}
+		if len(s.BlockEntities) != 1 || s.BlockEntities[0].Z != 199 || s.BlockEntities[0].Data["Lock"] != "key" {
+			t.Fatalf("v%d block entities: got %+v", version, s.BlockEntities)
+		}
+		if len(s.Entities) != 1 || s.Entities[0].Z != 3.25 || s.Entities[0].ID != "minecraft:pig" {
+			t.Fatalf("v%d entities: got %+v", version, s.Entities)
+		}
+	}
+}
+
+func TestSpongeSizeLimit(t *testing.T) {
+	s := New(65536, 1, 1)
+	s.PaletteID(chunkedit.BlockState{Name: "minecraft:air"})
+	if err := WriteSponge(&bytes.Buffer{}, s, 3); err == nil {
+		t.Fatal("width 65536 was written")
+	}
+
+	// Widths above 32767 wrap to negative shorts on disk.
+	s = New(40000, 1, 1)
+	s.PaletteID(chunkedit.BlockState{Name: "minecraft:air"})
+	s.Set(39999, 0, 0, chunkedit.BlockState{Name: "minecraft:stone"})
+	var buf bytes.Buffer
+	if err := WriteSponge(&buf, s, 2); err != nil {
+		t.Fatalf("WriteSponge: %v", err)
+	}
+	got, err := ReadSponge(&buf)
+	if err != nil || got.Width != 40000 || got.At(39999, 0, 0).Name != "minecraft:stone" {
+		t.Fatalf("ReadSponge: %v", err)
+	}
+}
+
+func TestVarints(t *testing.T) {
+	vals := []int{0, 1, 127, 128, 300, 70000}
+	got, err := decodeVarints(encodeVarints(vals), len(vals))
+	if err != nil {
+		t.Fatalf("decodeVarints: %v", err)
+	}
+	for i := range vals {
+		if got[i] != vals[i] {
+			t.Fatalf("value %d: got %d, want %d", i, got[i], vals[i])
+		}
+	}
+	if _, err := decodeVarints([]byte{0x80}, 1); err == nil {
+		t.Fatalf("expected error for truncated varint")
+	}
+}
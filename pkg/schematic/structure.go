@@ -0,0 +1,198 @@
+package schematic
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Tnze/go-mc/nbt"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+// StructureVoid marks positions a structure template leaves untouched. It
+// is never written to a template and never placed.
+var StructureVoid = chunkedit.BlockState{Name: "minecraft:structure_void"}
+
+type structureFile struct {
+	DataVersion int32             `nbt:"DataVersion"`
+	Size        []int32           `nbt:"size,list"`
+	Palette     []map[string]any  `nbt:"palette"`
+	Blocks      []structureBlock  `nbt:"blocks"`
+	Entities    []structureEntity `nbt:"entities"`
+}
+
+type structureBlock struct {
+	State int32          `nbt:"state"`
+	Pos   []int32        `nbt:"pos,list"`
+	NBT   map[string]any `nbt:"nbt,omitempty"`
+}
+
+type structureEntity struct {
+	Pos      []float64      `nbt:"pos"`
+	BlockPos []int32        `nbt:"blockPos,list"`
+	NBT      map[string]any `nbt:"nbt"`
+}
+
+// WriteStructure writes s as a gzip-compressed vanilla structure template.
+// Structure void blocks are omitted.
+func WriteStructure(w io.Writer, s *Schematic) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	sf := structureFile{
+		DataVersion: int32(s.DataVersion),
+		Size:        []int32{int32(s.Width), int32(s.Height), int32(s.Length)},
+		Blocks:      []structureBlock{},
+		Entities:    []structureEntity{},
+	}
+	for _, st := range s.Palette {
+		m := map[string]any{"Name": st.Name}
+		if len(st.Properties) > 0 {
+			props := make(map[string]any, len(st.Properties))
+			for k, v := range st.Properties {
+				props[k] = v
+			}
+			m["Properties"] = props
+		}
+		sf.Palette = append(sf.Palette, m)
+	}
+
+	blockNBT := make(map[[3]int]map[string]any, len(s.BlockEntities))
+	for _, be := range s.BlockEntities {
+		m := without(be.Data, "x", "y", "z")
+		m["id"] = be.ID
+		blockNBT[[3]int{be.X, be.Y, be.Z}] = m
+	}
+	void := -1
+	for i, st := range s.Palette {
+		if st.String() == StructureVoid.String() {
+			void = i
+		}
+	}
+	for y := range s.Height {
+		for z := range s.Length {
+			for x := range s.Width {
+				state := s.Blocks[s.Index(x, y, z)]
+				if state == void {
+					continue
+				}
+				sf.Blocks = append(sf.Blocks, structureBlock{
+					State: int32(state),
+					Pos:   []int32{int32(x), int32(y), int32(z)},
+					NBT:   blockNBT[[3]int{x, y, z}],
+				})
+			}
+		}
+	}
+
+	for _, e := range s.Entities {
+		m := without(e.Data, "UUID", "Pos")
+		m["id"] = e.ID
+		sf.Entities = append(sf.Entities, structureEntity{
+			Pos:      []float64{e.X, e.Y, e.Z},
+			BlockPos: []int32{int32(e.X), int32(e.Y), int32(e.Z)},
+			NBT:      m,
+		})
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := nbt.NewEncoder(zw).Encode(sf, ""); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadStructure reads a gzip-compressed vanilla structure template. For
+// templates with several palettes, palette selects which one to use.
+// Positions without a block become StructureVoid.
+func ReadStructure(r io.Reader, palette int) (*Schematic, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var root map[string]any
+	if _, err := nbt.NewDecoder(zr).Decode(&root); err != nil {
+		return nil, err
+	}
+	size, ok := intTriple(root["size"])
+	if !ok {
+		return nil, errors.New("structure has no size")
+	}
+	s := New(size[0], size[1], size[2])
+	s.DataVersion, _ = toInt(root["DataVersion"])
+
+	entries, _ := root["palette"].([]any)
+	if palettes, ok := root["palettes"].([]any); ok {
+		if palette < 0 || palette >= len(palettes) {
+			return nil, fmt.Errorf("palette %d out of range (structure has %d)", palette, len(palettes))
+		}
+		entries, _ = palettes[palette].([]any)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("structure has no palette")
+	}
+	for _, v := range entries {
+		m, _ := v.(map[string]any)
+		st := chunkedit.BlockState{}
+		st.Name, _ = m["Name"].(string)
+		if props, ok := m["Properties"].(map[string]any); ok && len(props) > 0 {
+			st.Properties = make(map[string]string, len(props))
+			for k, pv := range props {
+				st.Properties[k] = fmt.Sprint(pv)
+			}
+		}
+		s.Palette = append(s.Palette, st)
+	}
+	void := s.PaletteID(StructureVoid)
+	for i := range s.Blocks {
+		s.Blocks[i] = void
+	}
+
+	blocks, _ := root["blocks"].([]any)
+	for _, v := range blocks {
+		m, _ := v.(map[string]any)
+		pos, ok := intTriple(m["pos"])
+		if !ok || !s.Contains(pos[0], pos[1], pos[2]) {
+			return nil, fmt.Errorf("block with invalid pos %v", m["pos"])
+		}
+		state, ok := toInt(m["state"])
+		if !ok || state < 0 || state >= len(entries) {
+			return nil, fmt.Errorf("block with invalid state %v", m["state"])
+		}
+		s.Blocks[s.Index(pos[0], pos[1], pos[2])] = state
+		if data, ok := m["nbt"].(map[string]any); ok {
+			id, _ := data["id"].(string)
+			s.BlockEntities = append(s.BlockEntities, BlockEntity{
+				X: pos[0], Y: pos[1], Z: pos[2],
+				ID:   id,
+				Data: without(data, "id", "x", "y", "z"),
+			})
+		}
+	}
+
+	ents, _ := root["entities"].([]any)
+	for _, v := range ents {
+		m, _ := v.(map[string]any)
+		pos, ok := floatTriple(m["pos"])
+		if !ok {
+			return nil, errors.New("entity without pos")
+		}
+		data, _ := m["nbt"].(map[string]any)
+		id, _ := data["id"].(string)
+		s.Entities = append(s.Entities, Entity{
+			X: pos[0], Y: pos[1], Z: pos[2],
+			ID:   id,
+			Data: without(data, "id", "Pos", "UUID"),
+		})
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,87 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func TestStructureRoundTrip(t *testing.T) {
+	src := testSchematic()
+	src.Set(0, 1, 0, StructureVoid)
+
+	var buf bytes.Buffer
+	if err := WriteStructure(&buf, src); err != nil {
+		t.Fatalf("WriteStructure: %v", err)
+	}
+	raw := buf.Bytes()
+
+	s, err := ReadStructure(bytes.NewReader(raw), 0)
+	if err != nil {
+		t.Fatalf("ReadStructure: %v", err)
+	}
+	if s.Width != 2 || s.Height != 3 || s.Length != 200 {
+		t.Fatalf("size: got %dx%dx%d", s.Width, s.Height, s.Length)
+	}
+	if got := s.At(1, 2, 199).String(); got != "minecraft:chest[facing=west]" {
+		t.Fatalf("block: got %q", got)
+	}
+	if got := s.At(0, 1, 0); got.Name != StructureVoid.Name {
+		t.Fatalf("void position: got %v", got)
+	}
+	if len(s.BlockEntities) != 1 || s.BlockEntities[0].ID != "minecraft:chest" || s.BlockEntities[0].Data["Lock"] != "key" {
+		t.Fatalf("block entities: got %+v", s.BlockEntities)
+	}
+	if len(s.Entities) != 1 || s.Entities[0].ID != "minecraft:pig" || s.Entities[0].X != 0.5 {
+		t.Fatalf("entities: got %+v", s.Entities)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var root map[string]any
+	if _, err := nbt.NewDecoder(zr).Decode(&root); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := root["size"].([]any); !ok {
+		t.Fatalf("size must be an NBT list of ints, got %T", root["size"])
+	}
+	if n := len(root["blocks"].([]any)); n != src.Width*src.Height*src.Length-1 {
+		t.Fatalf("blocks: got %d entries, want every non-void position", n)
+	}
+}
+
+func TestStructurePalettes(t *testing.T) {
+	doc := map[string]any{
+		"DataVersion": int32(3465),
+		"size":        []any{int32(1), int32(1), int32(1)},
+		"palettes": []any{
+			[]any{map[string]any{"Name": "minecraft:oak_planks"}},
+			[]any{map[string]any{"Name": "minecraft:spruce_planks"}},
+		},
+		"blocks": []any{
+			map[string]any{"state": int32(0), "pos": []any{int32(0), int32(0), int32(0)}},
+		},
+		"entities": []any{},
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := nbt.NewEncoder(zw).Encode(doc, ""); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	zw.Close()
+
+	s, err := ReadStructure(bytes.NewReader(buf.Bytes()), 1)
+	if err != nil {
+		t.Fatalf("ReadStructure: %v", err)
+	}
+	if got := s.At(0, 0, 0); got.Name != "minecraft:spruce_planks" {
+		t.Fatalf("palette 1 block: got %v", got)
+	}
+	if _, err := ReadStructure(bytes.NewReader(buf.Bytes()), 2); err == nil {
+		t.Fatalf("expected error for missing palette")
+	}
+}
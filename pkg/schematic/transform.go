@@ -0,0 +1,290 @@
+package schematic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+// Transform mirrors and then rotates a schematic clockwise around the Y
+// axis. MirrorX flips east and west, MirrorZ flips north and south.
+type Transform struct {
+	Rotate  int
+	MirrorX bool
+	MirrorZ bool
+}
+
+// ParseTransform builds a transform from a rotation in degrees, a multiple
+// of 90, and a mirror of "", "none", "x", "z" or "xz".
+func ParseTransform(rotate int, mirror string) (Transform, error) {
+	t := Transform{Rotate: ((rotate % 360) + 360) % 360}
+	if t.Rotate%90 != 0 {
+		return t, fmt.Errorf("rotation must be a multiple of 90, got %d", rotate)
+	}
+	switch mirror {
+	case "", "none":
+	case "x":
+		t.MirrorX = true
+	case "z":
+		t.MirrorZ = true
+	case "xz":
+		t.MirrorX, t.MirrorZ = true, true
+	default:
+		return t, fmt.Errorf("unknown mirror %q (want x, z or xz)", mirror)
+	}
+	return t, nil
+}
+
+// Size returns the footprint of a w x l schematic after the transform.
+func (t Transform) Size(w, l int) (int, int) {
+	if t.Rotate == 90 || t.Rotate == 270 {
+		return l, w
+	}
+	return w, l
+}
+
+// Pos maps a block position inside a w x l schematic to its position inside
+// the transformed footprint.
+func (t Transform) Pos(x, z, w, l int) (int, int) {
+	if t.MirrorX {
+		x = w - 1 - x
+	}
+	if t.MirrorZ {
+		z = l - 1 - z
+	}
+	switch t.Rotate {
+	case 90:
+		return l - 1 - z, x
+	case 180:
+		return w - 1 - x, l - 1 - z
+	case 270:
+		return z, w - 1 - x
+	}
+	return x, z
+}
+
+// Source is the inverse of Pos.
+func (t Transform) Source(x, z, w, l int) (int, int) {
+	switch t.Rotate {
+	case 90:
+		x, z = z, l-1-x
+	case 180:
+		x, z = w-1-x, l-1-z
+	case 270:
+		x, z = w-1-z, x
+	}
+	if t.MirrorX {
+		x = w - 1 - x
+	}
+	if t.MirrorZ {
+		z = l - 1 - z
+	}
+	return x, z
+}
+
+// PosF maps an entity position inside a w x l schematic like Pos, treating
+// the schematic as continuous space.
+func (t Transform) PosF(x, z float64, w, l int) (float64, float64) {
+	fw, fl := float64(w), float64(l)
+	if t.MirrorX {
+		x = fw - x
+	}
+	if t.MirrorZ {
+		z = fl - z
+	}
+	switch t.Rotate {
+	case 90:
+		return fl - z, x
+	case 180:
+		return fw - x, fl - z
+	case 270:
+		return z, fw - x
+	}
+	return x, z
+}
+
+// Yaw transforms an entity yaw in degrees, where 0 faces south and values
+// increase clockwise. The result is in [0, 360).
+func (t Transform) Yaw(yaw float32) float32 {
+	if t.MirrorX {
+		yaw = -yaw
+	}
+	if t.MirrorZ {
+		yaw = 180 - yaw
+	}
+	y := math.Mod(float64(yaw)+float64(t.Rotate), 360)
+	if y < 0 {
+		y += 360
+	}
+	return float32(y)
+}
+
+var (
+	// facings3D are the directions of the item frame Facing byte and
+	// facings2D those of the painting facing byte.
+	facings3D = []string{"down", "up", "north", "south", "west", "east"}
+	facings2D = []string{"south", "west", "north", "east"}
+)
+
+func (t Transform) facing(v int8, names []string) int8 {
+	if v < 0 || int(v) >= len(names) {
+		return v
+	}
+	d := t.dir(names[v])
+	for i, n := range names {
+		if n == d {
+			return int8(i)
+		}
+	}
+	return v
+}
+
+// Entity transforms an entity of a w x l schematic: its position like PosF,
+// its yaw, and the facing of paintings and item frames. Data is copied.
+func (t Transform) Entity(e Entity, w, l int) Entity {
+	e.X, e.Z = t.PosF(e.X, e.Z, w, l)
+	e.Data = without(e.Data)
+	if rot, ok := e.Data["Rotation"].([]any); ok && len(rot) == 2 {
+		if yaw, ok := rot[0].(float32); ok {
+			e.Data["Rotation"] = []any{t.Yaw(yaw), rot[1]}
+		}
+	}
+	if e.ID == "minecraft:painting" {
+		for _, k := range []string{"facing", "Facing"} {
+			if f, ok := e.Data[k].(int8); ok {
+				e.Data[k] = t.facing(f, facings2D)
+			}
+		}
+	} else if f, ok := e.Data["Facing"].(int8); ok {
+		e.Data["Facing"] = t.facing(f, facings3D)
+	}
+	return e
+}
+
+var clockwise = map[string]string{"north": "east", "east": "south", "south": "west", "west": "north"}
+
+func (t Transform) dir(d string) string {
+	if t.MirrorX {
+		switch d {
+		case "east":
+			d = "west"
+		case "west":
+			d = "east"
+		}
+	}
+	if t.MirrorZ {
+		switch d {
+		case "north":
+			d = "south"
+		case "south":
+			d = "north"
+		}
+	}
+	for range t.Rotate / 90 {
+		if next, ok := clockwise[d]; ok {
+			d = next
+		}
+	}
+	return d
+}
+
+func (t Transform) mirrored() bool {
+	return t.MirrorX != t.MirrorZ
+}
+
+// rotation16 transforms the 16-step rotation used by signs, banners and
+// skulls, where 0 faces south and values increase clockwise.
+func (t Transform) rotation16(r int) int {
+	if t.MirrorX {
+		r = (16 - r) % 16
+	}
+	if t.MirrorZ {
+		r = (24 - r) % 16
+	}
+	return (r + t.Rotate/90*4) % 16
+}
+
+func (t Transform) railShape(shape string) string {
+	if rest, ok := strings.CutPrefix(shape, "ascending_"); ok {
+		return "ascending_" + t.dir(rest)
+	}
+	a, b, ok := strings.Cut(shape, "_")
+	if !ok {
+		return shape
+	}
+	a, b = t.dir(a), t.dir(b)
+	if a == "east" || a == "west" {
+		if b == "north" || b == "south" {
+			a, b = b, a
+		}
+	}
+	if a == "south" && b == "north" {
+		a, b = b, a
+	}
+	if a == "west" && b == "east" {
+		a, b = b, a
+	}
+	return a + "_" + b
+}
+
+func swapLeftRight(v string) string {
+	switch {
+	case strings.Contains(v, "left"):
+		return strings.Replace(v, "left", "right", 1)
+	case strings.Contains(v, "right"):
+		return strings.Replace(v, "right", "left", 1)
+	}
+	return v
+}
+
+// State transforms the directional properties of a block state.
+func (t Transform) State(st chunkedit.BlockState) chunkedit.BlockState {
+	if len(st.Properties) == 0 || (t.Rotate == 0 && !t.MirrorX && !t.MirrorZ) {
+		return st
+	}
+	props := make(map[string]string, len(st.Properties))
+	for k, v := range st.Properties {
+		switch k {
+		case "facing", "horizontal_facing":
+			v = t.dir(v)
+		case "axis":
+			if t.Rotate == 90 || t.Rotate == 270 {
+				switch v {
+				case "x":
+					v = "z"
+				case "z":
+					v = "x"
+				}
+			}
+		case "rotation":
+			if r, err := strconv.Atoi(v); err == nil {
+				v = strconv.Itoa(t.rotation16(r))
+			}
+		case "orientation":
+			parts := strings.Split(v, "_")
+			for i := range parts {
+				parts[i] = t.dir(parts[i])
+			}
+			v = strings.Join(parts, "_")
+		case "shape":
+			if strings.Contains(v, "left") || strings.Contains(v, "right") {
+				if t.mirrored() {
+					v = swapLeftRight(v)
+				}
+			} else {
+				v = t.railShape(v)
+			}
+		case "hinge", "type":
+			if t.mirrored() && (v == "left" || v == "right") {
+				v = swapLeftRight(v)
+			}
+		case "north", "east", "south", "west":
+			k = t.dir(k)
+		}
+		props[k] = v
+	}
+	return chunkedit.BlockState{Name: st.Name, Properties: props}
+}
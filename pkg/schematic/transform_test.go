@@ -0,0 +1,108 @@
+package schematic
+
+import (
+	"testing"
+
+	"github.com/Zeptile/nbt-cli/pkg/chunkedit"
+)
+
+func TestTransformPosRoundTrip(t *testing.T) {
+	const w, l = 3, 5
+	for _, rot := range []int{0, 90, 180, 270} {
+		for _, mirror := range []string{"", "x", "z", "xz"} {
+			tr, err := ParseTransform(rot, mirror)
+			if err != nil {
+				t.Fatalf("ParseTransform(%d, %q): %v", rot, mirror, err)
+			}
+			tw, tl := tr.Size(w, l)
+			for x := range w {
+				for z := range l {
+					px, pz := tr.Pos(x, z, w, l)
+					if px < 0 || pz < 0 || px >= tw || pz >= tl {
+						t.Fatalf("rot=%d mirror=%q: (%d,%d) -> (%d,%d) outside %dx%d", rot, mirror, x, z, px, pz, tw, tl)
+					}
+					if sx, sz := tr.Source(px, pz, w, l); sx != x || sz != z {
+						t.Fatalf("rot=%d mirror=%q: Source(Pos(%d,%d)) = (%d,%d)", rot, mirror, x, z, sx, sz)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTransformRotate90(t *testing.T) {
+	tr, _ := ParseTransform(90, "")
+	if x, z := tr.Pos(0, 0, 3, 5); x != 4 || z != 0 {
+		t.Fatalf("north-west corner: got (%d,%d), want (4,0)", x, z)
+	}
+
+	cases := map[string]string{
+		"minecraft:oak_stairs[facing=north,shape=inner_left]": "minecraft:oak_stairs[facing=east,shape=inner_left]",
+		"minecraft:oak_log[axis=x]":                           "minecraft:oak_log[axis=z]",
+		"minecraft:oak_sign[rotation=0]":                      "minecraft:oak_sign[rotation=4]",
+		"minecraft:rail[shape=north_east]":                    "minecraft:rail[shape=south_east]",
+		"minecraft:rail[shape=ascending_west]":                "minecraft:rail[shape=ascending_north]",
+		"minecraft:oak_fence[east=true,north=false]":          "minecraft:oak_fence[east=false,south=true]",
+	}
+	for in, want := range cases {
+		st, err := chunkedit.ParseBlockState(in)
+		if err != nil {
+			t.Fatalf("ParseBlockState(%q): %v", in, err)
+		}
+		if got := tr.State(st).String(); got != want {
+			t.Fatalf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestTransformMirror(t *testing.T) {
+	tr, _ := ParseTransform(0, "x")
+	cases := map[string]string{
+		"minecraft:oak_stairs[facing=east,shape=outer_left]": "minecraft:oak_stairs[facing=west,shape=outer_right]",
+		"minecraft:oak_door[facing=north,hinge=left]":        "minecraft:oak_door[facing=north,hinge=right]",
+		"minecraft:oak_sign[rotation=12]":                    "minecraft:oak_sign[rotation=4]",
+		"minecraft:chest[facing=south,type=left]":            "minecraft:chest[facing=south,type=right]",
+	}
+	for in, want := range cases {
+		st, _ := chunkedit.ParseBlockState(in)
+		if got := tr.State(st).String(); got != want {
+			t.Fatalf("%s: got %s, want %s", in, got, want)
+		}
+	}
+	if _, err := ParseTransform(45, ""); err == nil {
+		t.Fatalf("expected error for 45 degree rotation")
+	}
+}
+
+func TestTransformEntity(t *testing.T) {
+	tr, _ := ParseTransform(90, "")
+	frame := Entity{X: 0.5, Y: 1, Z: 0.03125, ID: "minecraft:item_frame", Data: map[string]any{
+		"Facing":   int8(3),
+		"Rotation": []any{float32(0), float32(0)},
+	}}
+	got := tr.Entity(frame, 3, 5)
+	if got.X != 4.96875 || got.Z != 0.5 {
+		t.Fatalf("position: got (%v,%v), want (4.96875,0.5)", got.X, got.Z)
+	}
+	if got.Data["Facing"] != int8(4) {
+		t.Fatalf("item frame facing south: got %v, want 4 (west)", got.Data["Facing"])
+	}
+	if rot := got.Data["Rotation"].([]any); rot[0] != float32(90) {
+		t.Fatalf("yaw: got %v, want 90", rot[0])
+	}
+	if frame.Data["Facing"] != int8(3) {
+		t.Fatal("Entity changed the source data")
+	}
+
+	painting := Entity{ID: "minecraft:painting", Data: map[string]any{"facing": int8(2)}}
+	if got := tr.Entity(painting, 3, 5); got.Data["facing"] != int8(3) {
+		t.Fatalf("painting facing north: got %v, want 3 (east)", got.Data["facing"])
+	}
+
+	mx, _ := ParseTransform(0, "x")
+	for yaw, want := range map[float32]float32{90: 270, 0: 0, -45: 45} {
+		if got := mx.Yaw(yaw); got != want {
+			t.Fatalf("mirror x yaw %v: got %v, want %v", yaw, got, want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package world
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// DimensionType holds the vertical extent of a dimension, as read from a
+// dimension_type JSON file.
+type DimensionType struct {
+	// MinY is the lowest absolute Y and Height the number of blocks above it,
+	// MinY included.
+	MinY   int `json:"min_y"`
+	Height int `json:"height"`
+}
+
+// MaxY returns the highest Y blocks can be placed at.
+func (t DimensionType) MaxY() int {
+	return t.MinY + t.Height - 1
+}
+
+// Contains reports whether absolute y is within the build height.
+func (t DimensionType) Contains(y int) bool {
+	return y >= t.MinY && y <= t.MaxY()
+}
+
+var vanillaTypes = map[string]DimensionType{
+	"minecraft:overworld":       {MinY: -64, Height: 384},
+	"minecraft:overworld_caves": {MinY: -64, Height: 384},
+	"minecraft:the_nether":      {MinY: 0, Height: 256},
+	"minecraft:the_end":         {MinY: 0, Height: 256},
+}
+
+// LoadDimensionType returns the dimension type of a dimension. Definitions
+// in the world's datapacks (folders or zips under datapacks/) take
+// precedence over the vanilla defaults, so datapacks that change the
+// overworld height are honoured. When several packs define the same file,
+// the one enabled last in level.dat wins, like in the game.
+func LoadDimensionType(worldDir, dimension string) (DimensionType, error) {
+	id, err := NormalizeDimension(dimension)
+	if err != nil {
+		return DimensionType{}, err
+	}
+	packs, err := datapacks(worldDir)
+	if err != nil {
+		return DimensionType{}, err
+	}
+
+	typeID := id
+	if raw, ok, err := packs.read(resourcePath(id, "dimension")); err != nil {
+		return DimensionType{}, err
+	} else if ok {
+		var dim struct {
+			Type json.RawMessage `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &dim); err != nil {
+			return DimensionType{}, fmt.Errorf("dimension %s: %w", id, err)
+		}
+		if err := json.Unmarshal(dim.Type, &typeID); err != nil {
+			return parseType(id, dim.Type)
+		}
+		if typeID, err = NormalizeDimension(typeID); err != nil {
+			return DimensionType{}, err
+		}
+	}
+
+	if raw, ok, err := packs.read(resourcePath(typeID, "dimension_type")); err != nil {
+		return DimensionType{}, err
+	} else if ok {
+		return parseType(typeID, raw)
+	}
+	if t, ok := vanillaTypes[typeID]; ok {
+		return t, nil
+	}
+	return DimensionType{}, fmt.Errorf("unknown dimension type %s for %s", typeID, id)
+}
+
+func parseType(id string, raw []byte) (DimensionType, error) {
+	var t DimensionType
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return t, fmt.Errorf("dimension type %s: %w", id, err)
+	}
+	if t.Height <= 0 || t.Height%16 != 0 || t.MinY%16 != 0 {
+		return t, fmt.Errorf("dimension type %s: invalid min_y %d / height %d", id, t.MinY, t.Height)
+	}
+	return t, nil
+}
+
+func resourcePath(id, kind string) string {
+	ns, path, _ := strings.Cut(id, ":")
+	return "data/" + ns + "/" + kind + "/" + path + ".json"
+}
+
+// datapackList holds datapack paths in load order, lowest priority first.
+type datapackList []string
+
+// datapacks lists the datapacks of the world in worldDir in the order of
+// DataPacks.Enabled in level.dat, followed by the packs level.dat does not
+// mention, by name, as the game enables new packs on load. Packs listed in
+// DataPacks.Disabled are left out.
+func datapacks(worldDir string) (datapackList, error) {
+	dir := filepath.Join(worldDir, "datapacks")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+	paths := map[string]string{}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".zip") {
+			id := "file/" + e.Name()
+			paths[id] = filepath.Join(dir, e.Name())
+			names = append(names, id)
+		}
+	}
+	sort.Strings(names)
+
+	enabled, disabled, err := levelDataPacks(filepath.Join(worldDir, "level.dat"))
+	if err != nil {
+		return nil, err
+	}
+	var out datapackList
+	seen := map[string]bool{}
+	for _, id := range enabled {
+		if p, ok := paths[id]; ok && !seen[id] {
+			out = append(out, p)
+			seen[id] = true
+		}
+	}
+	for _, id := range disabled {
+		seen[id] = true
+	}
+	for _, id := range names {
+		if !seen[id] {
+			out = append(out, paths[id])
+		}
+	}
+	return out, nil
+}
+
+// levelDataPacks returns the enabled and disabled pack ids, such as
+// "vanilla" or "file/tall.zip", from a level.dat. A missing file lists
+// none.
+func levelDataPacks(path string) (enabled, disabled []string, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var level struct {
+		Data struct {
+			DataPacks struct {
+				Enabled  []string
+				Disabled []string
+			}
+		}
+	}
+	if _, err := nbt.NewDecoder(zr).Decode(&level); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return level.Data.DataPacks.Enabled, level.Data.DataPacks.Disabled, nil
+}
+
+// read returns the contents of rel from the last datapack containing it,
+// which overrides the packs loaded before it.
+func (packs datapackList) read(rel string) ([]byte, bool, error) {
+	for i := len(packs) - 1; i >= 0; i-- {
+		p := packs[i]
+		if !strings.HasSuffix(p, ".zip") {
+			b, err := os.ReadFile(filepath.Join(p, filepath.FromSlash(rel)))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return b, err == nil, err
+		}
+		b, ok, err := readZip(p, rel)
+		if err != nil || ok {
+			return b, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+func readZip(path, rel string) ([]byte, bool, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("datapack %s: %w", path, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != rel {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, false, err
+		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		return b, err == nil, err
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,107 @@
+// Package world locates the storage directories of a dimension inside a
+// world folder.
+package world
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when no directory exists for a dimension.
+var ErrNotFound = errors.New("dimension not found")
+
+// Dirs are the storage directories of one dimension.
+type Dirs struct {
+	// Dimension is the namespaced id, such as minecraft:overworld.
+	Dimension string
+	// Root is the dimension folder holding the three directories below,
+	// such as the world folder for the overworld or DIM-1 for the nether.
+	Root string
+	// Region, Entities and POI hold the region files of terrain chunks,
+	// entity chunks and point-of-interest records. They need not exist.
+	Region   string
+	Entities string
+	POI      string
+}
+
+func dirsAt(dimension, root string) Dirs {
+	return Dirs{
+		Dimension: dimension,
+		Root:      root,
+		Region:    filepath.Join(root, "region"),
+		Entities:  filepath.Join(root, "entities"),
+		POI:       filepath.Join(root, "poi"),
+	}
+}
+
+var idPart = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+var pathPart = regexp.MustCompile(`^[a-z0-9_.\-/]+$`)
+
+// NormalizeDimension turns a dimension name into a namespaced id. An empty
+// name is the overworld; "nether" and "end" are accepted as short forms and
+// names without a namespace get minecraft:.
+func NormalizeDimension(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "overworld":
+		return "minecraft:overworld", nil
+	case "nether", "the_nether":
+		return "minecraft:the_nether", nil
+	case "end", "the_end":
+		return "minecraft:the_end", nil
+	}
+	ns, path, ok := strings.Cut(name, ":")
+	if !ok {
+		ns, path = "minecraft", name
+	}
+	if !idPart.MatchString(ns) || !pathPart.MatchString(path) || strings.Contains(path, "..") {
+		return "", fmt.Errorf("invalid dimension %q", name)
+	}
+	return ns + ":" + path, nil
+}
+
+// Candidates returns the directories a dimension may be stored in, most
+// specific first: the per-dimension layout under dimensions/<ns>/<name>,
+// the classic vanilla layout (DIM-1, DIM1), and the Bukkit layout where
+// the nether and end are sibling world folders (world_nether/DIM-1).
+func Candidates(worldDir, dimension string) ([]string, error) {
+	id, err := NormalizeDimension(dimension)
+	if err != nil {
+		return nil, err
+	}
+	worldDir = filepath.Clean(worldDir)
+	ns, path, _ := strings.Cut(id, ":")
+	out := []string{filepath.Join(worldDir, "dimensions", ns, filepath.FromSlash(path))}
+	switch id {
+	case "minecraft:overworld":
+		out = append(out, worldDir)
+	case "minecraft:the_nether":
+		out = append(out, filepath.Join(worldDir, "DIM-1"), filepath.Join(worldDir+"_nether", "DIM-1"))
+	case "minecraft:the_end":
+		out = append(out, filepath.Join(worldDir, "DIM1"), filepath.Join(worldDir+"_the_end", "DIM1"))
+	}
+	return out, nil
+}
+
+// Resolve returns the directories of dimension in the world at worldDir,
+// using the first candidate that has a region folder.
+func Resolve(worldDir, dimension string) (Dirs, error) {
+	id, err := NormalizeDimension(dimension)
+	if err != nil {
+		return Dirs{}, err
+	}
+	cands, err := Candidates(worldDir, id)
+	if err != nil {
+		return Dirs{}, err
+	}
+	for _, c := range cands {
+		if info, err := os.Stat(filepath.Join(c, "region")); err == nil && info.IsDir() {
+			return dirsAt(id, c), nil
+		}
+	}
+	return Dirs{}, fmt.Errorf("%w: %s in %s (tried %s)", ErrNotFound, id, worldDir, strings.Join(cands, ", "))
+}
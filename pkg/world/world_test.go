@@ -0,0 +1,182 @@
+package world
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func mkdirs(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNormalizeDimension(t *testing.T) {
+	cases := map[string]string{
+		"":                       "minecraft:overworld",
+		"nether":                 "minecraft:the_nether",
+		"minecraft:the_end":      "minecraft:the_end",
+		"mining":                 "minecraft:mining",
+		"MyPack:worlds/skyblock": "mypack:worlds/skyblock",
+	}
+	for in, want := range cases {
+		got, err := NormalizeDimension(in)
+		if err != nil || got != want {
+			t.Errorf("%q: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"a:b:c", "ns:../escape", "bad name"} {
+		if _, err := NormalizeDimension(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestResolveVanilla(t *testing.T) {
+	w := filepath.Join(t.TempDir(), "world")
+	mkdirs(t, filepath.Join(w, "region"), filepath.Join(w, "DIM-1", "region"),
+		filepath.Join(w, "dimensions", "pack", "mining", "region"))
+
+	d, err := Resolve(w, "overworld")
+	if err != nil || d.Region != filepath.Join(w, "region") || d.Entities != filepath.Join(w, "entities") {
+		t.Fatalf("overworld: got %+v, %v", d, err)
+	}
+	d, err = Resolve(w, "the_nether")
+	if err != nil || d.POI != filepath.Join(w, "DIM-1", "poi") {
+		t.Fatalf("nether: got %+v, %v", d, err)
+	}
+	d, err = Resolve(w, "pack:mining")
+	if err != nil || d.Root != filepath.Join(w, "dimensions", "pack", "mining") {
+		t.Fatalf("custom: got %+v, %v", d, err)
+	}
+	if _, err := Resolve(w, "the_end"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("end: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestResolveBukkitAndPerDimensionLayout(t *testing.T) {
+	base := t.TempDir()
+	w := filepath.Join(base, "world")
+	mkdirs(t, filepath.Join(w, "region"), filepath.Join(base, "world_the_end", "DIM1", "region"),
+		filepath.Join(w, "dimensions", "minecraft", "the_nether", "region"))
+
+	d, err := Resolve(w, "end")
+	if err != nil || d.Region != filepath.Join(base, "world_the_end", "DIM1", "region") {
+		t.Fatalf("bukkit end: got %+v, %v", d, err)
+	}
+	d, err = Resolve(w, "nether")
+	if err != nil || d.Root != filepath.Join(w, "dimensions", "minecraft", "the_nether") {
+		t.Fatalf("per-dimension nether: got %+v, %v", d, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	mkdirs(t, filepath.Dir(path))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDimensionType(t *testing.T) {
+	w := t.TempDir()
+	if dt, err := LoadDimensionType(w, "the_nether"); err != nil || dt.MinY != 0 || dt.MaxY() != 255 {
+		t.Fatalf("vanilla nether: got %+v, %v", dt, err)
+	}
+
+	pack := filepath.Join(w, "datapacks", "tall", "data")
+	writeFile(t, filepath.Join(pack, "minecraft", "dimension_type", "overworld.json"), `{"min_y":-128,"height":640,"ultrawarm":false}`)
+	writeFile(t, filepath.Join(pack, "pack", "dimension", "mining.json"), `{"type":"pack:deep","generator":{}}`)
+	writeFile(t, filepath.Join(pack, "pack", "dimension_type", "deep.json"), `{"min_y":-256,"height":512}`)
+	writeFile(t, filepath.Join(pack, "pack", "dimension", "inline.json"), `{"type":{"min_y":0,"height":128}}`)
+
+	cases := map[string][2]int{
+		"overworld":   {-128, 511},
+		"pack:mining": {-256, 255},
+		"pack:inline": {0, 127},
+		"the_end":     {0, 255},
+	}
+	for dim, want := range cases {
+		dt, err := LoadDimensionType(w, dim)
+		if err != nil || dt.MinY != want[0] || dt.MaxY() != want[1] {
+			t.Errorf("%s: got %+v (max %d), %v; want %v", dim, dt, dt.MaxY(), err, want)
+		}
+	}
+	if _, err := LoadDimensionType(w, "pack:missing"); err == nil {
+		t.Fatal("expected error for unknown dimension")
+	}
+}
+
+func TestLoadDimensionTypeZip(t *testing.T) {
+	w := t.TempDir()
+	mkdirs(t, filepath.Join(w, "datapacks"))
+	f, err := os.Create(filepath.Join(w, "datapacks", "pack.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	fw, _ := zw.Create("data/minecraft/dimension_type/the_end.json")
+	fw.Write([]byte(`{"min_y":-64,"height":320}`))
+	zw.Close()
+	f.Close()
+
+	dt, err := LoadDimensionType(w, "the_end")
+	if err != nil || dt.MinY != -64 || dt.MaxY() != 255 {
+		t.Fatalf("got %+v, %v", dt, err)
+	}
+}
+
+func writeLevelDat(t *testing.T, worldDir string, enabled, disabled []string) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(worldDir, "level.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	level := map[string]any{"Data": map[string]any{
+		"LevelName": "test",
+		"DataPacks": map[string]any{"Enabled": enabled, "Disabled": disabled},
+	}}
+	if err := nbt.NewEncoder(zw).Encode(level, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDimensionTypePackOrder(t *testing.T) {
+	w := t.TempDir()
+	for name, height := range map[string]string{"a": "512", "b": "640"} {
+		writeFile(t, filepath.Join(w, "datapacks", name, "data", "minecraft", "dimension_type", "overworld.json"), `{"min_y":-64,"height":`+height+`}`)
+	}
+	cases := []struct {
+		enabled, disabled []string
+		want              int
+	}{
+		{nil, nil, 575},
+		{[]string{"vanilla", "file/b", "file/a"}, nil, 447},
+		{[]string{"vanilla", "file/a", "file/b"}, nil, 575},
+		{[]string{"vanilla", "file/b"}, []string{"file/a"}, 575},
+		{[]string{"vanilla", "file/a"}, []string{"file/b"}, 447},
+	}
+	for _, c := range cases {
+		if c.enabled != nil {
+			writeLevelDat(t, w, c.enabled, c.disabled)
+		}
+		dt, err := LoadDimensionType(w, "overworld")
+		if err != nil || dt.MaxY() != c.want {
+			t.Errorf("enabled %v, disabled %v: got %+v (max %d), %v; want max %d", c.enabled, c.disabled, dt, dt.MaxY(), err, c.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package nbtcli holds the release version of the nbt-cli module. The
+// library lives in the pkg/ packages; cmd/nbt-cli is the command line tool
+// built on them.
+package nbtcli
+
+// Version is the module's semantic version. The exported API of the pkg/
+// packages only changes incompatibly with a new major version.
+const Version = "1.0.0"